@@ -1,3 +1,3 @@
 module github.com/sosalejandro/otel-example-go/app1
 
-go 1.21.1
+go 1.22
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+)
+
+type graphQLPackage struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+var packageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Package",
+	Fields: graphql.Fields{
+		"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"status": &graphql.Field{Type: graphql.String},
+		// resolved on demand, each traced as its own span
+		"inStock": &graphql.Field{
+			Type: graphql.Boolean,
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				return checkWarehouse(p.Context, p.Source.(graphQLPackage).ID)
+			},
+		},
+		"deliveryHours": &graphql.Field{
+			Type: graphql.Float,
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				if d, ok := deliveryTimes[p.Source.(graphQLPackage).ID]; ok {
+					return d.Hours(), nil
+				}
+				return nil, errors.New("no delivery time for package")
+			},
+		},
+	},
+})
+
+// Schema of the package API served at /graphql, every resolver getting a
+// span.
+var packageSchema = func() graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"package": &graphql.Field{
+					Type: packageType,
+					Args: graphql.FieldConfigArgument{
+						"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					},
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						id, _ := p.Args["id"].(string)
+						status := getPackage(p.Context, id)
+						if status == "unknown" {
+							return nil, errors.New("package not found")
+						}
+						return graphQLPackage{ID: id, Status: status}, nil
+					},
+				},
+			},
+		}),
+		Extensions: []graphql.Extension{telemetry.GraphQLExtension()},
+	})
+	telemetry.HandleErr(err, "Failed to build the GraphQL schema")
+	return schema
+}()
+
+// Serves GraphQL queries, sent as JSON with POST or in the query parameter
+// with GET.
+func graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query         string         `json:"query"`
+		OperationName string         `json:"operationName"`
+		Variables     map[string]any `json:"variables"`
+	}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.Query, req.OperationName = r.URL.Query().Get("query"), r.URL.Query().Get("operationName")
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         packageSchema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        r.Context(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
@@ -1,111 +1,752 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sosalejandro/otel-example/commons/envconfig"
+	"github.com/sosalejandro/otel-example/commons/flags"
+	"github.com/sosalejandro/otel-example/commons/packagesvc"
+	"github.com/sosalejandro/otel-example/commons/server"
 	"github.com/sosalejandro/otel-example/commons/telemetry"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 const serverName = "otel-example-server"
 
 func main() {
-	// ...
+	checkConfig := flag.Bool("check-config", false, "validate the telemetry config and exit")
+	flag.Parse()
 
-	otelShutdown := telemetry.InitProvider(serverName)
-	defer otelShutdown()
+	// strategies may be managed centrally, OTEL_TRACES_SAMPLER=jaeger_remote
+	baseSampler := telemetry.GetSampler()
+	if remote, ok := telemetry.JaegerRemoteFromEnv(); ok {
+		baseSampler = telemetry.NewJaegerRemoteSampler(serverName, remote)
+	}
+	// admin endpoints are not worth tracing
+	sampler := telemetry.NewDynamicSampler(telemetry.NewRuleSampler(
+		baseSampler,
+		telemetry.SamplingRule{Route: "/admin/otel/**", Ratio: 0},
+		telemetry.SamplingRule{Route: "/debug/**", Ratio: 0},
+	))
+	// lets operators turn tracing off or redirect it at runtime
+	controller := telemetry.NewController(sampler)
+	opts := []telemetry.Option{
+		telemetry.WithController(controller),
+		telemetry.WithExemplars(true),
+		telemetry.WithErrorPriority(true),
+		telemetry.WithSampler(sampler),
+		telemetry.WithRedaction(telemetry.NewRedactor(
+			telemetry.DenyKeys(telemetry.RedactDrop, "http.request.header.authorization", "http.request.header.cookie"),
+			telemetry.RedactValues(telemetry.EmailPattern, telemetry.RedactHash),
+			telemetry.RedactValues(telemetry.CardNumberPattern, telemetry.RedactHash),
+		)),
+		telemetry.WithDialTimeout(3 * time.Second),
+		// protect the collector from traffic spikes
+		telemetry.WithSpanRateLimit(1000, 2000),
+		telemetry.WithTruncationMetric(true),
+		// package lookups should succeed within 300ms, 99% of the time
+		telemetry.WithSLOs(telemetry.SLO{
+			Name:      "package-lookup",
+			Spans:     []string{"GET /packages/*", "GET /v1/packages/*"},
+			Latency:   300 * time.Millisecond,
+			Objective: 0.99,
+		}),
+		// stop waiting on a stalled collector
+		telemetry.WithCircuitBreaker(5, 30*time.Second),
+		// back off while the collector rejects spans
+		telemetry.WithDegradedSampling(0.1, time.Minute),
+		telemetry.WithSpanNameCheck(os.Getenv("GO_ENV") != "production"),
+		telemetry.WithConnectionStateHandler(func(state connectivity.State) {
+			log.Printf("collector connection: %s", state)
+		}),
+	}
+	// deployed instances describe where they run (cloud.region, k8s.pod.name, ...)
+	if os.Getenv("GO_ENV") == "production" {
+		opts = append(opts, telemetry.WithCloudDetection(2*time.Second))
+	}
+	// per-customer sampling, e.g. TENANT_SAMPLING=acme=0.1,globex=1
+	if ratios, ok := os.LookupEnv("TENANT_SAMPLING"); ok {
+		tenants, err := telemetry.ParseTenantRatios(ratios)
+		telemetry.HandleErr(err, "Invalid TENANT_SAMPLING")
+		opts = append(opts, telemetry.WithTenantSampling(tenants))
+	}
+	// sampler, log level, redaction and exporter reloaded on SIGHUP or
+	// change, e.g. TELEMETRY_CONFIG=telemetry.yaml
+	if path := os.Getenv("TELEMETRY_CONFIG"); path != "" {
+		opts = append(opts, telemetry.WithConfigFile(path))
+	}
+	// composable span processing, e.g.
+	// SPAN_PIPELINE="filter:names=GET /healthz;baggage-copy:keys=destination"
+	if pipeline := os.Getenv("SPAN_PIPELINE"); pipeline != "" {
+		specs, err := telemetry.ParseSpanPipeline(pipeline)
+		telemetry.HandleErr(err, "Invalid SPAN_PIPELINE")
+		opts = append(opts, telemetry.WithSpanPipeline(specs...))
+	}
+	// dual-write spans to the console while debugging
+	if os.Getenv("TRACES_CONSOLE_EXPORT") == "true" {
+		consoleExp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		telemetry.HandleErr(err, "Failed to create the console trace exporter")
+		opts = append(opts, telemetry.WithExporter("console", consoleExp))
+	}
+	// keep feeding the legacy dashboards, e.g. STATSD_ADDR=udp://localhost:8125
+	if addr, ok := os.LookupEnv("STATSD_ADDR"); ok {
+		opts = append(opts, telemetry.WithStatsDBridge(addr,
+			telemetry.WithStatsDPrefix("otel_example."),
+			telemetry.WithStatsDInstruments("http.server.*", "packages.*"),
+			telemetry.WithStatsDTag("http.response.status_code", "status"),
+			telemetry.WithStatsDTag("http.route", ""),
+		))
+	}
+	// continuous profiling, e.g. PYROSCOPE_URL=http://localhost:4040
+	if url, ok := os.LookupEnv("PYROSCOPE_URL"); ok {
+		opts = append(opts, telemetry.WithProfiler(&telemetry.PyroscopeProfiler{ServerURL: url}))
+	}
+	// browse recent spans at /debug/tracez outside production
+	// and tail them at /debug/spans/stream
+	var zpages *telemetry.ZPages
+	var spanTail *telemetry.SpanTail
+	if os.Getenv("GO_ENV") != "production" {
+		zpages = telemetry.NewZPages()
+		spanTail = telemetry.NewSpanTail(500)
+		opts = append(opts, telemetry.WithZPages(zpages), telemetry.WithSpanTail(spanTail))
+	}
+	// spans survive collector restarts, e.g. SPAN_QUEUE_DIR=/var/lib/otel-example
+	if dir, ok := os.LookupEnv("SPAN_QUEUE_DIR"); ok {
+		opts = append(opts, telemetry.WithPersistentQueue(dir, 64<<20))
+	}
+	// verbose spans of the lookup sweeper, kept out of the main pipeline,
+	// e.g. DEBUG_SPANS=true
+	if os.Getenv("DEBUG_SPANS") == "true" {
+		opts = append(opts, telemetry.WithNamedProvider("debug", telemetry.ProviderConfig{}))
+	}
+	// see spans the instant they end while debugging ordering issues
+	if os.Getenv("TRACES_SYNC_EXPORT") == "true" {
+		opts = append(opts, telemetry.WithSyncExportDevOnly(true))
+	}
+	// serves on the socket systemd passes, or on a Unix socket for a sidecar,
+	// e.g. UNIX_SOCKET=/run/otel-example/server.sock
+	runOpts := []server.Option{server.WithSocketActivation()}
+	if path := os.Getenv("UNIX_SOCKET"); path != "" {
+		runOpts = append(runOpts, server.WithUnixSocket(path))
+	}
+	opts = append(opts, telemetry.WithResourceAttributes(server.ListenerAttributes(runOpts...)...))
+	// the standard OTEL_* variables, e.g. OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT=64,
+	// ahead so the options above take precedence
+	envOpts, err := envconfig.Options()
+	if err != nil && *checkConfig {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	telemetry.HandleErr(err, "Invalid OTEL_* environment")
+	opts = append(envOpts, opts...)
+	if *checkConfig {
+		// views are checked against the instruments of the service
+		registerTelemetry()
+		initPackageMetrics()
+		checkTelemetryConfig(opts)
+	}
+	// shut down by server.Run once the server stopped
+	otelShutdown := telemetry.InitProvider(serverName, opts...)
+	// log calls become log records too
+	defer telemetry.RedirectStdLog()()
+	// a crash still exports the telemetry leading to it
+	defer telemetry.RecoverAndFlush(context.Background())
+	registerTelemetry()
+	initPackageMetrics()
+
+	router := newRouter()
+	sweep := telemetry.InstrumentJob("lookup-sweeper", sweepLookups, telemetry.WithJobSchedule("@every 30s"))
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go runEvery(jobCtx, 30*time.Second, sweep)
+	// manual runs link to the admin request which triggered them
+	router.HandleFunc("/admin/sweep", func(w http.ResponseWriter, r *http.Request) {
+		if err := sweep(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}).Methods(http.MethodPost)
+	router.Handle("/admin/otel/manifest", telemetry.ManifestHandler(serverName))
+	router.HandleFunc("/status", statusPage)
+	if token := os.Getenv("TELEMETRY_ADMIN_TOKEN"); token != "" {
+		router.PathPrefix("/debug/telemetry").Handler(controller.Handler(token))
+		router.Handle("/debug/sampling", sampler.Handler(token))
+	}
+	registerPprof(router)
+	// REST gateway in front of the gRPC service, e.g. GRPC_GATEWAY=true
+	if os.Getenv("GRPC_GATEWAY") == "true" {
+		router.Handle("/v1/packages/{id:[0-9]+}", newGateway()).Methods(http.MethodGet)
+	}
+	if zpages != nil {
+		router.Handle("/debug/tracez", zpages.Handler())
+		router.Handle("/debug/spans/stream", spanTail.Handler())
+	}
+
+	srvOpts := []server.HTTPOption{server.WithTimeouts(500*time.Millisecond, 1*time.Second, 15*time.Second)}
+	// HTTP/2 without TLS, e.g. H2C=true behind a proxy terminating TLS
+	if os.Getenv("H2C") == "true" {
+		srvOpts = append(srvOpts, server.WithH2C())
+	}
+	srv := server.New(":8080", router, srvOpts...)
+
+	grpcServer := packagesvc.NewServer(packageServer{}, grpc.UnaryInterceptor(telemetry.DeadlineUnaryServerInterceptor))
+	go serveGRPC(grpcServer)
+
+	runOpts = append(runOpts,
+		server.WithShutdownHook("grpc", func(ctx context.Context) error {
+			grpcServer.GracefulStop()
+			return nil
+		}),
+		// let async work spawned by requests finish before flushing telemetry
+		server.WithShutdownHook("background", func(ctx context.Context) error {
+			background.Wait()
+			return nil
+		}),
+	)
+	if err := server.Run(srv, otelShutdown, runOpts...); err != nil {
+		log.Fatalf("Failed to run server: %v", err)
+	}
+}
 
+// Creates the router of the package API with its telemetry middlewares,
+// without the admin and debug endpoints, so tests can serve it in-process.
+func newRouter() *mux.Router {
 	router := mux.NewRouter()
 	router.Use(
+		telemetry.TenantMiddleware,
 		otelmux.Middleware(
 			serverName,
 			otelmux.WithSpanNameFormatter(func(routeName string, r *http.Request) string {
-				return fmt.Sprintf("%s %s", r.Method, routeName)
+				return telemetry.SpanNamer{}.HTTPServer(r.Method, routeName)
 			})),
+		telemetry.LazyAttributesMiddleware,
+		telemetry.LatencyMiddleware(serverName),
+		telemetry.InFlightMiddleware(serverName),
+		telemetry.TraceResponseMiddleware(),
+		telemetry.RequestIDMiddleware,
+		telemetry.DeadlineMiddleware,
+		telemetry.AccessLogMiddleware(accessLogger()),
 	)
+	if os.Getenv("CAPTURE_HTTP_BODIES") == "true" {
+		router.Use(telemetry.BodyCaptureMiddleware())
+	}
+	// faults asked for through baggage, e.g. fault=delay:200ms, for demos
+	if os.Getenv("FAULT_INJECTION") == "true" {
+		router.Use(telemetry.FaultMiddleware)
+	}
 
 	router.HandleFunc("/packages/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		id := vars["id"]
+		// every span of the lookup carries the package id
+		ctx := telemetry.WithAttrs(r.Context(), attribute.String("package.id", id))
 		// package response
-		pr := getPackage(r.Context(), id)
-
-		baggage := baggage.FromContext(r.Context())
+		pr := getPackage(ctx, id)
 
-		// late adquisition of the span to add attributes
-		span := trace.SpanFromContext(r.Context())
-		destination := baggage.Member("destination").Value()
-		transportation := baggage.Member("transportation").Value()
-		destinationAttr := trace.WithAttributes(attribute.String("destination", destination))
-		transportationAttr := trace.WithAttributes(attribute.String("transportation", transportation))
-		span.AddEvent("Obtaining package", destinationAttr, transportationAttr)
+		// late adquisition of the span to add attributes, skipped with the
+		// baggage extraction when the request is not recorded
+		telemetry.IfRecording(r.Context(), func(span trace.Span) {
+			bag := baggage.FromContext(r.Context())
+			span.AddEvent("Obtaining package", trace.WithAttributes(
+				attribute.String("destination", bag.Member("destination").Value()),
+				attribute.String("transportation", bag.Member("transportation").Value()),
+			))
+		})
 
-		reply := fmt.Sprintf("package is %s (id %s)\n", pr, id)
+		reply := "package is " + pr + " (id " + id + ")\n"
+		telemetry.AddEventf(r.Context(), "package replied", "replied %q to package %s lookup", pr, id)
+		// only computed if the request span was sampled
+		telemetry.AddLazyAttributes(r.Context(), telemetry.Lazy("package.reply_size", func() attribute.Value {
+			return attribute.IntValue(len(reply))
+		}))
 		_, _ = w.Write(([]byte)(reply))
+
+		// the desk is notified without holding the response back
+		telemetry.Go(r.Context(), "notify shipping desk", func(ctx context.Context) error {
+			return notifyShippingDesk(ctx, id)
+		}, &background)
 	})
 
-	server := &http.Server{
-		Addr:         ":8080",
-		Handler:      router,
-		ReadTimeout:  500 * time.Millisecond,
-		WriteTimeout: 1 * time.Second,
-		IdleTimeout:  15 * time.Second,
+	router.HandleFunc("/packages/{id:[0-9]+}/events", packageEvents)
+	router.HandleFunc("/packages/{id:[0-9]+}/download", packageDownload)
+	router.HandleFunc("/graphql", graphQLHandler).Methods(http.MethodGet, http.MethodPost)
+	router.Handle(packagesvc.UpdatesPath, websocket.Handler(packageUpdates))
+	return router
+}
+
+// Reports every misconfigured telemetry setting and exits, with a failure
+// status when there is any.
+func checkTelemetryConfig(opts []telemetry.Option) {
+	if err := telemetry.ValidateConfig(telemetry.NewConfig(serverName, opts...)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("telemetry config is valid")
+	os.Exit(0)
+}
+
+// Registers the spans, events and attributes emitted by this service into the
+// telemetry manifest.
+func registerTelemetry() {
+	telemetry.RegisterSpans(
+		telemetry.Descriptor{
+			Name:        "GET /packages/{id:[0-9]+}",
+			Kind:        "server",
+			Description: "Package lookup request.",
+			Attributes:  []string{"package.reply_size"},
+		},
+		telemetry.Descriptor{
+			Name:        "GET /v1/packages/{id:[0-9]+}",
+			Kind:        "server",
+			Description: "Package lookup through the REST gateway, calling the gRPC service.",
+		},
+		telemetry.Descriptor{
+			Name:        "graphql.resolve Query.package",
+			Kind:        "internal",
+			Description: "Resolution of a package queried at /graphql, parent of its inStock and deliveryHours resolvers.",
+			Attributes: []string{
+				string(telemetry.GraphQLFieldNameKey),
+				string(telemetry.GraphQLFieldPathKey),
+				string(telemetry.GraphQLParentTypeKey),
+			},
+		},
+		telemetry.Descriptor{
+			Name:        "getPackage",
+			Kind:        "internal",
+			Description: "Looks up a package by id.",
+			Attributes:  []string{"package.id", "stock.available"},
+		},
+		telemetry.Descriptor{
+			Name:        "notify shipping desk",
+			Kind:        "internal",
+			Description: "Asynchronous notification made after the lookup response.",
+		},
+		telemetry.Descriptor{
+			Name:        "GET warehouse",
+			Kind:        "client",
+			Description: "Stock check against the warehouse service.",
+			Attributes:  []string{"peer.service"},
+		},
+		telemetry.Descriptor{
+			Name:        "GET /packages/{id:[0-9]+}/events",
+			Kind:        "server",
+			Description: "Server-Sent Events stream of package status changes.",
+		},
+		telemetry.Descriptor{
+			Name:        "package event",
+			Kind:        "internal",
+			Description: "Server-Sent Event written to the stream.",
+			Attributes:  []string{"package", "package.status", "sse.event_id"},
+		},
+		telemetry.Descriptor{
+			Name:        packagesvc.UpdatesPath + " receive",
+			Kind:        "consumer",
+			Description: "Subscription message received over WebSocket.",
+			Attributes:  []string{"package"},
+		},
+		telemetry.Descriptor{
+			Name:        packagesvc.UpdatesPath + " send",
+			Kind:        "producer",
+			Description: "Package status change pushed over WebSocket.",
+			Attributes:  []string{"package", "package.status"},
+		},
+	)
+	telemetry.RegisterEvents(
+		telemetry.Descriptor{Name: "Obtaining package", Attributes: []string{"destination", "transportation"}},
+		telemetry.Descriptor{Name: "getPackage", Attributes: []string{"package"}},
+		telemetry.Descriptor{Name: "found package"},
+		telemetry.Descriptor{Name: "shipping desk notified", Attributes: []string{"package", "destination"}},
+		telemetry.Descriptor{Name: "package replied", Attributes: []string{string(telemetry.EventMessageKey)}},
+		telemetry.Descriptor{Name: "sse event sent", Attributes: []string{"sse.event_id", "package.status"}},
+		telemetry.Descriptor{Name: "context canceled", Attributes: []string{"context.cancel.reason", "context.cancel.cause"}},
+		telemetry.Descriptor{Name: "client disconnected", Attributes: []string{"sse.events_sent"}},
+		telemetry.Descriptor{Name: "fault.injected", Attributes: []string{
+			string(telemetry.FaultTypeKey), string(telemetry.FaultDelayKey), string(telemetry.FaultStatusKey),
+		}},
+	)
+	telemetry.RegisterAttributes(
+		telemetry.Descriptor{Name: "destination", Description: "Package destination, taken from baggage."},
+		telemetry.Descriptor{Name: "transportation", Description: "Transportation method, taken from baggage."},
+		telemetry.Descriptor{Name: "package", Description: "Id of the requested package."},
+		telemetry.Descriptor{Name: "package.id", Description: "Id of the looked up package, on every span of the lookup."},
+		telemetry.Descriptor{Name: "package.reply_size", Description: "Size in bytes of the reply body."},
+		telemetry.Descriptor{Name: "message", Description: "Message of the event, formatted once the span ends."},
+		telemetry.Descriptor{Name: "context.deadline.remaining_ms", Description: "Time left before the caller deadline on entry."},
+		telemetry.Descriptor{Name: "tenant.id", Description: "Tenant of the request, from X-Tenant-ID or baggage."},
+		telemetry.Descriptor{Name: "sweep.removed", Description: "Lookups forgotten by a sweeper run."},
+		telemetry.Descriptor{Name: "stock.available", Description: "Whether the warehouse has the package in stock."},
+		telemetry.Descriptor{Name: "package.status", Description: "Status pushed to package update subscribers."},
+	)
+}
+
+// Access logs go to stdout unless ACCESS_LOG_FILE names a file to append to.
+func accessLogger() *slog.Logger {
+	path, ok := os.LookupEnv("ACCESS_LOG_FILE")
+	if !ok {
+		return telemetry.NewJSONAccessLogger(os.Stdout)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	telemetry.HandleErr(err, "Failed to open the access log file")
+	return telemetry.NewJSONAccessLogger(f)
+}
+
+// gRPC flavour of the package lookup endpoint.
+type packageServer struct {
+	packagesvc.UnimplementedPackageServiceServer
+}
+
+func (packageServer) GetPackage(ctx context.Context, req *packagesvc.GetPackageRequest) (*packagesvc.GetPackageResponse, error) {
+	telemetry.IfRecording(ctx, func(span trace.Span) {
+		bag := baggage.FromContext(ctx)
+		span.AddEvent("Obtaining package", trace.WithAttributes(
+			attribute.String("destination", bag.Member("destination").Value()),
+			attribute.String("transportation", bag.Member("transportation").Value()),
+		))
+	})
+
+	return &packagesvc.GetPackageResponse{Id: req.GetId(), Status: getPackage(ctx, req.GetId())}, nil
+}
+
+// Serves the package lookup over REST by calling this service's own gRPC
+// server, so a gateway request traces the REST and the gRPC layers.
+func newGateway() http.Handler {
+	target := grpcAddr()
+	if strings.HasPrefix(target, ":") {
+		target = "localhost" + target
+	}
+	client, err := packagesvc.NewClient(target)
+	telemetry.HandleErr(err, "Failed to create the gateway client")
+	gateway, err := packagesvc.NewGateway(client)
+	telemetry.HandleErr(err, "Failed to create the gateway")
+	return gateway
+}
+
+// Address of the gRPC server.
+func grpcAddr() string {
+	if addr, ok := os.LookupEnv("GRPC_ADDR"); ok {
+		return addr
 	}
+	return ":8081"
+}
 
-	if err := runServer(server); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+func serveGRPC(server *grpc.Server) {
+	lis, err := net.Listen("tcp", grpcAddr())
+	if err != nil {
+		log.Printf("gRPC listen error: %v", err)
+		return
+	}
+	if err := server.Serve(lis); err != nil {
+		log.Printf("gRPC server error: %v", err)
 	}
 }
 
-func runServer(server *http.Server) error {
-	// Start the server in a separate goroutine
-	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			log.Printf("Server error: %v", err)
+// Statuses streamed to package update subscribers.
+var packageStatuses = []string{"packed", "shipped", "in transit", "delivered"}
+
+// Streams the status changes of the package a client subscribes to. The
+// trace context comes with the handshake request, and every message gets its
+// own span linked to the span of the peer which sent it.
+func packageUpdates(ws *websocket.Conn) {
+	// the server write timeout must not cut the stream
+	_ = ws.SetDeadline(time.Time{})
+	ctx := ws.Request().Context()
+	tracer := telemetry.Tracer(serverName, "")
+
+	var sub packagesvc.Subscription
+	if err := websocket.JSON.Receive(ws, &sub); err != nil {
+		trace.SpanFromContext(ctx).RecordError(err)
+		return
+	}
+	_, span := packagesvc.StartReceiveSpan(ctx, tracer, sub.ID, sub.Trace)
+	span.End()
+
+	for _, status := range packageStatuses {
+		time.Sleep(500 * time.Millisecond)
+		_, span, carrier := packagesvc.StartSendSpan(ctx, tracer, sub.ID)
+		span.SetAttributes(attribute.String("package.status", status))
+		err := websocket.JSON.Send(ws, packagesvc.Update{ID: sub.ID, Status: status, Trace: carrier})
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "client gone")
+			span.End()
+			return
 		}
-	}()
+		span.End()
+	}
+}
 
-	// Wait for an interrupt signal to gracefully shut down the server
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	<-stop
+// Streams the documents of a package, "size" bytes (16 MiB by default) sent
+// in chunks at "rate" bytes per second (4 MiB/s by default). The request span
+// gets progress events along the way and a summary of the transfer.
+func packageDownload(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.ParseInt(r.URL.Query().Get("size"), 10, 64)
+	if err != nil || size <= 0 {
+		size = 16 << 20
+	}
+	rate, err := strconv.ParseInt(r.URL.Query().Get("rate"), 10, 64)
+	if err != nil || rate <= 0 {
+		rate = 4 << 20
+	}
+	rc := http.NewResponseController(w)
+	// the server write timeout must not cut the download
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
 
-	log.Println("Shutting down server...")
+	chunk := bytes.Repeat([]byte("package "+mux.Vars(r)["id"]+"\n"), 4096)
+	pause := time.Duration(float64(len(chunk)) / float64(rate) * float64(time.Second))
+	out := telemetry.NewTransferWriter(r.Context(), w, 250*time.Millisecond)
+	defer out.Finish()
+	for sent := int64(0); sent < size; {
+		n, err := out.Write(chunk[:min(int64(len(chunk)), size-sent)])
+		sent += int64(n)
+		if err != nil {
+			trace.SpanFromContext(r.Context()).RecordError(err)
+			return
+		}
+		_ = rc.Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(pause):
+		}
+	}
+}
 
-	// Create a context with a timeout of 5 seconds to allow outstanding requests to finish
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Streams the status changes of a package as Server-Sent Events. Each event
+// is written within its own child span and carries the trace ID, while the
+// long-lived request span collects one span event per event sent.
+func packageEvents(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	tracer := telemetry.Tracer(serverName, "")
 
-	// Shut down the server gracefully
-	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
-		return err
+	rc := http.NewResponseController(w)
+	// the server write timeout must not cut the stream
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for i, status := range packageStatuses {
+		select {
+		case <-ctx.Done():
+			span.AddEvent("client disconnected", trace.WithAttributes(attribute.Int("sse.events_sent", i)))
+			return
+		case <-ticker.C:
+		}
+
+		_, tick := tracer.Start(ctx, "package event", trace.WithAttributes(
+			attribute.String("package", id),
+			attribute.String("package.status", status),
+			attribute.Int("sse.event_id", i),
+		))
+		data, _ := json.Marshal(map[string]string{
+			"id":       id,
+			"status":   status,
+			"trace_id": span.SpanContext().TraceID().String(),
+		})
+		_, err := fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", i, data)
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			tick.RecordError(err)
+			tick.SetStatus(codes.Error, "event not delivered")
+			tick.End()
+			return
+		}
+		tick.End()
+		span.AddEvent("sse event sent", trace.WithAttributes(
+			attribute.Int("sse.event_id", i),
+			attribute.String("package.status", status),
+		))
 	}
+}
 
-	log.Println("Server shut down.")
+// Goroutines spawned by requests and still running.
+var background sync.WaitGroup
 
+// Simulates a call to the shipping desk, made after the lookup response is
+// sent, the destination coming from the request baggage.
+func notifyShippingDesk(ctx context.Context, id string) error {
+	time.Sleep(100 * time.Millisecond)
+	telemetry.IfRecording(ctx, func(span trace.Span) {
+		span.AddEvent("shipping desk notified", trace.WithAttributes(
+			attribute.String("package", id),
+			attribute.String("destination", baggage.FromContext(ctx).Member("destination").Value()),
+		))
+	})
+	return nil
+}
+
+// Packages looked up recently with the time of the lookup, cleared by the
+// background sweeper.
+var recentLookups sync.Map
+
+// How long lookups are remembered.
+const lookupTTL = time.Minute
+
+// Forgets the lookups older than lookupTTL.
+func sweepLookups(ctx context.Context) error {
+	// one span per entry, only with DEBUG_SPANS
+	debug := telemetry.Provider("debug").Tracer(serverName)
+	removed := 0
+	recentLookups.Range(func(id, at any) bool {
+		_, span := debug.Start(ctx, "sweepLookups.entry", trace.WithAttributes(attribute.String("package.id", id.(string))))
+		defer span.End()
+		expired := time.Since(at.(time.Time)) > lookupTTL
+		span.SetAttributes(attribute.Bool("sweep.expired", expired))
+		if expired {
+			recentLookups.Delete(id)
+			removed++
+		}
+		return true
+	})
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("sweep.removed", removed))
 	return nil
 }
 
+// Runs job every interval until ctx is done.
+func runEvery(ctx context.Context, interval time.Duration, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				log.Printf("Background job error: %v", err)
+			}
+		}
+	}
+}
+
 func getPackage(ctx context.Context, id string) string {
-	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(serverName).Start(ctx, "getPackage")
-	defer span.End()
+	ctx, span, end := telemetry.Span(ctx, "getPackage").Scope(serverName).Attr("package.id", id).Start()
+	defer end(nil)
+	recentLookups.Store(id, time.Now())
 
 	span.AddEvent("getPackage", trace.WithAttributes(attribute.String("package", id)))
-	if id == "123" {
+	found := id == "123"
+	if flags.Enabled(ctx, indexedLookup) {
+		_, found = deliveryTimes[id]
+	}
+	if found {
 		span.AddEvent("found package")
+		packageLookups.Add(ctx, 1, lookupFound)
+		if d, ok := deliveryTimes[id]; ok {
+			deliveryTime.Record(ctx, d.Hours())
+		}
+		available, err := checkWarehouse(ctx, id)
+		if err != nil {
+			// the lookup still succeeds without the warehouse
+			span.RecordError(err)
+		} else {
+			span.SetAttributes(attribute.Bool("stock.available", available))
+		}
 		return "found package"
 	}
+	// exported through the error priority queue
 	span.RecordError(fmt.Errorf("package not found"))
+	span.SetStatus(codes.Error, "package not found")
+	packageLookups.Add(ctx, 1, lookupUnknown)
 	return "unknown"
 }
+
+// Experimental lookup of the packages in the delivery index instead of the
+// hardcoded one, enabled with FEATURE_FLAGS=indexed-lookup=on.
+var indexedLookup = flags.Flag{Key: "indexed-lookup", DefaultVariant: "off"}
+
+// Business metrics of the package lookups.
+var (
+	packageLookups metric.Int64Counter
+	deliveryTime   metric.Float64Histogram
+)
+
+// Lookup results, built once as every lookup records one.
+var (
+	lookupFound   = metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "found")))
+	lookupUnknown = metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "unknown")))
+)
+
+// Time taken to deliver the known packages.
+var deliveryTimes = map[string]time.Duration{"123": 36 * time.Hour}
+
+// Creates the business metrics, exported next to the RPC metrics. The lookup
+// counter shows as packages_lookup_total in Prometheus.
+func initPackageMetrics() {
+	packageLookups = telemetry.NewCounter(serverName, telemetry.Descriptor{
+		Name:        "packages.lookup",
+		Unit:        "{lookup}",
+		Description: "Package lookups by result, found or unknown.",
+		Attributes:  []string{"result"},
+	})
+	deliveryTime = telemetry.NewHistogram(serverName, telemetry.Descriptor{
+		Name:        "packages.delivery.duration",
+		Unit:        "h",
+		Description: "Time taken to deliver the packages looked up.",
+	}, 6, 12, 24, 48, 72, 120, 168)
+}
+
+// Client for the downstream warehouse service (app3), reporting the use of
+// its connection pool.
+var warehouse = telemetry.NewPeerClient("warehouse",
+	telemetry.InstrumentTransport("warehouse", telemetry.NewTransport(
+		telemetry.WithMaxIdleConnsPerHost(16),
+		telemetry.WithIdleConnTimeout(30*time.Second),
+	)))
+
+// Asks the warehouse service whether the package is in stock.
+func checkWarehouse(ctx context.Context, id string) (bool, error) {
+	base, ok := os.LookupEnv("WAREHOUSE_URL")
+	if !ok {
+		base = "http://localhost:8082"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/stock/"+id, nil)
+	if err != nil {
+		return false, err
+	}
+	telemetry.SetRequestIDHeader(req)
+
+	res, err := warehouse.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("warehouse answered %s", res.Status)
+	}
+	var stock struct {
+		Available bool `json:"available"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&stock); err != nil {
+		return false, err
+	}
+	return stock.Available, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sosalejandro/otel-example/commons/packagesvc"
+	"github.com/sosalejandro/otel-example/commons/teletest"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Serves a warehouse reporting every package in stock, as WAREHOUSE_URL.
+func fakeWarehouse(tb testing.TB) {
+	tb.Helper()
+	srv := httptest.NewServer(otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"available":true}`)
+	}), "GET /stock/{id}"))
+	tb.Cleanup(srv.Close)
+	tb.Setenv("WAREHOUSE_URL", srv.URL)
+}
+
+// Returns a harness serving the router of newRouter, built once the harness
+// installed its tracer provider since the instrumentation binds the one it
+// finds when created.
+func newHarness(t *testing.T) (*teletest.Harness, *mux.Router) {
+	var router *mux.Router
+	h := teletest.New(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { router.ServeHTTP(w, r) }))
+	router = newRouter()
+	return h, router
+}
+
+func TestPackageLookupTrace(t *testing.T) {
+	t.Setenv("ACCESS_LOG_FILE", os.DevNull)
+	initPackageMetrics()
+	fakeWarehouse(t)
+	h, _ := newHarness(t)
+
+	res, err := h.Get(context.Background(), "/packages/123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /packages/123 answered %s", res.Status)
+	}
+	// the shipping desk is notified after the response
+	background.Wait()
+
+	h.AssertTrace(t, teletest.ExpectTrace().
+		Root("HTTP GET").WithKind(trace.SpanKindClient).
+		Child("GET /packages/*").WithKind(trace.SpanKindServer).WithStatus(codes.Unset).
+		Child("getPackage").WithKind(trace.SpanKindInternal).
+		WithAttr(attribute.String("package.id", "123"), attribute.Bool("stock.available", true)).
+		Child("GET warehouse").WithKind(trace.SpanKindClient).
+		Child("GET /stock/{id}").WithKind(trace.SpanKindServer).
+		Parent().Parent().Parent().
+		Child("notify shipping desk"))
+}
+
+// A failed lookup carries the error status routing it through the error
+// priority queue.
+func TestUnknownPackageLookupTrace(t *testing.T) {
+	t.Setenv("ACCESS_LOG_FILE", os.DevNull)
+	initPackageMetrics()
+	h, _ := newHarness(t)
+
+	res, err := h.Get(context.Background(), "/packages/456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	background.Wait()
+
+	h.AssertTrace(t, teletest.ExpectTrace().
+		Root("HTTP GET").
+		Child("GET /packages/*").
+		Child("getPackage").WithAttr(attribute.String("package.id", "456")).WithStatus(codes.Error))
+}
+
+// Lookup of an unknown package, served without calling the warehouse so the
+// measurements do not depend on the network.
+const overheadPath = "/packages/456"
+
+// Keeps the tracing overhead of the package lookup within the budget of the
+// services of this repository.
+func TestPackageLookupOverheadBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("measures every tracing mode")
+	}
+	t.Setenv("ACCESS_LOG_FILE", os.DevNull)
+	initPackageMetrics()
+	results := teletest.MeasureHandlerOverhead(func() http.Handler { return newRouter() }, overheadPath)
+	background.Wait()
+	for _, r := range results {
+		t.Logf("%-11s %6d ns/op %4d allocs/op", r.Mode, r.NsPerOp, r.AllocsPerOp)
+	}
+	if err := teletest.OverheadBudgetFromEnv().Check(results); err != nil {
+		t.Error(err)
+	}
+}
+
+// Compares the package lookup with tracing off, sampled out and sampled in.
+func BenchmarkPackageLookup(b *testing.B) {
+	b.Setenv("ACCESS_LOG_FILE", os.DevNull)
+	initPackageMetrics()
+	teletest.BenchmarkHandlerOverhead(b, func() http.Handler { return newRouter() }, overheadPath)
+	background.Wait()
+}
+
+// A lookup through the REST gateway traces the gateway route, the gRPC
+// client call it makes and the gRPC server handling it.
+func TestGatewayPackageLookupTrace(t *testing.T) {
+	t.Setenv("ACCESS_LOG_FILE", os.DevNull)
+	initPackageMetrics()
+	h, router := newHarness(t)
+	fakeWarehouse(t)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := packagesvc.NewServer(packageServer{})
+	go func() { _ = grpcServer.Serve(lis) }()
+	t.Cleanup(grpcServer.Stop)
+	t.Setenv("GRPC_ADDR", lis.Addr().String())
+	router.Handle("/v1/packages/{id:[0-9]+}", newGateway()).Methods(http.MethodGet)
+
+	res, err := h.Get(context.Background(), "/v1/packages/123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(body), `"id":"123"`) {
+		t.Fatalf("GET /v1/packages/123 answered %s: %s", res.Status, body)
+	}
+	background.Wait()
+
+	h.AssertTrace(t, teletest.ExpectTrace().
+		Root("HTTP GET").WithKind(trace.SpanKindClient).
+		Child("GET /v1/packages/{id:[0-9]+}").WithKind(trace.SpanKindServer).
+		Child("packages.PackageService/GetPackage").WithKind(trace.SpanKindClient).
+		Child("packages.PackageService/GetPackage").WithKind(trace.SpanKindServer).
+		Child("getPackage").WithAttr(attribute.String("package.id", "123")))
+}
@@ -0,0 +1,16 @@
+//go:build pprof
+
+package main
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+)
+
+// Serves the profiles under /debug/pprof/, with samples labelled by trace.
+// The endpoints are unauthenticated, so only builds made with -tags pprof
+// include them.
+func registerPprof(router *mux.Router) {
+	router.Use(telemetry.ProfilingLabelsMiddleware)
+	router.PathPrefix("/debug/pprof/").Handler(telemetry.PprofHandler())
+}
@@ -0,0 +1,8 @@
+//go:build !pprof
+
+package main
+
+import "github.com/gorilla/mux"
+
+// Profiling is only served by builds made with -tags pprof.
+func registerPprof(*mux.Router) {}
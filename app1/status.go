@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+)
+
+// Sections of the status page, each rendered within its own span.
+var statusTemplates = template.Must(template.New("status").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html><head><title>{{.Service}} status</title></head><body>
+<h1>{{.Service}}</h1>{{end}}
+{{define "provider"}}<h2>Export</h2>
+<table>
+<tr><td>Collector</td><td>{{.Collector}}</td></tr>
+<tr><td>Connected</td><td>{{if .Connected}}yes{{else}}no{{end}}</td></tr>
+<tr><td>Exporter</td><td>{{.Exporter}}</td></tr>
+</table>{{end}}
+{{define "sampler"}}<h2>Sampling</h2>
+<p><code>{{.Sampler}}</code></p>{{end}}
+{{define "errors"}}<h2>Errors</h2>
+<p>{{.ErrorSpans}} error spans, {{.PipelineErrors}} pipeline errors</p>
+<ul>{{range .RecentErrors}}<li>{{.Time.Format "15:04:05"}} {{.Message}}</li>{{end}}</ul>{{end}}
+{{define "footer"}}</body></html>{{end}}
+`))
+
+// Renders the state of the telemetry pipeline as an HTML page.
+func statusPage(w http.ResponseWriter, r *http.Request) {
+	status := telemetry.CurrentStatus()
+	var page bytes.Buffer
+	for _, name := range []string{"header", "provider", "sampler", "errors", "footer"} {
+		if err := telemetry.ExecuteTemplate(r.Context(), &page, statusTemplates, name, status); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = page.WriteTo(w)
+}
@@ -2,129 +2,526 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
-	"net/http/httptrace"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/sosalejandro/otel-example/commons/envconfig"
+	"github.com/sosalejandro/otel-example/commons/packagesvc"
+	"github.com/sosalejandro/otel-example/commons/semconvx"
 	"github.com/sosalejandro/otel-example/commons/telemetry"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
-	"google.golang.org/grpc"
+	"golang.org/x/net/websocket"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const serverName = "otel-example-client"
 
-func initTracer() (*sdktrace.TracerProvider, error) {
-	ctx := context.Background()
-
-	res, _ := resource.New(ctx,
-		resource.WithFromEnv(),
-		resource.WithProcess(),
-		resource.WithTelemetrySDK(),
-		resource.WithHost(),
-		resource.WithAttributes(
-			// the service name used to display traces in backends
-			semconv.ServiceNameKey.String(serverName),
-			attribute.String("environment", os.Getenv("GO_ENV")),
-		),
-	)
+// Subcommand of the client, each invocation traced under a root span named
+// after the command.
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
 
-	otelAgentAddr, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
+var commands = map[string]command{
+	"send":      {"send a package request over HTTP, or fan out to several servers", send},
+	"query":     {"look a package up through the gRPC package service", query},
+	"subscribe": {"follow the status changes of a package over WebSocket", subscribe},
+	"loadtest":  {"send requests to a server at a target rate from concurrent workers", loadTest},
+	"download":  {"stream the documents of a package, reporting the transfer progress", download},
+}
+
+func main() {
+	// the standard OTEL_* variables, e.g. OTEL_BSP_SCHEDULE_DELAY=500
+	envOpts, err := envconfig.Options()
+	if len(os.Args) > 1 && os.Args[1] == "-check-config" {
+		checkTelemetryConfig(envOpts, err)
+	}
+	telemetry.HandleErr(err, "Invalid OTEL_* environment")
+	shutdown, flushTelemetry := telemetry.InitProviderWithFlush(serverName, envOpts...)
+	defer shutdown()
+	// log calls become log records too
+	defer telemetry.RedirectStdLog()()
+	// a crash still exports the telemetry leading to it
+	defer telemetry.RecoverAndFlush(context.Background())
 
+	// send stays the default so plain flags keep working
+	name, args := "send", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
 	if !ok {
-		otelAgentAddr = "0.0.0.0:4317"
+		usage()
+		os.Exit(2)
 	}
 
-	traceClient := otlptracegrpc.NewClient(
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithEndpoint(otelAgentAddr),
-		otlptracegrpc.WithDialOption(grpc.WithBlock()))
-	traceExp, err := otlptrace.New(ctx, traceClient)
-	telemetry.HandleErr(err, "Failed to create the collector trace exporter")
+	bag, _ := baggage.Parse("destination=newyork,transportation=truck")
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+	ctx = telemetry.ContextWithRequestID(ctx, uuid.NewString())
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExp)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(telemetry.GetSampler()),
-		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
-	)
+	ctx, span := telemetry.Tracer(serverName, "").Start(ctx, "cli "+name,
+		trace.WithAttributes(
+			attribute.String("cli.command", name),
+			attribute.StringSlice("cli.args", args),
+		))
+	err = cmd.run(ctx, args)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, name+" failed")
+	}
+	span.End()
 
-	// set global propagator to tracecontext (the default is no-op).
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
-	otel.SetTracerProvider(tracerProvider)
-	return tracerProvider, nil
+	flush(flushTelemetry)
+	if err != nil {
+		shutdown()
+		log.Fatalf("Error executing %s: %v", name, err)
+	}
+	fmt.Printf("Inspect traces on jaeger\n")
 }
 
-func main() {
-	tp, err := initTracer()
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nor %s -check-config to validate the telemetry config\n", os.Args[0])
+}
+
+// Reports every misconfigured telemetry setting and exits, with a failure
+// status when there is any.
+func checkTelemetryConfig(opts []telemetry.Option, envErr error) {
+	if envErr != nil {
+		fmt.Fprintln(os.Stderr, envErr)
+		os.Exit(1)
+	}
+	if err := telemetry.ValidateConfig(telemetry.NewConfig(serverName, opts...)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("telemetry config is valid")
+	os.Exit(0)
+}
+
+// Parses the command flags and records their values on the command span.
+func parseFlags(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	span := trace.SpanFromContext(ctx)
+	fs.VisitAll(func(f *flag.Flag) {
+		span.SetAttributes(attribute.String("cli.flag."+f.Name, f.Value.String()))
+	})
+	return nil
+}
+
+// Adds the fault to the baggage of ctx, for the server to inject.
+func withFault(ctx context.Context, fault string) (context.Context, error) {
+	if _, err := telemetry.ParseFault(fault); err != nil {
+		return ctx, err
+	}
+	member, err := baggage.NewMemberRaw(telemetry.FaultBaggageKey, fault)
 	if err != nil {
-		log.Fatal(err)
+		return ctx, err
 	}
-	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			telemetry.HandleErr(err, "Error shutting down tracer provider")
-		}
-	}()
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, err
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
 
-	url := flag.String("server", "http://localhost:8080/packages/123", "server url")
-	flag.Parse()
+// Creates the traced HTTP client shared by the commands.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport
+	if os.Getenv("CAPTURE_HTTP_BODIES") == "true" {
+		transport = telemetry.NewBodyCaptureTransport(transport)
+	}
 
-	client := http.Client{
-		Transport: otelhttp.NewTransport(
-			http.DefaultTransport,
-			otelhttp.WithClientTrace(func(ctx context.Context) *httptrace.ClientTrace {
-				return otelhttptrace.NewClientTrace(ctx)
-			}),
-		),
+	opts := []telemetry.ClientOption{telemetry.WithConnectionSpans()}
+	// e.g. HTTP_CLIENT_ATTEMPTS=3 against flaky servers
+	if attempts, err := strconv.Atoi(os.Getenv("HTTP_CLIENT_ATTEMPTS")); err == nil && attempts > 1 {
+		opts = append(opts, telemetry.WithRetry(attempts, 100*time.Millisecond))
 	}
+	// e.g. HTTP_CLIENT_HEDGE_AFTER=200ms against slow replicas
+	if delay, err := time.ParseDuration(os.Getenv("HTTP_CLIENT_HEDGE_AFTER")); err == nil && delay > 0 {
+		opts = append(opts, telemetry.WithHedging(delay))
+	}
+	// e.g. HTTP_CLIENT_SHADOW_URL=http://localhost:9080 HTTP_CLIENT_SHADOW_RATIO=0.1
+	// to try a new server version on a tenth of the traffic
+	if shadow := os.Getenv("HTTP_CLIENT_SHADOW_URL"); shadow != "" {
+		ratio, err := strconv.ParseFloat(os.Getenv("HTTP_CLIENT_SHADOW_RATIO"), 64)
+		if err != nil {
+			ratio = 1
+		}
+		opts = append(opts, telemetry.WithShadow(shadow, ratio))
+	}
+	return telemetry.NewHTTPClient(transport, opts...)
+}
 
-	bag, _ := baggage.Parse("destination=newyork,transportation=truck")
-	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+// Sends one package request, or fans out to several servers.
+func send(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	url := fs.String("server", "http://localhost:8080/packages/123", "server url")
+	fanout := fs.String("fanout", "", "comma separated server urls to fan out requests to")
+	batchSize := fs.Int("batch", 2, "number of concurrent requests per fan out batch")
+	fault := fs.String("fault", "", "fault for servers running with FAULT_INJECTION=true to inject, e.g. delay:200ms, error:503 or abort")
+	if err := parseFlags(ctx, fs, args); err != nil {
+		return err
+	}
+	if *batchSize < 1 {
+		return fmt.Errorf("invalid -batch %d: expected at least one request per batch", *batchSize)
+	}
+	if *fault != "" {
+		var err error
+		if ctx, err = withFault(ctx, *fault); err != nil {
+			return err
+		}
+	}
 
-	var body []byte
+	client := newHTTPClient()
+	if *fanout != "" {
+		if err := fanOut(ctx, client, strings.Split(*fanout, ","), *batchSize); err != nil {
+			return err
+		}
+		fmt.Printf("Fan out completed\n\n")
+		return nil
+	}
 
-	tr := otel.Tracer(serverName)
-	err = func(ctx context.Context) error {
-		ctx, span := tr.Start(
+	var body []byte
+	err := func(ctx context.Context) error {
+		ctx, span := telemetry.Tracer(serverName, "").Start(
 			ctx,
 			"Otel propagation example: sending package from boston",
-			trace.WithAttributes(semconv.PeerService("otel-example-server")))
+			trace.WithAttributes(semconvx.PeerService("otel-example-server")))
 		defer span.End()
 		req, _ := http.NewRequestWithContext(ctx, "GET", *url, nil)
+		telemetry.SetRequestIDHeader(req)
 
 		span.AddEvent("Sending request...")
 		res, err := client.Do(req)
 		if err != nil {
-			panic(err)
+			return err
 		}
+		span.SetAttributes(telemetry.RequestIDKey.String(res.Header.Get(telemetry.RequestIDHeader)))
 		body, err = io.ReadAll(res.Body)
 		span.AddEvent("Request received")
 		_ = res.Body.Close()
 
 		return err
 	}(ctx)
-
 	if err != nil {
-		telemetry.HandleErr(err, "Error executing handler request")
+		return err
 	}
 
 	fmt.Printf("Response Received: %s\n\n\n", body)
-	fmt.Printf("Waiting for few seconds to export spans ...\n\n")
-	time.Sleep(10 * time.Second)
-	fmt.Printf("Inspect traces on jaeger\n")
+	return nil
+}
+
+// Looks a package up through the gRPC package service.
+func query(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8081", "package service gRPC address")
+	timeout := fs.Duration("timeout", time.Second, "deadline of the gRPC call")
+	id := fs.String("id", "123", "package id")
+	if err := parseFlags(ctx, fs, args); err != nil {
+		return err
+	}
+
+	pr, err := getPackageGRPC(ctx, *addr, *id, *timeout)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("gRPC Response Received: %s\n\n\n", pr)
+	return nil
+}
+
+// Subscribes to the status changes of a package. The handshake carries the
+// command trace context, and each received update gets a span linked to the
+// server span which sent it.
+func subscribe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("subscribe", flag.ExitOnError)
+	url := fs.String("server", "ws://localhost:8080"+packagesvc.UpdatesPath, "updates endpoint url")
+	origin := fs.String("origin", "http://localhost/", "origin sent in the handshake")
+	id := fs.String("id", "123", "package id")
+	if err := parseFlags(ctx, fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := websocket.NewConfig(*url, *origin)
+	if err != nil {
+		return err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(cfg.Header))
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	tracer := telemetry.Tracer(serverName, "")
+	_, span, carrier := packagesvc.StartSendSpan(ctx, tracer, *id)
+	err = websocket.JSON.Send(ws, packagesvc.Subscription{ID: *id, Trace: carrier})
+	span.End()
+	if err != nil {
+		return err
+	}
+
+	for {
+		var u packagesvc.Update
+		if err := websocket.JSON.Receive(ws, &u); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		_, span := packagesvc.StartReceiveSpan(ctx, tracer, u.ID, u.Trace)
+		span.SetAttributes(attribute.String("package.status", u.Status))
+		fmt.Printf("Package %s is %s\n", u.ID, u.Status)
+		span.End()
+	}
+}
+
+// Streams the documents of a package. The command span gets progress events
+// along the way and a summary of the transfer.
+func download(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	url := fs.String("server", "http://localhost:8080/packages/123/download", "download url")
+	size := fs.Int("size", 16<<20, "number of bytes to download")
+	if err := parseFlags(ctx, fs, args); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s?size=%d", *url, *size), nil)
+	if err != nil {
+		return err
+	}
+	res, err := newHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", res.Status)
+	}
+
+	in := telemetry.NewTransferReader(ctx, res.Body, 250*time.Millisecond)
+	defer in.Finish()
+	n, err := io.Copy(io.Discard, in)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Downloaded %d bytes\n", n)
+	return nil
+}
+
+// Sends n requests at a target rate from concurrent workers. Each request is
+// traced and tagged with the worker ID, and client-side latencies are
+// recorded in the loadtest.request.duration histogram.
+func loadTest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	url := fs.String("server", "http://localhost:8080/packages/123", "server url")
+	n := fs.Int("n", 100, "number of requests to send")
+	concurrency := fs.Int("concurrency", 4, "number of workers sending requests")
+	rps := fs.Float64("rps", 10, "target requests per second, unlimited when 0")
+	if err := parseFlags(ctx, fs, args); err != nil {
+		return err
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("invalid -concurrency %d: expected at least one worker", *concurrency)
+	}
+	// interval between two requests, unpaced when 0
+	var interval time.Duration
+	if *rps != 0 {
+		interval = time.Duration(float64(time.Second) / *rps)
+		if math.IsNaN(*rps) || interval <= 0 {
+			return fmt.Errorf("invalid -rps %g: expected a rate between 0 and 1e9", *rps)
+		}
+	}
+
+	latency, err := telemetry.Meter(serverName, "").Float64Histogram(
+		"loadtest.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Client-side duration of load test requests"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// paces the requests handed to the workers
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for i := 0; i < *n; i++ {
+			if tick != nil {
+				<-tick
+			}
+			jobs <- i
+		}
+	}()
+
+	client := newHTTPClient()
+	tr := telemetry.Tracer(serverName, "")
+	var failed atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := range jobs {
+				rctx, span := tr.Start(ctx, "loadtest request", trace.WithAttributes(
+					attribute.Int("loadtest.worker_id", worker),
+					attribute.Int("loadtest.request_index", i),
+				))
+				start := time.Now()
+				err := get(rctx, client, *url)
+				outcome := "success"
+				if err != nil {
+					outcome = "error"
+					failed.Add(1)
+					span.RecordError(err)
+					span.SetStatus(codes.Error, "request failed")
+				}
+				latency.Record(rctx, time.Since(start).Seconds(), metric.WithAttributes(
+					attribute.Int("loadtest.worker_id", worker),
+					attribute.String("loadtest.outcome", outcome),
+				))
+				span.End()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	fmt.Printf("Sent %d requests, %d failed\n\n", *n, failed.Load())
+	if failed.Load() > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed.Load(), *n)
+	}
+	return nil
+}
+
+// Delivers buffered spans and the last metric deltas before the client
+// exits.
+func flush(flushTelemetry func(context.Context) error) {
+	fmt.Printf("Exporting spans ...\n\n")
+	if err := flushTelemetry(context.Background()); err != nil {
+		log.Printf("Error flushing telemetry: %v", err)
+	}
+}
+
+// Fans out requests to several endpoints under one parent span. Requests are
+// sent in concurrent batches, each batch span linked to the previous one.
+func fanOut(ctx context.Context, client *http.Client, urls []string, batchSize int) error {
+	tr := telemetry.Tracer(serverName, "")
+	ctx, span := tr.Start(ctx, "Otel propagation example: fan out",
+		trace.WithAttributes(attribute.Int("fanout.requests", len(urls))))
+	defer span.End()
+
+	var prev trace.Link
+	var errs []error
+	for i := 0; i < len(urls); i += batchSize {
+		batch := urls[i:min(i+batchSize, len(urls))]
+		bctx, bspan := telemetry.StartLinkedSpan(ctx, tr, fmt.Sprintf("fan out batch %d", i/batchSize), prev)
+		bspan.SetAttributes(attribute.Int("fanout.batch_size", len(batch)))
+
+		var wg sync.WaitGroup
+		errCh := make(chan error, len(batch))
+		for _, u := range batch {
+			wg.Add(1)
+			go func(u string) {
+				defer wg.Done()
+				if err := get(bctx, client, u); err != nil {
+					errCh <- err
+				}
+			}(u)
+		}
+		wg.Wait()
+		close(errCh)
+		for err := range errCh {
+			bspan.RecordError(err)
+			errs = append(errs, err)
+		}
+		bspan.End()
+
+		prev = trace.LinkFromContext(bctx, attribute.String("link.type", "previous_batch"))
+	}
+	return errors.Join(errs...)
+}
+
+func get(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(io.Discard, res.Body)
+	return err
+}
+
+// Looks a package up through the gRPC package service, bounding the call
+// with a deadline which is surfaced on the client span.
+func getPackageGRPC(ctx context.Context, addr, id string, timeout time.Duration) (string, error) {
+	client, err := packagesvc.NewClient(addr)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	ctx, span := telemetry.Tracer(serverName, "").Start(
+		ctx,
+		"Otel propagation example: gRPC package lookup",
+		trace.WithAttributes(
+			semconvx.PeerService("otel-example-server"),
+			attribute.Int64("rpc.deadline.timeout_ms", timeout.Milliseconds()),
+		))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res, err := client.GetPackage(ctx, &packagesvc.GetPackageRequest{Id: id})
+	if err != nil {
+		span.SetAttributes(attribute.Bool("rpc.deadline.exceeded", status.Code(err) == grpccodes.DeadlineExceeded))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "gRPC package lookup failed")
+		return "", err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		span.SetAttributes(attribute.Int64("rpc.deadline.remaining_ms", time.Until(deadline).Milliseconds()))
+	}
+	return res.GetStatus(), nil
 }
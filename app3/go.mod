@@ -0,0 +1,3 @@
+module github.com/sosalejandro/otel-example-go/app3
+
+go 1.22
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sosalejandro/otel-example/commons/envconfig"
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"github.com/sosalejandro/otel-example/commons/telemetry/zapotel"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const serverName = "otel-example-warehouse"
+
+// Structured logger of the warehouse, correlated with the request spans
+// through zapotel.
+var logger = zap.Must(zap.NewProduction())
+
+// Stock level of a package in the warehouse.
+type stock struct {
+	ID        string `json:"id"`
+	Available bool   `json:"available"`
+	Location  string `json:"location"`
+}
+
+func main() {
+	// the standard OTEL_* variables, e.g. OTEL_BSP_SCHEDULE_DELAY=500
+	envOpts, err := envconfig.Options()
+	telemetry.HandleErr(err, "Invalid OTEL_* environment")
+	otelShutdown := telemetry.InitProvider(serverName, envOpts...)
+	defer otelShutdown()
+	defer func() { _ = logger.Sync() }()
+
+	router := mux.NewRouter()
+	router.Use(
+		otelmux.Middleware(
+			serverName,
+			otelmux.WithSpanNameFormatter(func(routeName string, r *http.Request) string {
+				return telemetry.SpanNamer{}.HTTPServer(r.Method, routeName)
+			})),
+		telemetry.LatencyMiddleware(serverName),
+		telemetry.RequestIDMiddleware,
+	)
+
+	router.HandleFunc("/stock/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		s := checkStock(r.Context(), id)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s)
+	})
+
+	addr, ok := os.LookupEnv("WAREHOUSE_ADDR")
+	if !ok {
+		addr = ":8082"
+	}
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      router,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 1 * time.Second,
+		IdleTimeout:  15 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("Server error: %v", err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+}
+
+func checkStock(ctx context.Context, id string) stock {
+	ctx, span := telemetry.Tracer(serverName, "").Start(ctx, "checkStock")
+	defer span.End()
+
+	s := stock{ID: id, Available: id == "123", Location: "boston"}
+	span.SetAttributes(
+		attribute.String("package", id),
+		attribute.Bool("stock.available", s.Available),
+	)
+	zapotel.Logger(ctx, logger).Info("stock checked",
+		zap.String("package", id),
+		zap.Bool("available", s.Available),
+	)
+	return s
+}
@@ -0,0 +1,3 @@
+module github.com/sosalejandro/otel-example-go/app4
+
+go 1.22
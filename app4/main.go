@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
+	"github.com/sosalejandro/otel-example/commons/envconfig"
+	"github.com/sosalejandro/otel-example/commons/semconvx"
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"github.com/sosalejandro/otel-example/commons/telemetry/zerootel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serverName = "otel-example-shipping"
+	topic      = "package.shipped"
+)
+
+// Structured logger of the shipping service, correlated with the message
+// spans through zerootel.
+var logger = zerolog.New(os.Stderr).With().Timestamp().Logger().Hook(zerootel.Hook{})
+
+func main() {
+	mode := flag.String("mode", "producer", "producer or consumer")
+	id := flag.String("id", "123", "id of the shipped package")
+	brokers := flag.String("brokers", "localhost:9092", "comma separated list of kafka brokers")
+	flag.Parse()
+
+	// the standard OTEL_* variables, e.g. OTEL_BSP_SCHEDULE_DELAY=500
+	envOpts, err := envconfig.Options()
+	telemetry.HandleErr(err, "Invalid OTEL_* environment")
+	otelShutdown := telemetry.InitProvider(serverName, envOpts...)
+	defer otelShutdown()
+	telemetry.DefineEvent("package.shipped", telemetry.EventConfig{
+		Description:       "A package left for its destination.",
+		Counter:           "packages.shipped",
+		CounterAttributes: []string{"destination"},
+	})
+
+	addrs := strings.Split(*brokers, ",")
+
+	switch *mode {
+	case "producer":
+		bag, _ := baggage.Parse("destination=newyork,transportation=truck")
+		ctx := baggage.ContextWithBaggage(context.Background(), bag)
+		err = publishShipped(ctx, addrs, *id)
+	case "consumer":
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		err = consumeShipped(ctx, addrs)
+	default:
+		err = fmt.Errorf("unknown mode %q", *mode)
+	}
+	if err != nil {
+		log.Printf("Shipping %s error: %v", *mode, err)
+	}
+}
+
+// Publishes a "package shipped" event carrying the trace context in its headers.
+func publishShipped(ctx context.Context, brokers []string, id string) error {
+	ctx, span := telemetry.Tracer(serverName, "").Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconvx.MessagingAttrs("kafka", topic, semconvx.MessagingPublish)...),
+		trace.WithAttributes(semconvx.KafkaMessageAttrs(id, -1, -1)...))
+	defer span.End()
+
+	w := &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		AllowAutoTopicCreation: true,
+	}
+	defer w.Close()
+
+	msg := kafka.Message{
+		Key:     []byte(id),
+		Value:   []byte(fmt.Sprintf("package %s shipped", id)),
+		Headers: telemetry.InjectKafkaHeaders(ctx, nil),
+	}
+	span.SetAttributes(semconvx.MessageBodySize(len(msg.Value)))
+
+	if err := w.WriteMessages(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to publish message")
+		return err
+	}
+	telemetry.Event(ctx, "package.shipped",
+		attribute.String("package.id", id),
+		attribute.String("destination", baggage.FromContext(ctx).Member("destination").Value()),
+	)
+	return nil
+}
+
+// Consumes "package shipped" events, continuing the producer's trace for each message.
+func consumeShipped(ctx context.Context, brokers []string) error {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   topic,
+		GroupID: serverName,
+	})
+	defer r.Close()
+
+	for {
+		msg, err := r.ReadMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+		processShipped(ctx, msg)
+	}
+}
+
+func processShipped(ctx context.Context, msg kafka.Message) {
+	ctx = telemetry.ExtractKafkaHeaders(ctx, msg.Headers)
+	ctx, span := telemetry.Tracer(serverName, "").Start(ctx, topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(semconvx.MessagingAttrs("kafka", msg.Topic, semconvx.MessagingProcess)...),
+		trace.WithAttributes(semconvx.KafkaMessageAttrs(string(msg.Key), msg.Partition, msg.Offset)...))
+	defer span.End()
+
+	destination := baggage.FromContext(ctx).Member("destination").Value()
+	span.AddEvent("Processing shipped package", trace.WithAttributes(attribute.String("destination", destination)))
+	logger.Info().Ctx(ctx).Bytes("value", msg.Value).Msg("received shipped package")
+}
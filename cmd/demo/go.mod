@@ -0,0 +1,3 @@
+module github.com/sosalejandro/otel-example-go/cmd/demo
+
+go 1.22
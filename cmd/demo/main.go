@@ -0,0 +1,230 @@
+// Command demo runs the whole example with one command: it starts the
+// collector and the tracing backends with docker compose, builds and
+// launches the server, the warehouse and the client, sends a scripted
+// scenario and prints where to find its trace.
+//
+//	go run ./cmd/demo
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/envconfig"
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const serverName = "otel-example-demo"
+
+// Containers of the demo, the collector pulling in Jaeger, Zipkin and Tempo.
+var services = []string{"otel-collector", "grafana", "prometheus"}
+
+// Endpoints polled until they answer before the scenario runs.
+var readiness = []struct{ name, url string }{
+	{"collector", "http://localhost:13133/"},
+	{"jaeger", "http://localhost:16686/"},
+	{"tempo", "http://localhost:3200/ready"},
+	{"grafana", "http://localhost:3000/api/health"},
+	{"server", "http://localhost:8080/status"},
+	{"warehouse", "http://localhost:8082/stock/123"},
+}
+
+// Binaries built from the workspace, by package directory.
+var binaries = map[string]string{
+	"app1": "server_app",
+	"app2": "client_app",
+	"app3": "warehouse_app",
+}
+
+func main() {
+	root := flag.String("root", ".", "root of the repository")
+	keep := flag.Bool("keep", false, "leave the containers running on exit")
+	timeout := flag.Duration("timeout", 3*time.Minute, "how long to wait for the stack to be ready")
+	flag.Parse()
+
+	if err := run(*root, *keep, *timeout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(root string, keep bool, timeout time.Duration) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	bin, err := os.MkdirTemp("", "otel-demo")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(bin)
+
+	step("Starting the collector and the backends")
+	if err := command(root, "docker", append([]string{"compose", "up", "-d"}, services...)...).Run(); err != nil {
+		return fmt.Errorf("docker compose: %w", err)
+	}
+	if !keep {
+		defer func() {
+			step("Stopping the containers")
+			_ = command(root, "docker", "compose", "down").Run()
+		}()
+	}
+
+	step("Building the apps")
+	for dir, name := range binaries {
+		if err := command(root, "go", "build", "-o", filepath.Join(bin, name), "./"+dir).Run(); err != nil {
+			return fmt.Errorf("build %s: %w", dir, err)
+		}
+	}
+
+	step("Launching the server and the warehouse, logging to " + bin)
+	for _, name := range []string{"server_app", "warehouse_app"} {
+		logs, err := os.Create(filepath.Join(bin, name+".log"))
+		if err != nil {
+			return err
+		}
+		defer logs.Close()
+		cmd := command(root, filepath.Join(bin, name))
+		cmd.Stdout, cmd.Stderr = logs, logs
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("start %s: %w", name, err)
+		}
+		defer func() {
+			_ = cmd.Process.Signal(os.Interrupt)
+			_ = cmd.Wait()
+		}()
+	}
+
+	step("Waiting for the stack")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, r := range readiness {
+		if err := waitReady(ctx, r.url); err != nil {
+			return fmt.Errorf("%s not ready: %w", r.name, err)
+		}
+		fmt.Printf("  %s is ready\n", r.name)
+	}
+
+	step("Running the scenario")
+	traceID, err := scenario(root, bin)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nScenario trace: http://localhost:16686/trace/%s\n", traceID)
+	fmt.Printf("Client traces:  http://localhost:16686/search?service=otel-example-client\n")
+	fmt.Printf("Tempo:          http://localhost:3000/explore\n")
+	fmt.Println("\nPress Ctrl+C to stop the demo.")
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+	return nil
+}
+
+// Sends the scripted traffic under one root span, then runs the client
+// commands, returning the id of the scenario trace.
+func scenario(root, bin string) (string, error) {
+	// the standard OTEL_* variables, e.g. OTEL_BSP_SCHEDULE_DELAY=500
+	envOpts, err := envconfig.Options()
+	telemetry.HandleErr(err, "Invalid OTEL_* environment")
+	shutdown, flush := telemetry.InitProviderWithFlush(serverName, envOpts...)
+	defer shutdown()
+
+	bag, _ := baggage.Parse("destination=newyork,transportation=truck")
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+	ctx, span := telemetry.Tracer(serverName, "").Start(ctx, "demo scenario")
+	client := telemetry.NewPeerClient("server", nil)
+
+	steps := []struct{ name, method, path, body string }{
+		{"found package", http.MethodGet, "/packages/123", ""},
+		{"unknown package", http.MethodGet, "/packages/9", ""},
+		{"graphql lookup", http.MethodPost, "/graphql", `{"query":"{ package(id: \"123\") { id status inStock deliveryHours } }"}`},
+	}
+	for _, s := range steps {
+		stepCtx, stepSpan := telemetry.Tracer(serverName, "").Start(ctx, s.name)
+		status, err := call(stepCtx, client, s.method, s.path, s.body)
+		stepSpan.SetAttributes(attribute.Int("demo.status", status))
+		if err != nil {
+			stepSpan.RecordError(err)
+			stepSpan.SetStatus(codes.Error, err.Error())
+		}
+		stepSpan.End()
+		fmt.Printf("  %-16s %s %s -> %d\n", s.name, s.method, s.path, status)
+	}
+	span.End()
+
+	for _, args := range [][]string{{"send"}, {"query"}} {
+		fmt.Printf("  client %s\n", strings.Join(args, " "))
+		if err := command(root, filepath.Join(bin, "client_app"), args...).Run(); err != nil {
+			return "", fmt.Errorf("client %s: %w", args[0], err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := flush(ctx); err != nil {
+		log.Printf("Failed to flush the scenario trace: %v", err)
+	}
+	return span.SpanContext().TraceID().String(), nil
+}
+
+func call(ctx context.Context, client *http.Client, method, path, body string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost:8080"+path, strings.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// Polls url until it answers with a 2xx status.
+func waitReady(ctx context.Context, url string) error {
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Command run from dir with the output of the demo and the collector of the
+// compose stack.
+func command(dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "OTEL_EXPORTER_OTLP_ENDPOINT=localhost:4317")
+	return cmd
+}
+
+func step(msg string) {
+	fmt.Printf("==> %s\n", msg)
+}
@@ -0,0 +1,132 @@
+// Command otelgen scaffolds services wired to the commons telemetry package,
+// so new services start with the same providers, middlewares and manifest as
+// the examples of this repository.
+//
+//	otelgen new service -name orders -dir orders -exporter otlp -backend jaeger
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// Values of the templates.
+type service struct {
+	Name      string
+	Module    string
+	Dir       string
+	Root      string
+	Port      string
+	EnvPrefix string
+	Exporter  string
+	Backend   string
+}
+
+// Files generated per backend, named after their template.
+var files = []string{
+	"main.go",
+	"handler.go",
+	"go.mod",
+	"Dockerfile",
+	"docker-compose.yml",
+	"otel-collector-config.yml",
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 3 || os.Args[1] != "new" || os.Args[2] != "service" {
+		log.Fatal("usage: otelgen new service -name <name> [-dir <dir>] [-module <path>] [-port <port>] [-exporter otlp|console] [-backend jaeger|tempo|zipkin]")
+	}
+
+	fs := flag.NewFlagSet("otelgen new service", flag.ExitOnError)
+	name := fs.String("name", "", "name of the service, reported as service.name")
+	dir := fs.String("dir", "", "directory of the service, relative to the workspace root, the name by default")
+	module := fs.String("module", "", "module path, github.com/sosalejandro/otel-example-go/<dir> by default")
+	port := fs.String("port", "8080", "port the service listens on")
+	exporter := fs.String("exporter", "otlp", "span exporter, otlp or console")
+	backend := fs.String("backend", "jaeger", "tracing backend behind the collector, jaeger, tempo or zipkin")
+	force := fs.Bool("force", false, "overwrite existing files")
+	_ = fs.Parse(os.Args[3:])
+
+	svc, err := newService(*name, *dir, *module, *port, *exporter, *backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := generate(svc, *force); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Created %s in %s. Add it to the workspace and run it:\n\n", svc.Name, svc.Dir)
+	fmt.Printf("\tgo work use ./%s\n\tgo run ./%s\n\tdocker compose -f %s/docker-compose.yml up\n", svc.Dir, svc.Dir, svc.Dir)
+}
+
+func newService(name, dir, module, port, exporter, backend string) (service, error) {
+	if name == "" {
+		return service{}, fmt.Errorf("-name is required")
+	}
+	if exporter != "otlp" && exporter != "console" {
+		return service{}, fmt.Errorf("unknown exporter %q, expected otlp or console", exporter)
+	}
+	if backend != "jaeger" && backend != "tempo" && backend != "zipkin" {
+		return service{}, fmt.Errorf("unknown backend %q, expected jaeger, tempo or zipkin", backend)
+	}
+	if dir == "" {
+		dir = name
+	}
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if module == "" {
+		module = "github.com/sosalejandro/otel-example-go/" + dir
+	}
+	return service{
+		Name:      name,
+		Module:    module,
+		Dir:       dir,
+		Root:      strings.Repeat("../", strings.Count(dir, "/")+1),
+		Port:      port,
+		EnvPrefix: strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name)),
+		Exporter:  exporter,
+		Backend:   backend,
+	}, nil
+}
+
+// Renders the templates of svc into its directory.
+func generate(svc service, force bool) error {
+	names := files
+	if svc.Backend == "tempo" {
+		names = append(names, "tempo.yml")
+	}
+	tmpl, err := template.ParseFS(templates, "templates/*.tmpl")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(svc.Dir, 0o755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		path := filepath.Join(svc.Dir, name)
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if !force {
+			flags |= os.O_EXCL
+		}
+		f, err := os.OpenFile(path, flags, 0o644)
+		if err != nil {
+			return err
+		}
+		err = tmpl.ExecuteTemplate(f, name+".tmpl", svc)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("render %s: %w", path, err)
+		}
+	}
+	return nil
+}
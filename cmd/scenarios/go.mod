@@ -0,0 +1,3 @@
+module github.com/sosalejandro/otel-example-go/cmd/scenarios
+
+go 1.22
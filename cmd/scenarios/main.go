@@ -0,0 +1,287 @@
+// Command scenarios replays named traffic patterns against the server and
+// checks the shape of the traces they produce, as the collector outputs
+// them, doubling as regression tests of the instrumentation. It receives the
+// traces over OTLP/gRPC, so the collector must forward to it, e.g.
+//
+//	exporters:
+//	  otlp/scenarios:
+//	    endpoint: host.docker.internal:4319
+//	    tls:
+//	      insecure: true
+//
+// The server must run with FAULT_INJECTION=true for the retry-storm scenario.
+// The scenarios run one after the other and the command fails when a trace
+// does not match:
+//
+//	go run ./cmd/scenarios -run success,timeout
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/envconfig"
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"github.com/sosalejandro/otel-example/commons/teletest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serverName = "otel-example-scenarios"
+
+// Traffic pattern and the trace it must produce.
+type scenario struct {
+	name string
+	run  func(ctx context.Context, target string) error
+	// expected trace below the "scenario <name>" root span
+	expect func(root *teletest.SpanMatcher)
+	// further checks of the spans of the trace
+	check func(spans tracetest.SpanStubs) error
+}
+
+const packageRoute = "GET /packages/{id:[0-9]+}"
+
+var scenarios = []scenario{
+	{
+		name: "success",
+		run: func(ctx context.Context, target string) error {
+			return get(ctx, client, target+"/packages/123", http.StatusOK)
+		},
+		expect: func(root *teletest.SpanMatcher) {
+			root.Child("GET server").WithKind(trace.SpanKindClient).
+				Child(packageRoute).WithKind(trace.SpanKindServer).
+				Child("getPackage").WithAttr(attribute.String("package.id", "123"))
+		},
+	},
+	{
+		name: "not-found",
+		run: func(ctx context.Context, target string) error {
+			return get(ctx, client, target+"/packages/9", http.StatusOK)
+		},
+		expect: func(root *teletest.SpanMatcher) {
+			root.Child("GET server").
+				Child(packageRoute).
+				Child("getPackage").WithAttr(attribute.String("package.id", "9"))
+		},
+	},
+	{
+		name: "timeout",
+		run: func(ctx context.Context, target string) error {
+			ctx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+			defer cancel()
+			err := get(ctx, client, target+"/packages/123/download?size=1048576&rate=65536", http.StatusOK)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("expected the download to time out, got %v", err)
+			}
+			return nil
+		},
+		expect: func(root *teletest.SpanMatcher) {
+			root.Child("GET server").WithStatus(codes.Error).
+				Child(packageRoute + "/download").WithKind(trace.SpanKindServer)
+		},
+	},
+	{
+		// needs the server to run with FAULT_INJECTION=true
+		name: "retry-storm",
+		run: func(ctx context.Context, target string) error {
+			// a quarter of the attempts fail and are retried
+			ctx, err := withBaggage(ctx, telemetry.FaultBaggageKey, "error:503@0.25")
+			if err != nil {
+				return err
+			}
+			retrying := telemetry.NewPeerClient("server", nil, telemetry.WithRetry(6, 50*time.Millisecond))
+			var wg sync.WaitGroup
+			errs := make([]error, 20)
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = get(ctx, retrying, target+"/packages/123", http.StatusOK)
+				}()
+			}
+			wg.Wait()
+			return errors.Join(errs...)
+		},
+		expect: func(root *teletest.SpanMatcher) {
+			root.Child("GET server").Child(packageRoute).Child("getPackage")
+		},
+		check: func(spans tracetest.SpanStubs) error {
+			served, faults := 0, 0
+			for _, s := range spans {
+				if s.Name != packageRoute {
+					continue
+				}
+				served++
+				for _, e := range s.Events {
+					if e.Name == "fault.injected" {
+						faults++
+					}
+				}
+			}
+			if served-faults < 20 {
+				return fmt.Errorf("%d of the 20 requests were served", served-faults)
+			}
+			if faults == 0 {
+				return fmt.Errorf("no fault injected, is FAULT_INJECTION=true set on the server?")
+			}
+			return nil
+		},
+	},
+	{
+		name: "slow-downstream",
+		run: func(ctx context.Context, target string) error {
+			// 256 KiB at 256 KiB/s
+			return get(ctx, client, target+"/packages/123/download?size=262144&rate=262144", http.StatusOK)
+		},
+		expect: func(root *teletest.SpanMatcher) {
+			root.Child("GET server").Child(packageRoute + "/download")
+		},
+		check: func(spans tracetest.SpanStubs) error {
+			for _, s := range spans {
+				if s.Name == packageRoute+"/download" {
+					if d := s.EndTime.Sub(s.StartTime); d < 900*time.Millisecond {
+						return fmt.Errorf("slow download took %s", d)
+					}
+					return nil
+				}
+			}
+			return fmt.Errorf("no download span")
+		},
+	},
+}
+
+var client = telemetry.NewPeerClient("server", nil)
+
+func main() {
+	target := flag.String("target", "http://localhost:8080", "base URL of the server")
+	listen := flag.String("listen", ":4319", "address the collector forwards the traces to over OTLP/gRPC")
+	only := flag.String("run", "", "comma separated scenarios to run, all by default")
+	wait := flag.Duration("wait", 15*time.Second, "how long to wait for the traces of a scenario")
+	flag.Parse()
+
+	receiver, err := teletest.StartReceiver(*listen)
+	if err != nil {
+		log.Fatalf("Failed to start the OTLP receiver: %v", err)
+	}
+	defer receiver.Close()
+
+	// the standard OTEL_* variables, e.g. OTEL_BSP_SCHEDULE_DELAY=500
+	envOpts, err := envconfig.Options()
+	telemetry.HandleErr(err, "Invalid OTEL_* environment")
+	shutdown, flush := telemetry.InitProviderWithFlush(serverName, envOpts...)
+	defer shutdown()
+
+	failed := 0
+	for _, s := range scenarios {
+		if *only != "" && !contains(strings.Split(*only, ","), s.name) {
+			continue
+		}
+		start := time.Now()
+		if err := play(s, *target, receiver, flush, *wait); err != nil {
+			failed++
+			fmt.Printf("FAIL %s (%s)\n%v\n", s.name, time.Since(start).Round(time.Millisecond), err)
+			continue
+		}
+		fmt.Printf("ok   %s (%s)\n", s.name, time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		shutdown()
+		os.Exit(1)
+	}
+}
+
+// Runs s under its own root span and waits for its trace to match.
+func play(s scenario, target string, receiver *teletest.Receiver, flush func(context.Context) error, wait time.Duration) error {
+	ctx, span := telemetry.Tracer(serverName, "").Start(context.Background(), "scenario "+s.name)
+	err := s.run(ctx, target)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	if err != nil {
+		return err
+	}
+	// the trace may still arrive, the match decides
+	if err := flush(context.Background()); err != nil {
+		log.Printf("Failed to flush the scenario spans: %v", err)
+	}
+
+	expected := teletest.ExpectTrace()
+	s.expect(expected.Root("scenario " + s.name))
+	traceID := span.SpanContext().TraceID()
+	deadline := time.Now().Add(wait)
+	for {
+		spans := traceSpans(receiver.SpanStubs(), traceID)
+		err := expected.Match(spans)
+		if err == nil && s.check != nil {
+			err = s.check(spans)
+		}
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+func get(ctx context.Context, client *http.Client, url string, want int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("GET %s: status %d, expected %d", url, resp.StatusCode, want)
+	}
+	return nil
+}
+
+func traceSpans(spans tracetest.SpanStubs, id trace.TraceID) tracetest.SpanStubs {
+	var kept tracetest.SpanStubs
+	for _, s := range spans {
+		if s.SpanContext.TraceID() == id {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func withBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	member, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx, err
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, err
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if strings.TrimSpace(n) == name {
+			return true
+		}
+	}
+	return false
+}
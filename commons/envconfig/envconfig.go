@@ -0,0 +1,291 @@
+// Package envconfig reads the standard OTEL_* environment variables into
+// telemetry options the way the OpenTelemetry specification defines them:
+// malformed values are reported instead of being silently ignored, the
+// specific limits take precedence over the general ones, and the variables
+// this service does not apply are logged.
+package envconfig
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Variables applied by Parse.
+var parsed = []string{
+	"OTEL_SERVICE_NAME",
+	"OTEL_RESOURCE_ATTRIBUTES",
+	"OTEL_EXPORTER_OTLP_TIMEOUT",
+	"OTEL_EXPORTER_OTLP_HEADERS",
+	"OTEL_EXPORTER_OTLP_CERTIFICATE",
+	"OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE",
+	"OTEL_EXPORTER_OTLP_CLIENT_KEY",
+	"OTEL_METRIC_EXPORT_INTERVAL",
+	"OTEL_METRIC_EXPORT_TIMEOUT",
+	"OTEL_BSP_SCHEDULE_DELAY",
+	"OTEL_BSP_EXPORT_TIMEOUT",
+	"OTEL_BSP_MAX_QUEUE_SIZE",
+	"OTEL_BSP_MAX_EXPORT_BATCH_SIZE",
+	"OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT",
+	"OTEL_ATTRIBUTE_COUNT_LIMIT",
+	"OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT",
+	"OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT",
+	"OTEL_SPAN_EVENT_COUNT_LIMIT",
+	"OTEL_SPAN_LINK_COUNT_LIMIT",
+	"OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT",
+	"OTEL_LINK_ATTRIBUTE_COUNT_LIMIT",
+}
+
+// Variables read by the telemetry package or the SDK itself.
+var readElsewhere = []string{
+	"OTEL_EXPORTER_OTLP_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE",
+	"OTEL_TRACES_SAMPLER",
+	"OTEL_TRACES_SAMPLER_ARG",
+	"OTEL_METRICS_EXPORTER",
+}
+
+// Variables of the specification this service does not apply, by prefix.
+type ignoredVar struct {
+	prefix string
+	// logged with the variable
+	reason string
+}
+
+var ignored = []ignoredVar{
+	{"OTEL_SDK_DISABLED", "telemetry is switched off through the admin endpoints"},
+	{"OTEL_LOG_LEVEL", "SDK errors are reported through the telemetry status"},
+	{"OTEL_PROPAGATORS", "W3C trace context and baggage are always propagated"},
+	{"OTEL_TRACES_EXPORTER", "spans are always exported over OTLP"},
+	{"OTEL_LOGS_EXPORTER", "log records are always exported over OTLP"},
+	{"OTEL_EXPORTER_OTLP_PROTOCOL", "the collector is always reached over gRPC"},
+	{"OTEL_EXPORTER_OTLP_INSECURE", "TLS is enabled by the certificate variables"},
+	{"OTEL_EXPORTER_OTLP_COMPRESSION", "exports are not compressed"},
+	{"OTEL_EXPORTER_OTLP_TRACES_", "signal-specific settings are not supported, use OTEL_EXPORTER_OTLP_*"},
+	{"OTEL_EXPORTER_OTLP_METRICS_", "signal-specific settings are not supported, use OTEL_EXPORTER_OTLP_*"},
+	{"OTEL_EXPORTER_OTLP_LOGS_", "signal-specific settings are not supported, use OTEL_EXPORTER_OTLP_*"},
+	{"OTEL_BLRP_", "log records go through the SDK's default batch processor"},
+	{"OTEL_LOGRECORD_", "log record limits are not supported"},
+}
+
+// Reads the OTEL_* variables of the environment, see Parse.
+func Options() ([]telemetry.Option, error) {
+	return Parse(os.Environ())
+}
+
+// Reads the OTEL_* variables among environ, as returned by os.Environ, into
+// options to pass to InitProvider ahead of the options of the code, which
+// take precedence. Returns a *telemetry.ValidationError listing every
+// malformed variable, and logs the variables that are not applied.
+func Parse(environ []string) ([]telemetry.Option, error) {
+	env := map[string]string{}
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		// empty variables count as unset
+		if strings.HasPrefix(key, "OTEL_") && value != "" {
+			env[key] = value
+		}
+	}
+	logUnapplied(env)
+
+	var opts []telemetry.Option
+	var issues []telemetry.FieldError
+	report := func(key, allowed, reason string) {
+		// a general limit is read for several specific ones
+		if slices.ContainsFunc(issues, func(f telemetry.FieldError) bool { return f.Field == key }) {
+			return
+		}
+		issues = append(issues, telemetry.FieldError{Field: key, Value: env[key], Allowed: allowed, Reason: reason})
+	}
+	integer := func(key string, min int, allowed string) (int, bool) {
+		v, ok := env[key]
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < min {
+			report(key, allowed, "")
+			return 0, false
+		}
+		return n, true
+	}
+	nonNegative := func(key string) (int, bool) { return integer(key, 0, "a non-negative integer") }
+	positive := func(key string) (int, bool) { return integer(key, 1, "a positive integer") }
+	// the specific limit when set, the general one otherwise
+	limit := func(specific, general string) (int, bool) {
+		n, ok := nonNegative(general)
+		if m, set := nonNegative(specific); set {
+			return m, true
+		}
+		return n, ok
+	}
+
+	attrs, serviceName, reason := parseResourceAttributes(env["OTEL_RESOURCE_ATTRIBUTES"])
+	if reason != "" {
+		report("OTEL_RESOURCE_ATTRIBUTES", "comma-separated key=value pairs, percent-encoded", reason)
+	}
+	if len(attrs) > 0 {
+		opts = append(opts, telemetry.WithResourceAttributes(attrs...))
+	}
+	if name, ok := env["OTEL_SERVICE_NAME"]; ok {
+		if name = strings.TrimSpace(name); name == "" {
+			report("OTEL_SERVICE_NAME", "a non-empty service name", "")
+		} else {
+			serviceName = name
+		}
+	}
+	if serviceName != "" {
+		opts = append(opts, telemetry.WithServiceName(serviceName))
+	}
+
+	if ms, ok := positive("OTEL_EXPORTER_OTLP_TIMEOUT"); ok {
+		opts = append(opts, telemetry.WithOTLPTimeout(millis(ms)))
+	}
+	if v, ok := env["OTEL_EXPORTER_OTLP_HEADERS"]; ok {
+		headers, reason := parseHeaders(v)
+		if reason != "" {
+			report("OTEL_EXPORTER_OTLP_HEADERS", "comma-separated key=value pairs, percent-encoded", reason)
+		} else {
+			opts = append(opts, telemetry.WithHeaders(headers))
+		}
+	}
+	if path, ok := env["OTEL_EXPORTER_OTLP_CERTIFICATE"]; ok {
+		opts = append(opts, telemetry.WithCACert(path))
+	}
+	cert, certSet := env["OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"]
+	key, keySet := env["OTEL_EXPORTER_OTLP_CLIENT_KEY"]
+	switch {
+	case certSet && keySet:
+		opts = append(opts, telemetry.WithClientCert(cert, key))
+	case certSet:
+		report("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", "", "set without OTEL_EXPORTER_OTLP_CLIENT_KEY")
+	case keySet:
+		report("OTEL_EXPORTER_OTLP_CLIENT_KEY", "", "set without OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE")
+	}
+	if ms, ok := positive("OTEL_METRIC_EXPORT_INTERVAL"); ok {
+		opts = append(opts, telemetry.WithMetricInterval(millis(ms)))
+	}
+	if ms, ok := positive("OTEL_METRIC_EXPORT_TIMEOUT"); ok {
+		opts = append(opts, telemetry.WithMetricExportTimeout(millis(ms)))
+	}
+	if ms, ok := positive("OTEL_BSP_SCHEDULE_DELAY"); ok {
+		opts = append(opts, telemetry.WithBatchTimeout(millis(ms)))
+	}
+	if ms, ok := positive("OTEL_BSP_EXPORT_TIMEOUT"); ok {
+		opts = append(opts, telemetry.WithExportTimeout(millis(ms)))
+	}
+	if n, ok := positive("OTEL_BSP_MAX_QUEUE_SIZE"); ok {
+		opts = append(opts, telemetry.WithMaxQueueSize(n))
+	}
+	if n, ok := positive("OTEL_BSP_MAX_EXPORT_BATCH_SIZE"); ok {
+		opts = append(opts, telemetry.WithMaxExportBatchSize(n))
+	}
+
+	if n, ok := limit("OTEL_SPAN_ATTRIBUTE_VALUE_LENGTH_LIMIT", "OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT"); ok {
+		opts = append(opts, telemetry.WithAttributeValueLengthLimit(n))
+	}
+	if n, ok := limit("OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT", "OTEL_ATTRIBUTE_COUNT_LIMIT"); ok {
+		opts = append(opts, telemetry.WithMaxAttributes(n))
+	}
+	if n, ok := limit("OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT", "OTEL_ATTRIBUTE_COUNT_LIMIT"); ok {
+		opts = append(opts, telemetry.WithMaxEventAttributes(n))
+	}
+	if n, ok := limit("OTEL_LINK_ATTRIBUTE_COUNT_LIMIT", "OTEL_ATTRIBUTE_COUNT_LIMIT"); ok {
+		opts = append(opts, telemetry.WithMaxLinkAttributes(n))
+	}
+	if n, ok := nonNegative("OTEL_SPAN_EVENT_COUNT_LIMIT"); ok {
+		opts = append(opts, telemetry.WithMaxEvents(n))
+	}
+	if n, ok := nonNegative("OTEL_SPAN_LINK_COUNT_LIMIT"); ok {
+		opts = append(opts, telemetry.WithMaxLinks(n))
+	}
+
+	if len(issues) > 0 {
+		return nil, &telemetry.ValidationError{Fields: issues}
+	}
+	return opts, nil
+}
+
+// Parses OTEL_RESOURCE_ATTRIBUTES, comma-separated key=value pairs whose
+// keys and values are percent-encoded, splitting service.name off. The whole
+// value is rejected when any pair is malformed, as the specification asks.
+func parseResourceAttributes(s string) (attrs []attribute.KeyValue, serviceName, reason string) {
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, "", "missing = in " + strconv.Quote(pair)
+		}
+		key, err := url.PathUnescape(strings.TrimSpace(key))
+		if err != nil || key == "" {
+			return nil, "", "invalid key in " + strconv.Quote(pair)
+		}
+		value, err = url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, "", "invalid percent-encoding in " + strconv.Quote(pair)
+		}
+		if key == string(semconv.ServiceNameKey) {
+			serviceName = value
+			continue
+		}
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs, serviceName, ""
+}
+
+// Parses OTEL_EXPORTER_OTLP_HEADERS, comma-separated key=value pairs whose
+// values are percent-encoded, keys being lowercased as gRPC metadata expects.
+func parseHeaders(s string) (headers map[string]string, reason string) {
+	headers = map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return nil, "missing key in " + strconv.Quote(pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, "invalid percent-encoding in " + strconv.Quote(pair)
+		}
+		headers[strings.ToLower(key)] = value
+	}
+	return headers, ""
+}
+
+// Logs the OTEL_* variables of env that neither Parse nor the telemetry
+// package apply, most likely typos or settings of another SDK.
+func logUnapplied(env map[string]string) {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if slices.Contains(parsed, key) || slices.Contains(readElsewhere, key) {
+			continue
+		}
+		if i := slices.IndexFunc(ignored, func(ig ignoredVar) bool {
+			return strings.HasPrefix(key, ig.prefix)
+		}); i >= 0 {
+			log.Printf("envconfig: ignoring %s, %s", key, ignored[i].reason)
+			continue
+		}
+		log.Printf("envconfig: ignoring unknown variable %s", key)
+	}
+}
+
+func millis(ms int) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
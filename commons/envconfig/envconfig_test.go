@@ -0,0 +1,167 @@
+package envconfig
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		// invalid variables, in order
+		invalid []string
+		check   func(t *testing.T, cfg telemetry.Config)
+	}{
+		{
+			name:    "batch span processor",
+			environ: []string{"OTEL_BSP_SCHEDULE_DELAY=500", "OTEL_BSP_EXPORT_TIMEOUT=10000", "OTEL_BSP_MAX_QUEUE_SIZE=4096", "OTEL_BSP_MAX_EXPORT_BATCH_SIZE=256"},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				want := telemetry.BatchConfig{Timeout: 500 * time.Millisecond, ExportTimeout: 10 * time.Second, MaxQueueSize: 4096, MaxExportBatchSize: 256}
+				if cfg.Batch != want {
+					t.Errorf("Batch = %+v, want %+v", cfg.Batch, want)
+				}
+			},
+		},
+		{
+			name:    "invalid batch span processor",
+			environ: []string{"OTEL_BSP_SCHEDULE_DELAY=5s", "OTEL_BSP_EXPORT_TIMEOUT=0", "OTEL_BSP_MAX_QUEUE_SIZE=-1", "OTEL_BSP_MAX_EXPORT_BATCH_SIZE=many"},
+			invalid: []string{"OTEL_BSP_SCHEDULE_DELAY", "OTEL_BSP_EXPORT_TIMEOUT", "OTEL_BSP_MAX_QUEUE_SIZE", "OTEL_BSP_MAX_EXPORT_BATCH_SIZE"},
+		},
+		{
+			name:    "empty values count as unset",
+			environ: []string{"OTEL_BSP_SCHEDULE_DELAY=", "OTEL_SERVICE_NAME="},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				if cfg.Batch.Timeout != 0 || cfg.ServiceName != "svc" {
+					t.Errorf("Batch.Timeout = %s, ServiceName = %q, want defaults", cfg.Batch.Timeout, cfg.ServiceName)
+				}
+			},
+		},
+		{
+			name:    "export timeout",
+			environ: []string{"OTEL_EXPORTER_OTLP_TIMEOUT=2500"},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				if cfg.OTLPTimeout != 2500*time.Millisecond {
+					t.Errorf("OTLPTimeout = %s, want 2.5s", cfg.OTLPTimeout)
+				}
+			},
+		},
+		{
+			name:    "specific limits override general ones",
+			environ: []string{"OTEL_ATTRIBUTE_COUNT_LIMIT=16", "OTEL_LINK_ATTRIBUTE_COUNT_LIMIT=4", "OTEL_ATTRIBUTE_VALUE_LENGTH_LIMIT=256", "OTEL_SPAN_EVENT_COUNT_LIMIT=8"},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				l := cfg.SpanLimits
+				if l.AttributeCountLimit != 16 || l.AttributePerEventCountLimit != 16 || l.AttributePerLinkCountLimit != 4 ||
+					l.AttributeValueLengthLimit != 256 || l.EventCountLimit != 8 {
+					t.Errorf("SpanLimits = %+v", l)
+				}
+			},
+		},
+		{
+			name:    "invalid general limit reported once",
+			environ: []string{"OTEL_ATTRIBUTE_COUNT_LIMIT=-3", "OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT=abc"},
+			invalid: []string{"OTEL_ATTRIBUTE_COUNT_LIMIT", "OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT"},
+		},
+		{
+			name:    "event attribute limit",
+			environ: []string{"OTEL_EVENT_ATTRIBUTE_COUNT_LIMIT=2"},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				if l := cfg.SpanLimits; l.AttributePerEventCountLimit != 2 || l.AttributePerLinkCountLimit != 128 {
+					t.Errorf("SpanLimits = %+v, want 2 attributes per event and the default per link", l)
+				}
+			},
+		},
+		{
+			name:    "exporter headers and certificates",
+			environ: []string{"OTEL_EXPORTER_OTLP_HEADERS=X-Api-Key=a%3Db,tenant=acme", "OTEL_EXPORTER_OTLP_CERTIFICATE=/etc/ca.pem", "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE=/etc/client.pem", "OTEL_EXPORTER_OTLP_CLIENT_KEY=/etc/client.key"},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				if cfg.Headers["x-api-key"] != "a=b" || cfg.Headers["tenant"] != "acme" {
+					t.Errorf("Headers = %v", cfg.Headers)
+				}
+				if cfg.CACertFile != "/etc/ca.pem" || cfg.ClientCertFile != "/etc/client.pem" || cfg.ClientKeyFile != "/etc/client.key" {
+					t.Errorf("certificate files %q %q %q", cfg.CACertFile, cfg.ClientCertFile, cfg.ClientKeyFile)
+				}
+			},
+		},
+		{
+			name:    "invalid headers and client certificate without key",
+			environ: []string{"OTEL_EXPORTER_OTLP_HEADERS=tenant", "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE=/etc/client.pem"},
+			invalid: []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"},
+		},
+		{
+			name:    "metric export",
+			environ: []string{"OTEL_METRIC_EXPORT_INTERVAL=15000", "OTEL_METRIC_EXPORT_TIMEOUT=5000"},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				if cfg.MetricInterval != 15*time.Second || cfg.MetricExportTimeout != 5*time.Second {
+					t.Errorf("MetricInterval = %s, MetricExportTimeout = %s, want 15s and 5s", cfg.MetricInterval, cfg.MetricExportTimeout)
+				}
+			},
+		},
+		{
+			name:    "resource attributes and service name",
+			environ: []string{"OTEL_RESOURCE_ATTRIBUTES=team=core,region=eu%2Cwest,service.name=from-attributes", "OTEL_SERVICE_NAME=from-name"},
+			check: func(t *testing.T, cfg telemetry.Config) {
+				want := []attribute.KeyValue{attribute.String("team", "core"), attribute.String("region", "eu,west")}
+				if len(cfg.ResourceAttributes) != len(want) || cfg.ResourceAttributes[0] != want[0] || cfg.ResourceAttributes[1] != want[1] {
+					t.Errorf("ResourceAttributes = %v, want %v", cfg.ResourceAttributes, want)
+				}
+				if cfg.ServiceName != "from-name" {
+					t.Errorf("ServiceName = %q, want OTEL_SERVICE_NAME to win", cfg.ServiceName)
+				}
+			},
+		},
+		{
+			name:    "malformed resource attributes",
+			environ: []string{"OTEL_RESOURCE_ATTRIBUTES=team=core,region"},
+			invalid: []string{"OTEL_RESOURCE_ATTRIBUTES"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := Parse(append([]string{"PATH=/bin"}, tt.environ...))
+			if len(tt.invalid) > 0 {
+				var verr *telemetry.ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("Parse() error = %v, want a *telemetry.ValidationError", err)
+				}
+				var fields []string
+				for _, f := range verr.Fields {
+					fields = append(fields, f.Field)
+				}
+				if len(fields) != len(tt.invalid) {
+					t.Fatalf("invalid variables %v, want %v", fields, tt.invalid)
+				}
+				for i := range fields {
+					if fields[i] != tt.invalid[i] {
+						t.Errorf("invalid variables %v, want %v", fields, tt.invalid)
+						break
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			tt.check(t, telemetry.NewConfig("svc", opts...))
+		})
+	}
+}
+
+// Parse is the only reader of the variables, the config built without its
+// options keeps the defaults.
+func TestNewConfigIgnoresEnvironment(t *testing.T) {
+	t.Setenv("OTEL_BSP_SCHEDULE_DELAY", "500")
+	t.Setenv("OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT", "4")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "tenant=acme")
+	cfg := telemetry.NewConfig("svc")
+	if cfg.Batch != (telemetry.BatchConfig{}) || cfg.SpanLimits.AttributeCountLimit != 128 || len(cfg.Headers) != 0 {
+		t.Errorf("NewConfig read the environment: Batch %+v, SpanLimits %+v, Headers %v", cfg.Batch, cfg.SpanLimits, cfg.Headers)
+	}
+	if cfg.SpanLimits.AttributeValueLengthLimit != telemetry.DefaultAttributeValueLengthLimit {
+		t.Errorf("AttributeValueLengthLimit = %d, want %d", cfg.SpanLimits.AttributeValueLengthLimit, telemetry.DefaultAttributeValueLengthLimit)
+	}
+}
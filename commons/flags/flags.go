@@ -0,0 +1,99 @@
+// Package flags evaluates feature flags in the style of OpenFeature, recording
+// every evaluation as a feature_flag event on the active span so traces tell
+// which code path a request took.
+package flags
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the span event recorded per evaluation.
+const EventName = "feature_flag"
+
+// Feature flag, served with DefaultVariant when the provider does not know
+// it or fails.
+type Flag struct {
+	Key            string
+	DefaultVariant string
+}
+
+// Source of the flag variants.
+type Provider interface {
+	// name reported as feature_flag.provider_name
+	Name() string
+	// returns the variant of the flag, false when the flag is unknown
+	Resolve(ctx context.Context, key string) (string, bool, error)
+}
+
+var (
+	mu       sync.RWMutex
+	provider Provider = EnvProvider("FEATURE_FLAGS")
+)
+
+// Replaces the provider, FEATURE_FLAGS from the environment by default.
+func SetProvider(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+}
+
+// Returns the variant of flag and records the evaluation on the span in ctx
+// with its key, variant and provider. Provider errors are reported to the
+// global error handler and fall back to the default variant.
+func Evaluate(ctx context.Context, flag Flag) string {
+	mu.RLock()
+	p := provider
+	mu.RUnlock()
+
+	variant, ok, err := p.Resolve(ctx, flag.Key)
+	if err != nil {
+		otel.Handle(err)
+	}
+	if err != nil || !ok {
+		variant = flag.DefaultVariant
+	}
+	trace.SpanFromContext(ctx).AddEvent(EventName, trace.WithAttributes(
+		semconv.FeatureFlagKey(flag.Key),
+		semconv.FeatureFlagVariant(variant),
+		semconv.FeatureFlagProviderName(p.Name()),
+	))
+	return variant
+}
+
+// Reports whether flag evaluates to the "on" variant.
+func Enabled(ctx context.Context, flag Flag) bool {
+	return Evaluate(ctx, flag) == "on"
+}
+
+// Provider serving fixed variants by flag key.
+type StaticProvider map[string]string
+
+func (StaticProvider) Name() string { return "static" }
+
+func (p StaticProvider) Resolve(_ context.Context, key string) (string, bool, error) {
+	variant, ok := p[key]
+	return variant, ok, nil
+}
+
+// Provider reading the variants from the named environment variable,
+// written as "key1=variant1,key2=variant2", on every evaluation.
+type EnvProvider string
+
+func (EnvProvider) Name() string { return "env" }
+
+func (p EnvProvider) Resolve(_ context.Context, key string) (string, bool, error) {
+	for _, pair := range strings.Split(os.Getenv(string(p)), ",") {
+		k, variant, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(variant), true, nil
+		}
+	}
+	return "", false, nil
+}
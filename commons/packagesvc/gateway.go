@@ -0,0 +1,20 @@
+package packagesvc
+
+import (
+	"context"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+// Returns a grpc-gateway mux exposing the service over REST, translating the
+// routes of package_gateway.yaml into calls through client. Mounted behind
+// the traced HTTP router and with an instrumented client, one request shows
+// the gateway server span, the gRPC client span and the gRPC server span in
+// the same trace.
+func NewGateway(client *Client) (*runtime.ServeMux, error) {
+	mux := runtime.NewServeMux()
+	if err := RegisterPackageServiceHandlerClient(context.Background(), mux, client); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
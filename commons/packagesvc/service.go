@@ -0,0 +1,44 @@
+// Package packagesvc defines the gRPC package lookup service shared by the
+// server (app1) and its clients, wired with OpenTelemetry instrumentation.
+// The service and its REST routes are generated from package.proto.
+package packagesvc
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative --grpc-gateway_out=.. --grpc-gateway_opt=paths=source_relative,grpc_api_configuration=packagesvc/package_gateway.yaml packagesvc/package.proto
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Creates a gRPC server instrumented with OpenTelemetry serving srv.
+func NewServer(srv PackageServiceServer, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}, opts...)
+	s := grpc.NewServer(opts...)
+	RegisterPackageServiceServer(s, srv)
+	return s
+}
+
+// Client side of the package service.
+type Client struct {
+	PackageServiceClient
+	cc *grpc.ClientConn
+}
+
+// Creates a client instrumented with OpenTelemetry. Trace context and baggage
+// travel in the gRPC metadata through the global propagator.
+func NewClient(target string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}, opts...)
+	cc, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{PackageServiceClient: NewPackageServiceClient(cc), cc: cc}, nil
+}
+
+func (c *Client) Close() error {
+	return c.cc.Close()
+}
@@ -0,0 +1,49 @@
+package packagesvc
+
+import (
+	"context"
+
+	"github.com/sosalejandro/otel-example/commons/semconvx"
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Path of the WebSocket endpoint streaming package status changes.
+const UpdatesPath = "/packages/updates"
+
+// First message sent by a subscriber, selecting the package to follow.
+type Subscription struct {
+	ID    string            `json:"id"`
+	Trace map[string]string `json:"trace,omitempty"`
+}
+
+// Status change of a package pushed to subscribers.
+type Update struct {
+	ID     string            `json:"id"`
+	Status string            `json:"status"`
+	Trace  map[string]string `json:"trace,omitempty"`
+}
+
+// Starts the span of an outgoing WebSocket message about package id. The
+// returned map carries the span context and goes into the message Trace field.
+func StartSendSpan(ctx context.Context, tracer trace.Tracer, id string) (context.Context, trace.Span, map[string]string) {
+	ctx, span := tracer.Start(ctx, UpdatesPath+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messageAttributes(id, semconvx.MessagingPublish)...))
+	return ctx, span, telemetry.InjectMap(ctx)
+}
+
+// Starts the span of a received WebSocket message about package id as a
+// child of ctx, linked to the span which sent the message.
+func StartReceiveSpan(ctx context.Context, tracer trace.Tracer, id string, carrier map[string]string) (context.Context, trace.Span) {
+	sent := telemetry.ExtractMap(context.Background(), carrier)
+	return tracer.Start(ctx, UpdatesPath+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.LinkFromContext(sent)),
+		trace.WithAttributes(messageAttributes(id, semconvx.MessagingReceive)...))
+}
+
+func messageAttributes(id, operation string) []attribute.KeyValue {
+	return append(semconvx.MessagingAttrs("websocket", UpdatesPath, operation), attribute.String("package", id))
+}
@@ -0,0 +1,74 @@
+package semconvx
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Attributes built once for the values hot paths record again and again.
+var (
+	NetworkProtocolHTTP10 = semconv.NetworkProtocolVersion("1.0")
+	NetworkProtocolHTTP11 = semconv.NetworkProtocolVersion("1.1")
+	NetworkProtocolHTTP2  = semconv.NetworkProtocolVersion("2")
+	URLSchemeHTTP         = semconv.URLScheme("http")
+	URLSchemeHTTPS        = semconv.URLScheme("https")
+)
+
+// Reusable attribute slice, taken with GetAttrs and given back with Release
+// once the attributes were handed to a span or an instrument, which copy
+// them. Hot handlers build their attributes without allocating a slice per
+// request.
+type AttrBuilder struct {
+	attrs []attribute.KeyValue
+}
+
+var builders = sync.Pool{
+	New: func() any { return &AttrBuilder{attrs: make([]attribute.KeyValue, 0, 16)} },
+}
+
+// Takes an empty builder from the pool.
+func GetAttrs() *AttrBuilder {
+	return builders.Get().(*AttrBuilder)
+}
+
+// Appends attributes.
+func (b *AttrBuilder) Add(attrs ...attribute.KeyValue) *AttrBuilder {
+	b.attrs = append(b.attrs, attrs...)
+	return b
+}
+
+// Appends the route template gorilla/mux matched for r, if any.
+func (b *AttrBuilder) Route(r *http.Request) *AttrBuilder {
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if tpl, err := cr.GetPathTemplate(); err == nil {
+			b.attrs = append(b.attrs, semconv.HTTPRoute(tpl))
+		}
+	}
+	return b
+}
+
+// Appends the attributes HTTPServerAttrs returns for r.
+func (b *AttrBuilder) HTTPServer(r *http.Request) *AttrBuilder {
+	b.attrs = AppendHTTPServerAttrs(b.attrs, r)
+	return b
+}
+
+// Returns the attributes built so far, valid until Release.
+func (b *AttrBuilder) Attrs() []attribute.KeyValue {
+	return b.attrs
+}
+
+// Gives the builder back to the pool.
+func (b *AttrBuilder) Release() {
+	// oversized slices would pin memory in the pool
+	if cap(b.attrs) > 64 {
+		return
+	}
+	clear(b.attrs)
+	b.attrs = b.attrs[:0]
+	builders.Put(b)
+}
@@ -0,0 +1,166 @@
+// Package semconvx builds OpenTelemetry semantic convention attributes from a
+// single pinned semconv version, so applications never mix versions and an
+// upgrade of the conventions happens in one place.
+package semconvx
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Schema URL of the pinned semantic conventions.
+const SchemaURL = semconv.SchemaURL
+
+// Operation types of messaging spans.
+const (
+	MessagingPublish = "publish"
+	MessagingReceive = "receive"
+	MessagingProcess = "process"
+)
+
+// Returns the attributes describing an incoming HTTP request, including the
+// gorilla/mux route template when one matched.
+func HTTPServerAttrs(r *http.Request) []attribute.KeyValue {
+	return AppendHTTPServerAttrs(make([]attribute.KeyValue, 0, 10), r)
+}
+
+// Appends the attributes HTTPServerAttrs returns to dst.
+func AppendHTTPServerAttrs(dst []attribute.KeyValue, r *http.Request) []attribute.KeyValue {
+	dst = append(dst,
+		method(r.Method),
+		semconv.URLPath(r.URL.Path),
+		scheme(r),
+		protocolVersion(r),
+	)
+	dst = appendHostAttrs(dst, r.Host)
+	if ua := r.UserAgent(); ua != "" {
+		dst = append(dst, semconv.UserAgentOriginal(ua))
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		dst = append(dst, semconv.ClientAddress(host))
+	}
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if tpl, err := cr.GetPathTemplate(); err == nil {
+			dst = append(dst, semconv.HTTPRoute(tpl))
+		}
+	}
+	return dst
+}
+
+// Returns the attributes describing an outgoing HTTP request and, when resp
+// is not nil, its response. Credentials in the URL are never recorded.
+func HTTPClientAttrs(req *http.Request, resp *http.Response) []attribute.KeyValue {
+	u := *req.URL
+	u.User = nil
+	attrs := []attribute.KeyValue{
+		method(req.Method),
+		semconv.URLFull(u.String()),
+	}
+	attrs = append(attrs, hostAttrs(u.Host)...)
+	if resp != nil {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	return attrs
+}
+
+// Returns the attributes describing a database call, e.g.
+// DBAttrs("postgresql", "SELECT * FROM packages WHERE id = $1").
+func DBAttrs(system, stmt string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.DBSystemKey.String(system)}
+	if stmt != "" {
+		attrs = append(attrs, semconv.DBQueryText(stmt))
+		if op, _, _ := strings.Cut(strings.TrimSpace(stmt), " "); op != "" {
+			attrs = append(attrs, semconv.DBOperationName(strings.ToUpper(op)))
+		}
+	}
+	return attrs
+}
+
+// Returns the attributes describing a messaging operation, one of
+// MessagingPublish, MessagingReceive or MessagingProcess, on destination.
+func MessagingAttrs(system, destination, operation string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String(system),
+		semconv.MessagingDestinationName(destination),
+		semconv.MessagingOperationTypeKey.String(operation),
+		semconv.MessagingOperationName(operation),
+	}
+}
+
+// Returns the Kafka specific attributes of a message. Partition and offset
+// are omitted when negative, i.e. before the message is written.
+func KafkaMessageAttrs(key string, partition int, offset int64) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.MessagingKafkaMessageKey(key)}
+	if partition >= 0 {
+		attrs = append(attrs, semconv.MessagingDestinationPartitionID(strconv.Itoa(partition)))
+	}
+	if offset >= 0 {
+		attrs = append(attrs, semconv.MessagingKafkaMessageOffset(int(offset)))
+	}
+	return attrs
+}
+
+// Returns the size of a message body.
+func MessageBodySize(n int) attribute.KeyValue {
+	return semconv.MessagingMessageBodySize(n)
+}
+
+// Returns the logical name of the remote service.
+func PeerService(name string) attribute.KeyValue {
+	return semconv.PeerService(name)
+}
+
+// Known methods go into http.request.method as is, anything else as _OTHER
+// to bound the cardinality.
+func method(m string) attribute.KeyValue {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return semconv.HTTPRequestMethodKey.String(m)
+	}
+	return semconv.HTTPRequestMethodOther
+}
+
+func hostAttrs(hostport string) []attribute.KeyValue {
+	return appendHostAttrs(nil, hostport)
+}
+
+func appendHostAttrs(dst []attribute.KeyValue, hostport string) []attribute.KeyValue {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		if hostport == "" {
+			return dst
+		}
+		return append(dst, semconv.ServerAddress(hostport))
+	}
+	dst = append(dst, semconv.ServerAddress(host))
+	if p, err := strconv.Atoi(port); err == nil {
+		dst = append(dst, semconv.ServerPort(p))
+	}
+	return dst
+}
+
+func scheme(r *http.Request) attribute.KeyValue {
+	if r.TLS != nil {
+		return URLSchemeHTTPS
+	}
+	return URLSchemeHTTP
+}
+
+func protocolVersion(r *http.Request) attribute.KeyValue {
+	switch {
+	case r.ProtoMajor == 2:
+		return NetworkProtocolHTTP2
+	case r.ProtoMajor == 1 && r.ProtoMinor == 1:
+		return NetworkProtocolHTTP11
+	case r.ProtoMajor == 1 && r.ProtoMinor == 0:
+		return NetworkProtocolHTTP10
+	}
+	return semconv.NetworkProtocolVersion(strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor))
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Settings of the servers built by New.
+type HTTPConfig struct {
+	// time to read a whole request, body included
+	ReadTimeout time.Duration
+	// time to read the request headers, guarding against slow clients
+	ReadHeaderTimeout time.Duration
+	// time to write the response
+	WriteTimeout time.Duration
+	// time a keep-alive connection waits for the next request
+	IdleTimeout time.Duration
+	// size cap of the request headers
+	MaxHeaderBytes int
+	// serve over TLS with these settings, plaintext when nil
+	TLSConfig *tls.Config
+	// accept HTTP/2 without TLS, e.g. behind a proxy or for gRPC clients
+	H2C bool
+}
+
+// Customizes the HTTPConfig of New.
+type HTTPOption func(*HTTPConfig)
+
+// Bounds reading requests, writing responses and idle connections.
+func WithTimeouts(read, write, idle time.Duration) HTTPOption {
+	return func(c *HTTPConfig) {
+		c.ReadTimeout, c.WriteTimeout, c.IdleTimeout = read, write, idle
+	}
+}
+
+// Bounds reading the request headers, 2 seconds by default.
+func WithReadHeaderTimeout(d time.Duration) HTTPOption {
+	return func(c *HTTPConfig) {
+		c.ReadHeaderTimeout = d
+	}
+}
+
+// Caps the size of the request headers, 64 KiB by default.
+func WithMaxHeaderBytes(n int) HTTPOption {
+	return func(c *HTTPConfig) {
+		c.MaxHeaderBytes = n
+	}
+}
+
+// Serves over TLS with cfg, which must hold the certificates.
+func WithTLS(cfg *tls.Config) HTTPOption {
+	return func(c *HTTPConfig) {
+		c.TLSConfig = cfg
+	}
+}
+
+// Accepts HTTP/2 over plaintext connections. Ignored with TLS, where HTTP/2
+// is negotiated.
+func WithH2C() HTTPOption {
+	return func(c *HTTPConfig) {
+		c.H2C = true
+	}
+}
+
+// Creates a server listening on addr with hardened defaults: every timeout
+// set and the request headers capped. Run serves it through a listener
+// reporting its connections.
+func New(addr string, handler http.Handler, opts ...HTTPOption) *http.Server {
+	cfg := HTTPConfig{
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 2 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    64 << 10,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.H2C && cfg.TLSConfig == nil {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: cfg.IdleTimeout})
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		TLSConfig:         cfg.TLSConfig,
+	}
+}
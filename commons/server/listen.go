@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Resource and span attribute telling how the server listens.
+const ListenerTypeKey = attribute.Key("server.listener.type")
+
+// Values of ListenerTypeKey.
+const (
+	ListenerTCP     = "tcp"
+	ListenerUnix    = "unix"
+	ListenerSystemd = "systemd"
+)
+
+// First file descriptor passed by systemd socket activation.
+const listenFDsStart = 3
+
+// Listens on the Unix domain socket at path instead of TCP, e.g. for a
+// sidecar reaching the service over a shared volume. A socket left at path
+// by a previous run is removed.
+func WithUnixSocket(path string) Option {
+	return func(c *Config) {
+		c.UnixSocket = path
+	}
+}
+
+// Serves on the socket passed by systemd through LISTEN_FDS when the process
+// was socket activated, listening as configured otherwise.
+func WithSocketActivation() Option {
+	return func(c *Config) {
+		c.SocketActivation = true
+	}
+}
+
+// Returns the attributes describing how Run listens with opts, to be added
+// to the resource with telemetry.WithResourceAttributes.
+func ListenerAttributes(opts ...Option) []attribute.KeyValue {
+	return []attribute.KeyValue{ListenerTypeKey.String(listenerType(newConfig(opts...)))}
+}
+
+func listenerType(cfg Config) string {
+	switch {
+	case cfg.SocketActivation && socketActivated():
+		return ListenerSystemd
+	case cfg.UnixSocket != "":
+		return ListenerUnix
+	}
+	return ListenerTCP
+}
+
+// Reports whether systemd passed sockets to this process.
+func socketActivated() bool {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return false
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return err == nil && n > 0
+}
+
+// Opens the listener configured by cfg, on addr when listening over TCP,
+// and returns it with its type.
+func listen(cfg Config, addr string) (net.Listener, string, error) {
+	switch kind := listenerType(cfg); kind {
+	case ListenerSystemd:
+		// children must not take the sockets for theirs
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+		f := os.NewFile(listenFDsStart, "LISTEN_FD_"+strconv.Itoa(listenFDsStart))
+		defer f.Close()
+		l, err := net.FileListener(f)
+		return l, kind, err
+	case ListenerUnix:
+		if fi, err := os.Lstat(cfg.UnixSocket); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+			if err := os.Remove(cfg.UnixSocket); err != nil {
+				return nil, kind, err
+			}
+		} else if err == nil {
+			return nil, kind, fmt.Errorf("%s exists and is not a socket", cfg.UnixSocket)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, kind, err
+		}
+		l, err := net.Listen("unix", cfg.UnixSocket)
+		return l, kind, err
+	default:
+		if addr == "" {
+			addr = ":http"
+		}
+		l, err := net.Listen("tcp", addr)
+		return l, kind, err
+	}
+}
+
+// Returns the ConnContext of a server listening with a listener of the given
+// type: every span of a request gets the type through the ambient attributes.
+func listenerConnContext(kind string, next func(context.Context, net.Conn) context.Context) func(context.Context, net.Conn) context.Context {
+	return func(ctx context.Context, c net.Conn) context.Context {
+		if next != nil {
+			ctx = next(ctx, c)
+		}
+		return telemetry.WithAttrs(ctx, ListenerTypeKey.String(kind))
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Instrumentation scope of the metrics of this package.
+const instrumentationName = "github.com/sosalejandro/otel-example/commons/server"
+
+// Listener counting the connections it accepts and those still open.
+type instrumentedListener struct {
+	net.Listener
+	attrs    metric.MeasurementOption
+	accepted metric.Int64Counter
+	active   metric.Int64UpDownCounter
+}
+
+// Wraps l so the connections it accepts are counted in
+// server.connections.accepted and those open in server.connections.active.
+func NewListener(l net.Listener) net.Listener {
+	meter := telemetry.Meter(instrumentationName, "")
+	accepted := telemetry.NewCounter(instrumentationName, telemetry.Descriptor{
+		Name:        "server.connections.accepted",
+		Unit:        "{connection}",
+		Description: "Connections accepted by the server.",
+		Attributes:  []string{string(semconv.ServerAddressKey)},
+	})
+	active, err := meter.Int64UpDownCounter(
+		"server.connections.active",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("Connections open on the server."),
+	)
+	telemetry.HandleErr(err, "Failed to create the active connections counter")
+	telemetry.RegisterMetrics(telemetry.Descriptor{
+		Name:        "server.connections.active",
+		Kind:        "updowncounter",
+		Unit:        "{connection}",
+		Description: "Connections open on the server.",
+		Attributes:  []string{string(semconv.ServerAddressKey)},
+	})
+	return &instrumentedListener{
+		Listener: l,
+		attrs:    metric.WithAttributes(semconv.ServerAddress(l.Addr().String())),
+		accepted: accepted,
+		active:   active,
+	}
+}
+
+func (l *instrumentedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.accepted.Add(context.Background(), 1, l.attrs)
+	l.active.Add(context.Background(), 1, l.attrs)
+	return &countedConn{Conn: conn, listener: l}, nil
+}
+
+// Connection leaving the active count when closed.
+type countedConn struct {
+	net.Conn
+	listener *instrumentedListener
+	once     sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		c.listener.active.Add(context.Background(), -1, c.listener.attrs)
+	})
+	return c.Conn.Close()
+}
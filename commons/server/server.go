@@ -0,0 +1,185 @@
+// Package server runs HTTP servers until the process is asked to stop, then
+// shuts them down in order: the server drains its requests first, the
+// shutdown hooks run next, and telemetry is flushed and shut down last, so
+// the spans of the final requests are exported.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/telemetry"
+	"go.opentelemetry.io/otel"
+)
+
+// Settings of Run.
+type Config struct {
+	// signals asking for the shutdown, SIGINT and SIGTERM by default
+	Signals []os.Signal
+	// time in-flight requests get to finish
+	DrainTimeout time.Duration
+	// time each shutdown hook gets
+	HookTimeout time.Duration
+	// time the telemetry flush and shutdown get
+	TelemetryTimeout time.Duration
+	// run in order once the server stopped, before telemetry shuts down
+	Hooks []Hook
+	// path of the Unix domain socket listened on instead of TCP
+	UnixSocket string
+	// serve on the socket passed by systemd when there is one
+	SocketActivation bool
+}
+
+// Work run during the shutdown, e.g. stopping a gRPC server or waiting for
+// background tasks. It should return once ctx is done.
+type Hook struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// Customizes the Config of Run.
+type Option func(*Config)
+
+// Shuts down on the given signals instead of SIGINT and SIGTERM.
+func WithSignals(signals ...os.Signal) Option {
+	return func(c *Config) {
+		c.Signals = signals
+	}
+}
+
+// Gives in-flight requests d to finish, 5 seconds by default.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.DrainTimeout = d
+	}
+}
+
+// Gives each shutdown hook d, 5 seconds by default.
+func WithHookTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.HookTimeout = d
+	}
+}
+
+// Gives the telemetry flush and shutdown d, 5 seconds by default.
+func WithTelemetryTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.TelemetryTimeout = d
+	}
+}
+
+// Runs fn once the server stopped, after the hooks added before it.
+func WithShutdownHook(name string, fn func(ctx context.Context) error) Option {
+	return func(c *Config) {
+		c.Hooks = append(c.Hooks, Hook{Name: name, Fn: fn})
+	}
+}
+
+func newConfig(opts ...Option) Config {
+	cfg := Config{
+		Signals:          []os.Signal{os.Interrupt, syscall.SIGTERM},
+		DrainTimeout:     5 * time.Second,
+		HookTimeout:      5 * time.Second,
+		TelemetryTimeout: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Serves srv, over TLS when it has a TLS config, through a listener
+// reporting its connections, on its address or the socket configured by
+// opts, until one of the configured signals arrives or it fails. Then shuts
+// down the server, runs the hooks and finally flushes and shuts down
+// telemetry through telemetryShutdown, each phase within its own timeout. Returns the errors of the server and of the hooks, those of
+// telemetry going to the OTel error handler.
+func Run(srv *http.Server, telemetryShutdown func(), opts ...Option) error {
+	cfg := newConfig(opts...)
+	var errs []error
+	if err := serve(srv, cfg); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, h := range cfg.Hooks {
+		if err := within(cfg.HookTimeout, h.Fn); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %s: %w", h.Name, err))
+		}
+	}
+
+	err := within(cfg.TelemetryTimeout, func(ctx context.Context) error {
+		err := telemetry.Flush(ctx)
+		if telemetryShutdown != nil {
+			telemetryShutdown()
+		}
+		return err
+	})
+	if err != nil {
+		otel.Handle(fmt.Errorf("telemetry shutdown: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// Serves srv until a signal of cfg arrives, then drains its requests.
+func serve(srv *http.Server, cfg Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), cfg.Signals...)
+	defer stop()
+
+	l, kind, err := listen(cfg, srv.Addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	l = NewListener(l)
+	srv.ConnContext = listenerConnContext(kind, srv.ConnContext)
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			serveErr <- srv.ServeTLS(l, "", "")
+			return
+		}
+		serveErr <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("Shutting down server...")
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	defer cancel()
+	var errs []error
+	if err := srv.Shutdown(drainCtx); err != nil {
+		errs = append(errs, fmt.Errorf("server shutdown: %w", err))
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("serve: %w", err))
+	}
+	log.Println("Server shut down.")
+	return errors.Join(errs...)
+}
+
+// Runs fn with a context done after timeout, giving up on it when it does
+// not return by then.
+func within(timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
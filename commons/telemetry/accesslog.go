@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Creates a logger writing one JSON object per line to the given sink.
+func NewJSONAccessLogger(sink io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(sink, nil))
+}
+
+// Middleware logging one line per request with its method, route, status,
+// duration, size and trace correlation fields. It must be installed after
+// the tracing middleware.
+func AccessLogMiddleware(logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := httpsnoop.CaptureMetrics(next, w, r)
+
+			route := r.URL.Path
+			if cr := mux.CurrentRoute(r); cr != nil {
+				if tpl, err := cr.GetPathTemplate(); err == nil {
+					route = tpl
+				}
+			}
+			sc := trace.SpanContextFromContext(r.Context())
+
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "access",
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.Int("status", m.Code),
+				slog.Duration("duration", m.Duration),
+				slog.Int64("bytes", m.Written),
+				slog.String("trace_id", sc.TraceID().String()),
+				slog.String("span_id", sc.SpanID().String()),
+				slog.String("destination", baggage.FromContext(r.Context()).Member("destination").Value()),
+			)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type ambientAttrsKey struct{}
+
+// Returns a copy of ctx carrying attrs on top of those it already carries.
+// Every span started under the returned context gets them, so handlers set
+// attributes such as package.id once instead of on each child span. Later
+// values win over earlier ones of the same key.
+func WithAttrs(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	current := AttrsFromContext(ctx)
+	merged := make([]attribute.KeyValue, 0, len(current)+len(attrs))
+	merged = append(append(merged, current...), attrs...)
+	return context.WithValue(ctx, ambientAttrsKey{}, merged)
+}
+
+// Returns the attributes stored in ctx by WithAttrs.
+func AttrsFromContext(ctx context.Context) []attribute.KeyValue {
+	attrs, _ := ctx.Value(ambientAttrsKey{}).([]attribute.KeyValue)
+	return attrs
+}
+
+// Span processor setting the attributes stored by WithAttrs on the spans
+// started under them.
+type ambientSpanProcessor struct{}
+
+func (ambientSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if attrs := AttrsFromContext(parent); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+func (ambientSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (ambientSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (ambientSpanProcessor) ForceFlush(context.Context) error { return nil }
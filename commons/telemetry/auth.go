@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+)
+
+// Source of rotating credentials, e.g. short-lived OAuth tokens, asked for a
+// token before every export.
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Adapts a function to the TokenProvider interface.
+type TokenProviderFunc func(ctx context.Context) (string, error)
+
+func (f TokenProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Sends the given headers with every export, e.g. {"x-honeycomb-team": key}
+// or {"DD-API-KEY": key}, on top of those from OTEL_EXPORTER_OTLP_HEADERS.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *Config) {
+		if c.Headers == nil {
+			c.Headers = map[string]string{}
+		}
+		for k, v := range headers {
+			c.Headers[k] = v
+		}
+	}
+}
+
+// Sends "authorization: Bearer <token>" with every export, asking provider
+// for the token each time so rotated credentials are used right away. The
+// collector connection must use TLS.
+func WithTokenProvider(provider TokenProvider) Option {
+	return func(c *Config) {
+		c.TokenProvider = provider
+	}
+}
+
+// gRPC credentials adding the bearer token of a TokenProvider to every call.
+type tokenCredentials struct {
+	provider TokenProvider
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	token, err := t.provider.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the exporter token: %w", err)
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (tokenCredentials) RequireTransportSecurity() bool { return true }
@@ -0,0 +1,104 @@
+package telemetry
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Tuning of the batch span processors, zero values keeping the SDK defaults.
+type BatchConfig struct {
+	// maximum delay before a batch is exported
+	Timeout time.Duration
+	// maximum number of spans waiting for export, later spans are dropped
+	MaxQueueSize int
+	// maximum number of spans sent in one export
+	MaxExportBatchSize int
+	// maximum duration of one export
+	ExportTimeout time.Duration
+}
+
+// Checks the settings are consistent with each other.
+func (b BatchConfig) validate() error {
+	if issues := b.issues(); len(issues) > 0 {
+		return &ValidationError{Fields: issues}
+	}
+	return nil
+}
+
+// Returns the negative settings, and a batch size exceeding the queue.
+func (b BatchConfig) issues() []FieldError {
+	var issues []FieldError
+	for _, d := range []struct {
+		field string
+		value int64
+	}{
+		{"Batch.Timeout", int64(b.Timeout)},
+		{"Batch.ExportTimeout", int64(b.ExportTimeout)},
+		{"Batch.MaxQueueSize", int64(b.MaxQueueSize)},
+		{"Batch.MaxExportBatchSize", int64(b.MaxExportBatchSize)},
+	} {
+		if d.value < 0 {
+			issues = append(issues, FieldError{Field: d.field, Value: strconv.FormatInt(d.value, 10), Allowed: "a non-negative value, 0 for the SDK default"})
+		}
+	}
+	if b.MaxQueueSize > 0 && b.MaxExportBatchSize > b.MaxQueueSize {
+		issues = append(issues, FieldError{
+			Field:   "Batch.MaxExportBatchSize",
+			Value:   strconv.Itoa(b.MaxExportBatchSize),
+			Allowed: fmt.Sprintf("at most Batch.MaxQueueSize (%d)", b.MaxQueueSize),
+		})
+	}
+	return issues
+}
+
+// Converts the settings into batch span processor options.
+func (b BatchConfig) options() []sdktrace.BatchSpanProcessorOption {
+	var opts []sdktrace.BatchSpanProcessorOption
+	if b.Timeout > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(b.Timeout))
+	}
+	if b.MaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(b.MaxQueueSize))
+	}
+	if b.MaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(b.MaxExportBatchSize))
+	}
+	if b.ExportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(b.ExportTimeout))
+	}
+	return opts
+}
+
+// Sets the maximum delay before a batch of spans is exported, overriding
+// OTEL_BSP_SCHEDULE_DELAY.
+func WithBatchTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Batch.Timeout = timeout
+	}
+}
+
+// Sets the maximum number of spans waiting for export, overriding
+// OTEL_BSP_MAX_QUEUE_SIZE. Spans ending while the queue is full are dropped.
+func WithMaxQueueSize(size int) Option {
+	return func(c *Config) {
+		c.Batch.MaxQueueSize = size
+	}
+}
+
+// Sets the maximum number of spans sent in one export, overriding
+// OTEL_BSP_MAX_EXPORT_BATCH_SIZE. It must not exceed the queue size.
+func WithMaxExportBatchSize(size int) Option {
+	return func(c *Config) {
+		c.Batch.MaxExportBatchSize = size
+	}
+}
+
+// Sets how long one export may take, overriding OTEL_BSP_EXPORT_TIMEOUT.
+func WithExportTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Batch.ExportTimeout = timeout
+	}
+}
@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestBatchConfigOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		batch   BatchConfig
+		invalid []string
+		want    sdktrace.BatchSpanProcessorOptions
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "all set",
+			batch: BatchConfig{
+				Timeout:            time.Second,
+				MaxQueueSize:       4096,
+				MaxExportBatchSize: 1024,
+				ExportTimeout:      10 * time.Second,
+			},
+			want: sdktrace.BatchSpanProcessorOptions{
+				BatchTimeout:       time.Second,
+				MaxQueueSize:       4096,
+				MaxExportBatchSize: 1024,
+				ExportTimeout:      10 * time.Second,
+			},
+		},
+		{
+			name:    "negative values",
+			batch:   BatchConfig{Timeout: -time.Second, MaxQueueSize: -1},
+			invalid: []string{"Batch.Timeout", "Batch.MaxQueueSize"},
+		},
+		{
+			name:    "batch larger than the queue",
+			batch:   BatchConfig{MaxQueueSize: 100, MaxExportBatchSize: 200},
+			invalid: []string{"Batch.MaxExportBatchSize"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, issue := range tt.batch.issues() {
+				fields = append(fields, issue.Field)
+			}
+			if !equalStrings(fields, tt.invalid) {
+				t.Errorf("invalid fields %v, want %v", fields, tt.invalid)
+			}
+			if (tt.batch.validate() != nil) != (len(tt.invalid) > 0) {
+				t.Errorf("validate() = %v, want an error only with invalid fields", tt.batch.validate())
+			}
+			if len(tt.invalid) > 0 {
+				return
+			}
+			var got sdktrace.BatchSpanProcessorOptions
+			for _, opt := range tt.batch.options() {
+				opt(&got)
+			}
+			if got != tt.want {
+				t.Errorf("options applied %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package telemetry
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultMaxBodyBytes = 1024
+
+type bodyCaptureConfig struct {
+	maxBytes     int
+	contentTypes []string
+}
+
+// Configures request/response body capture.
+type BodyCaptureOption func(*bodyCaptureConfig)
+
+// Limits the number of bytes recorded per body, 1KiB by default.
+func WithMaxBodyBytes(n int) BodyCaptureOption {
+	return func(c *bodyCaptureConfig) {
+		c.maxBytes = n
+	}
+}
+
+// Restricts capture to bodies whose content type starts with one of the
+// given prefixes, "application/json" and "text/" by default.
+func WithBodyContentTypes(prefixes ...string) BodyCaptureOption {
+	return func(c *bodyCaptureConfig) {
+		c.contentTypes = prefixes
+	}
+}
+
+func newBodyCaptureConfig(opts []BodyCaptureOption) bodyCaptureConfig {
+	cfg := bodyCaptureConfig{
+		maxBytes:     defaultMaxBodyBytes,
+		contentTypes: []string{"application/json", "text/"},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (c bodyCaptureConfig) allowed(contentType string) bool {
+	for _, prefix := range c.contentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Truncated copy of a body along with its full size.
+type bodyCapture struct {
+	mu   sync.Mutex
+	max  int
+	buf  bytes.Buffer
+	size int
+	once sync.Once
+}
+
+func (c *bodyCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size += len(p)
+	if room := c.max - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// Adds the captured body as a span event, at most once.
+func (c *bodyCapture) record(span trace.Span, cfg bodyCaptureConfig, name, contentType string) {
+	c.once.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.size == 0 {
+			return
+		}
+		if contentType == "" {
+			contentType = http.DetectContentType(c.buf.Bytes())
+		}
+		if !cfg.allowed(contentType) {
+			return
+		}
+		span.AddEvent(name, trace.WithAttributes(
+			attribute.String("http.body.content", c.buf.String()),
+			attribute.String("http.body.content_type", contentType),
+			attribute.Int("http.body.size", c.size),
+			attribute.Bool("http.body.truncated", c.size > c.buf.Len()),
+		))
+	})
+}
+
+// Body reader teeing what is read into a capture, recording it at EOF or Close.
+type captureReadCloser struct {
+	io.ReadCloser
+	capture *bodyCapture
+	onDone  func()
+}
+
+func (r *captureReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	_, _ = r.capture.Write(p[:n])
+	if err == io.EOF {
+		r.onDone()
+	}
+	return n, err
+}
+
+func (r *captureReadCloser) Close() error {
+	r.onDone()
+	return r.ReadCloser.Close()
+}
+
+// Opt-in server middleware recording truncated request and response bodies
+// as span events. It must be installed after the tracing middleware.
+// Only the part of the request body read by the handler is recorded.
+func BodyCaptureMiddleware(opts ...BodyCaptureOption) func(http.Handler) http.Handler {
+	cfg := newBodyCaptureConfig(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := trace.SpanFromContext(r.Context())
+			if !span.IsRecording() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reqCapture := &bodyCapture{max: cfg.maxBytes}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &captureReadCloser{ReadCloser: r.Body, capture: reqCapture, onDone: func() {}}
+			}
+			resCapture := &bodyCapture{max: cfg.maxBytes}
+			ww := httpsnoop.Wrap(w, httpsnoop.Hooks{
+				Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+					return func(b []byte) (int, error) {
+						n, err := next(b)
+						_, _ = resCapture.Write(b[:n])
+						return n, err
+					}
+				},
+				ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+					return func(src io.Reader) (int64, error) {
+						return next(io.TeeReader(src, resCapture))
+					}
+				},
+			})
+
+			next.ServeHTTP(ww, r)
+
+			reqCapture.record(span, cfg, "http.request.body", r.Header.Get("Content-Type"))
+			resCapture.record(span, cfg, "http.response.body", w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+// Opt-in client transport recording truncated request and response bodies
+// as span events. It must be wrapped by the tracing transport, e.g.
+// otelhttp.NewTransport(telemetry.NewBodyCaptureTransport(http.DefaultTransport)).
+func NewBodyCaptureTransport(base http.RoundTripper, opts ...BodyCaptureOption) http.RoundTripper {
+	return &bodyCaptureTransport{base: base, cfg: newBodyCaptureConfig(opts)}
+}
+
+type bodyCaptureTransport struct {
+	base http.RoundTripper
+	cfg  bodyCaptureConfig
+}
+
+func (t *bodyCaptureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	span := trace.SpanFromContext(req.Context())
+	if !span.IsRecording() {
+		return t.base.RoundTrip(req)
+	}
+
+	reqCapture := &bodyCapture{max: t.cfg.maxBytes}
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &captureReadCloser{ReadCloser: req.Body, capture: reqCapture, onDone: func() {}}
+	}
+
+	res, err := t.base.RoundTrip(req)
+	reqCapture.record(span, t.cfg, "http.request.body", req.Header.Get("Content-Type"))
+	if err != nil {
+		return res, err
+	}
+
+	resCapture := &bodyCapture{max: t.cfg.maxBytes}
+	contentType := res.Header.Get("Content-Type")
+	res.Body = &captureReadCloser{
+		ReadCloser: res.Body,
+		capture:    resCapture,
+		onDone:     func() { resCapture.record(span, t.cfg, "http.response.body", contentType) },
+	}
+	return res, nil
+}
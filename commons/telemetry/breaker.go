@@ -0,0 +1,140 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Reason recorded for spans dropped while the export circuit is open.
+const DropReasonCircuitOpen = "circuit_open"
+
+// Error returned for the batches dropped while the circuit is open.
+var ErrCircuitOpen = errors.New("span export circuit open")
+
+// Stops exporting for cooldown after failures consecutive export failures,
+// dropping spans meanwhile, so a stalled collector does not hold the export
+// path and the requests sharing its resources.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(c *Config) {
+		c.BreakerFailures = failures
+		c.BreakerCooldown = cooldown
+	}
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// Span exporter short-circuiting next while it keeps failing. Once the
+// cooldown elapsed, a single trial export decides whether the circuit closes
+// again or stays open for another cooldown.
+type circuitBreakerExporter struct {
+	next      sdktrace.SpanExporter
+	threshold int
+	cooldown  time.Duration
+	dropped   metric.Int64Counter
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// Wraps next into a circuit breaker opening after failures consecutive
+// failures and half-opening once cooldown elapsed. Dropped spans are counted
+// in the telemetry.spans.dropped counter.
+func NewCircuitBreakerExporter(next sdktrace.SpanExporter, failures int, cooldown time.Duration) sdktrace.SpanExporter {
+	if failures < 1 {
+		failures = 1
+	}
+	counter, err := Meter(instrumentationName, "").Int64Counter(
+		"telemetry.spans.dropped",
+		metric.WithDescription("Spans dropped before export, by reason."),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	RegisterMetrics(Descriptor{
+		Name:        "telemetry.spans.dropped",
+		Kind:        "counter",
+		Description: "Spans dropped before export, by reason.",
+		Attributes:  []string{string(DropReasonKey)},
+	})
+	return &circuitBreakerExporter{next: next, threshold: failures, cooldown: cooldown, dropped: counter}
+}
+
+func (e *circuitBreakerExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if !e.allow() {
+		e.dropped.Add(ctx, int64(len(spans)), metric.WithAttributes(DropReasonKey.String(DropReasonCircuitOpen)))
+		return ErrCircuitOpen
+	}
+	err := e.next.ExportSpans(ctx, spans)
+	e.record(err)
+	return err
+}
+
+// Reports whether an export may go through, moving an open circuit whose
+// cooldown elapsed to half-open for a single trial.
+func (e *circuitBreakerExporter) allow() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch e.state {
+	case circuitOpen:
+		if time.Since(e.openedAt) < e.cooldown {
+			return false
+		}
+		e.setState(circuitHalfOpen)
+		return true
+	case circuitHalfOpen:
+		// the trial export is in flight
+		return false
+	}
+	return true
+}
+
+func (e *circuitBreakerExporter) record(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		e.failures = 0
+		e.setState(circuitClosed)
+		return
+	}
+	e.failures++
+	if e.state == circuitHalfOpen || e.failures >= e.threshold {
+		e.openedAt = time.Now()
+		e.setState(circuitOpen)
+	}
+}
+
+func (e *circuitBreakerExporter) setState(s circuitState) {
+	if e.state != s {
+		log.Printf("telemetry: span export circuit %s", s)
+		e.state = s
+	}
+}
+
+func (e *circuitBreakerExporter) Shutdown(ctx context.Context) error {
+	return e.next.Shutdown(ctx)
+}
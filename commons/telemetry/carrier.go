@@ -0,0 +1,22 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Returns the trace context and baggage of ctx as a map, e.g. to store in a
+// job payload, a database row or a custom protocol message.
+func InjectMap(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// Returns a copy of ctx carrying the trace context and baggage stored in m by
+// InjectMap. A nil or empty map leaves ctx unchanged.
+func ExtractMap(ctx context.Context, m map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(m))
+}
@@ -0,0 +1,21 @@
+package telemetry
+
+import "time"
+
+// Source of time of the helpers measuring or waiting, replaced in tests to
+// assert span timings and retry delays without sleeping, e.g. with
+// teletest.FakeClock.
+type Clock interface {
+	Now() time.Time
+	// delivers the time once d elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// Clock of the running system.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,175 @@
+package telemetry
+
+import (
+	"context"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes of the spans standing for a run of compressed spans.
+const (
+	// number of spans of the run
+	CompressedCountKey = attribute.Key("span.compressed.count")
+	// sum of the durations of the spans of the run, in milliseconds
+	CompressedDurationKey = attribute.Key("span.compressed.duration_sum_ms")
+)
+
+// Runs older than this are exported even though their parent did not end.
+const compressionMaxAge = 5 * time.Second
+
+// Collapses runs of sibling spans sharing their name, matching one of the
+// patterns (with * and ? wildcards) and lasting at most maxDuration, e.g.
+// the GETs of a chatty cache loop, into one span spanning the whole run.
+func WithSpanCompression(maxDuration time.Duration, patterns ...string) Option {
+	return func(c *Config) {
+		c.CompressionMaxDuration = maxDuration
+		c.CompressionPatterns = patterns
+	}
+}
+
+// Identifies the children of a span.
+type parentKey struct {
+	traceID trace.TraceID
+	spanID  trace.SpanID
+}
+
+// Consecutive sibling spans of the same name.
+type spanRun struct {
+	spans   []sdktrace.ReadOnlySpan
+	started time.Time
+}
+
+// Span processor compressing runs of short identical sibling spans before
+// handing them to next. A run is exported when a sibling of another name
+// ends, when the parent ends, or after 5 seconds.
+type compressingSpanProcessor struct {
+	next        sdktrace.SpanProcessor
+	maxDuration time.Duration
+	patterns    []string
+
+	mu   sync.Mutex
+	runs map[parentKey]*spanRun
+}
+
+func NewCompressingSpanProcessor(next sdktrace.SpanProcessor, maxDuration time.Duration, patterns ...string) sdktrace.SpanProcessor {
+	return &compressingSpanProcessor{
+		next:        next,
+		maxDuration: maxDuration,
+		patterns:    patterns,
+		runs:        map[parentKey]*spanRun{},
+	}
+}
+
+func (p *compressingSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *compressingSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	var ready []sdktrace.ReadOnlySpan
+	p.mu.Lock()
+	// the runs of the children end with their parent
+	self := parentKey{s.SpanContext().TraceID(), s.SpanContext().SpanID()}
+	if run, ok := p.runs[self]; ok {
+		ready = append(ready, compressRun(run.spans))
+		delete(p.runs, self)
+	}
+	ready = append(ready, p.expired()...)
+
+	key := parentKey{s.Parent().TraceID(), s.Parent().SpanID()}
+	run, ok := p.runs[key]
+	if ok && run.spans[0].Name() != s.Name() {
+		ready = append(ready, compressRun(run.spans))
+		delete(p.runs, key)
+		ok = false
+	}
+	switch {
+	case !p.compressible(s):
+		ready = append(ready, s)
+	case ok:
+		run.spans = append(run.spans, s)
+	default:
+		p.runs[key] = &spanRun{spans: []sdktrace.ReadOnlySpan{s}, started: time.Now()}
+	}
+	p.mu.Unlock()
+
+	for _, span := range ready {
+		p.next.OnEnd(span)
+	}
+}
+
+func (p *compressingSpanProcessor) compressible(s sdktrace.ReadOnlySpan) bool {
+	if !s.Parent().IsValid() || s.Status().Code == codes.Error || s.EndTime().Sub(s.StartTime()) > p.maxDuration {
+		return false
+	}
+	for _, pattern := range p.patterns {
+		if ok, _ := path.Match(pattern, s.Name()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes and returns the runs older than compressionMaxAge.
+func (p *compressingSpanProcessor) expired() []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for key, run := range p.runs {
+		if time.Since(run.started) > compressionMaxAge {
+			spans = append(spans, compressRun(run.spans))
+			delete(p.runs, key)
+		}
+	}
+	return spans
+}
+
+// Exports every pending run.
+func (p *compressingSpanProcessor) flush() {
+	p.mu.Lock()
+	var spans []sdktrace.ReadOnlySpan
+	for key, run := range p.runs {
+		spans = append(spans, compressRun(run.spans))
+		delete(p.runs, key)
+	}
+	p.mu.Unlock()
+	for _, span := range spans {
+		p.next.OnEnd(span)
+	}
+}
+
+// Returns the span standing for the run: the first span of the run,
+// stretched to the end of the last one, with the count and total duration
+// of the run.
+func compressRun(spans []sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	if len(spans) == 1 {
+		return spans[0]
+	}
+	run := snapshotOf(spans[0])
+	var total time.Duration
+	for _, s := range spans {
+		total += s.EndTime().Sub(s.StartTime())
+		if s.EndTime().After(run.end) {
+			run.end = s.EndTime()
+		}
+	}
+	run.attributes = append(slices.Clip(run.attributes),
+		CompressedCountKey.Int(len(spans)),
+		CompressedDurationKey.Float64(float64(total)/float64(time.Millisecond)),
+	)
+	return run
+}
+
+func (p *compressingSpanProcessor) Shutdown(ctx context.Context) error {
+	p.flush()
+	return p.next.Shutdown(ctx)
+}
+
+func (p *compressingSpanProcessor) ForceFlush(ctx context.Context) error {
+	p.flush()
+	return p.next.ForceFlush(ctx)
+}
@@ -0,0 +1,307 @@
+package telemetry
+
+import (
+	"crypto/tls"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Settings used to build the trace and metric providers.
+type Config struct {
+	// the service name used to display traces in backends
+	ServiceName string
+	// reported as service.version, BuildVersion() by default
+	ServiceVersion string
+	// attach trace/span IDs of sampled spans to metric measurements
+	Exemplars bool
+	// views customizing metric streams, the first matching one wins
+	MetricViews []sdkmetric.View
+	// interval between metric exports, from OTEL_METRIC_EXPORT_INTERVAL
+	// or DefaultMetricInterval
+	MetricInterval time.Duration
+	// maximum duration of one metric export, from OTEL_METRIC_EXPORT_TIMEOUT
+	MetricExportTimeout time.Duration
+	// push metrics through Prometheus remote-write instead of OTLP
+	RemoteWrite *RemoteWriteConfig
+	// mirror metrics to a StatsD sink, on top of the regular export
+	StatsD *StatsDConfig
+	// in-process span view, disabled when nil
+	ZPages *ZPages
+	// ring buffer of finished spans streamed as NDJSON, disabled when nil
+	SpanTail *SpanTail
+	// sampling ratio applied for DegradedPeriod after the collector rejected
+	// spans, disabled when the period is zero
+	DegradedSamplingRatio float64
+	DegradedPeriod        time.Duration
+	// degraded mode shared by the collector connection and the sampler
+	degraded *degradedMode
+	// runs of sibling spans matching the patterns and lasting at most
+	// CompressionMaxDuration are collapsed into one span
+	CompressionMaxDuration time.Duration
+	CompressionPatterns    []string
+	// consecutive export failures opening the export circuit, disabled when
+	// zero, and how long it stays open
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	// directory of the write-ahead log of spans, disabled when empty
+	QueueDir string
+	// size cap of the write-ahead log, unlimited when zero
+	QueueMaxBytes int64
+	// runtime switchboard of tracing, sampling and export, disabled when nil
+	Controller *Controller
+	// continuous profiler started with the providers
+	Profiler Profiler
+	// reader collecting metrics instead of the periodic export, e.g. a
+	// manual reader in tests
+	MetricReader sdkmetric.Reader
+	// temporality of exported metrics, from the environment when nil
+	Temporality sdkmetric.TemporalitySelector
+	// default aggregation of instruments, latency buckets for histograms
+	Aggregation sdkmetric.AggregationSelector
+	// tuning of the batch span processors, e.g. from OTEL_BSP_* through envconfig
+	Batch BatchConfig
+	// caps on span attributes, events, links and attribute values
+	SpanLimits sdktrace.SpanLimits
+	// count what the span limits drop in telemetry.span.limited
+	TruncationMetric bool
+	// service level objectives judged on the server spans, counted in
+	// slo.spans
+	SLOs []SLO
+	// export spans as they end instead of batching them, development only
+	SyncExport bool
+	// export error spans through a dedicated fast-path queue
+	ErrorPriority bool
+	// generator for trace and span IDs, the SDK's random one when nil
+	IDGenerator sdktrace.IDGenerator
+	// sampler for new traces, GetSampler() when nil
+	Sampler sdktrace.Sampler
+	// sampling ratios of new traces per tenant, overriding Sampler
+	TenantSampling map[string]float64
+	// spans per second handed to the exporters, unlimited when 0
+	SpanRateLimit float64
+	// spans exported at once above SpanRateLimit
+	SpanRateBurst int
+	// sampling and rate limiting of log records before export, disabled when
+	// nil
+	LogSampling *LogSamplingConfig
+	// scrubs span attributes and log records before export when set
+	Redactor *Redactor
+	// exporters receiving spans alongside the OTLP collector
+	Exporters []NamedSpanExporter
+	// secondary tracer providers returned by Provider, by name
+	Providers map[string]ProviderConfig
+	// predicates dropping finished spans before export
+	SpanFilters []SpanPredicate
+	// registered span processors spans go through, in order, ahead of the
+	// built-in ones
+	SpanPipeline []ProcessorSpec
+	// YAML file of settings reloaded while running, none when empty
+	ConfigFile string
+	// settings of ConfigFile applied while running
+	reload *configReloader
+	// attributes added to the resource, e.g. how the service is reached
+	ResourceAttributes []attribute.KeyValue
+	// detect container, Kubernetes and cloud provider resource attributes
+	CloudDetection bool
+	// upper bound for querying cloud metadata endpoints
+	CloudDetectionTimeout time.Duration
+	// warn about span names containing ID-like tokens
+	SpanNameCheck bool
+	// TLS settings of the collector connection, plaintext when nil and no
+	// certificate file is set
+	TLSConfig *tls.Config
+	// PEM file of the CA certificates the collector is verified against
+	CACertFile string
+	// PEM files of the client certificate and key presented to the collector
+	ClientCertFile string
+	ClientKeyFile  string
+	// headers sent with every export
+	Headers map[string]string
+	// source of the bearer token sent with every export
+	TokenProvider TokenProvider
+	// upper bound for each connection attempt to the collector
+	DialTimeout time.Duration
+	// upper bound of each OTLP export, OTEL_EXPORTER_OTLP_TIMEOUT as read by
+	// the exporters when zero
+	OTLPTimeout time.Duration
+	// notified of every connectivity change of the collector connection
+	ConnectionStateHandler func(connectivity.State)
+}
+
+// Span exporter identified by a name, e.g. "console" or "zipkin".
+type NamedSpanExporter struct {
+	Name     string
+	Exporter sdktrace.SpanExporter
+}
+
+// Customizes the telemetry Config.
+type Option func(*Config)
+
+// Returns the Config InitProvider builds for the service from opts, e.g.
+// those envconfig reads from the OTEL_* variables, to check it with
+// ValidateConfig.
+func NewConfig(serviceName string, opts ...Option) Config {
+	cfg := Config{
+		ServiceName:    serviceName,
+		ServiceVersion: BuildVersion(),
+		Exemplars:      true,
+		Aggregation:    defaultAggregation,
+		DialTimeout:    5 * time.Second,
+		SpanLimits:     defaultSpanLimits(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Enables or disables exemplars on metric measurements. When enabled,
+// measurements recorded within a sampled span carry its trace and span IDs so
+// backends can jump from a histogram bucket straight to the trace.
+func WithExemplars(enabled bool) Option {
+	return func(c *Config) {
+		c.Exemplars = enabled
+	}
+}
+
+// Routes spans with an error status through a small, short-delay batch queue
+// so error traces are exported ahead of the regular batch queue.
+func WithErrorPriority(enabled bool) Option {
+	return func(c *Config) {
+		c.ErrorPriority = enabled
+	}
+}
+
+// Replaces the generator of trace and span IDs, e.g. with NewXRayIDGenerator
+// to export to AWS X-Ray or NewDeterministicIDGenerator for reproducible IDs.
+func WithIDGenerator(gen sdktrace.IDGenerator) Option {
+	return func(c *Config) {
+		c.IDGenerator = gen
+	}
+}
+
+// Replaces the sampler chosen from GO_ENV, e.g. with a DynamicSampler.
+func WithSampler(sampler sdktrace.Sampler) Option {
+	return func(c *Config) {
+		c.Sampler = sampler
+	}
+}
+
+// Scrubs span, event and link attributes, and log record bodies and
+// attributes, with the given Redactor before they are exported.
+func WithRedaction(redactor *Redactor) Option {
+	return func(c *Config) {
+		c.Redactor = redactor
+	}
+}
+
+// Sends spans to exp as well as to the OTLP collector. Each exporter gets its
+// own batch queue so failures stay isolated.
+func WithExporter(name string, exp sdktrace.SpanExporter) Option {
+	return func(c *Config) {
+		c.Exporters = append(c.Exporters, NamedSpanExporter{Name: name, Exporter: exp})
+	}
+}
+
+// Drops finished spans matching any of the predicates before they are
+// batched for export.
+func WithSpanFilters(drop ...SpanPredicate) Option {
+	return func(c *Config) {
+		c.SpanFilters = append(c.SpanFilters, drop...)
+	}
+}
+
+// Runs finished spans through the registered span processors named by
+// specs, in order, before the built-in filtering and redaction. Unknown
+// processors and invalid parameters fail the init.
+func WithSpanPipeline(specs ...ProcessorSpec) Option {
+	return func(c *Config) {
+		c.SpanPipeline = append(c.SpanPipeline, specs...)
+	}
+}
+
+// Enriches the resource with container, Kubernetes downward-API and AWS, GCP
+// or Azure attributes (cloud.region, k8s.pod.name, ...). Metadata endpoints
+// are given at most timeout to answer.
+func WithCloudDetection(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.CloudDetection = true
+		c.CloudDetectionTimeout = timeout
+	}
+}
+
+// Overrides the service name given to InitProvider, e.g. with
+// OTEL_SERVICE_NAME.
+func WithServiceName(name string) Option {
+	return func(c *Config) {
+		c.ServiceName = name
+	}
+}
+
+// Overrides the service.version resource attribute taken from build info.
+func WithVersion(version string) Option {
+	return func(c *Config) {
+		c.ServiceVersion = version
+	}
+}
+
+// Bounds each attempt to connect to the collector. Connecting never blocks
+// the application start: exporters retry in the background while the
+// collector is unreachable.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.DialTimeout = timeout
+	}
+}
+
+// Bounds each OTLP export of spans, metrics and log records.
+func WithOTLPTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.OTLPTimeout = timeout
+	}
+}
+
+// Calls handler with every connectivity change of the collector connection,
+// e.g. to log when telemetry infrastructure is degraded.
+func WithConnectionStateHandler(handler func(connectivity.State)) Option {
+	return func(c *Config) {
+		c.ConnectionStateHandler = handler
+	}
+}
+
+// Logs a warning for every distinct span name containing an ID-like token,
+// see SpanNamer for names that keep cardinality low.
+func WithSpanNameCheck(enabled bool) Option {
+	return func(c *Config) {
+		c.SpanNameCheck = enabled
+	}
+}
+
+// Samples new traces of the listed tenants with their own ratio, e.g.
+// {"acme": 0.1} keeps one trace in ten for acme. The tenant is set by
+// TenantMiddleware; other tenants keep the configured sampler.
+func WithTenantSampling(ratios map[string]float64) Option {
+	return func(c *Config) {
+		c.TenantSampling = ratios
+	}
+}
+
+// Adds attrs to the resource describing the service.
+func WithResourceAttributes(attrs ...attribute.KeyValue) Option {
+	return func(c *Config) {
+		c.ResourceAttributes = append(c.ResourceAttributes, attrs...)
+	}
+}
+
+// Caps the spans exported to perSecond, allowing bursts of burst spans.
+// Spans above the limit are dropped and counted in telemetry.spans.dropped.
+func WithSpanRateLimit(perSecond float64, burst int) Option {
+	return func(c *Config) {
+		c.SpanRateLimit = perSecond
+		c.SpanRateBurst = burst
+	}
+}
@@ -0,0 +1,87 @@
+package telemetry
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Dials the collector without blocking so applications start even when the
+// telemetry infrastructure is down; exporters keep retrying in the
+// background and each connection attempt is bounded by the dial timeout.
+func dialCollector(cfg Config, addr string, extra ...grpc.DialOption) (*grpc.ClientConn, error) {
+	creds, err := collectorCredentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: cfg.DialTimeout,
+		}),
+	}
+	if cfg.TokenProvider != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials{cfg.TokenProvider}))
+	}
+	opts = append(opts, extra...)
+	// unix:// addresses are dialed natively
+	checkUnixEndpoint(addr)
+	target := addr
+	if cfg.reload != nil {
+		// resolved by the config file, which may change the endpoint
+		target = cfg.reload.target(addr)
+		opts = append(opts, grpc.WithResolvers(cfg.reload.resolver), grpc.WithContextDialer(dialCollectorAddress))
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// leave idle mode right away instead of waiting for the first export
+	conn.Connect()
+	return conn, nil
+}
+
+// Tracks the connectivity of the collector connection, reporting every
+// change to the configured handler and through the
+// telemetry.exporter.connected gauge.
+type connectionWatcher struct {
+	connected atomic.Bool
+}
+
+// Reports state changes of conn until ctx is done.
+func (w *connectionWatcher) watch(ctx context.Context, conn *grpc.ClientConn, handler func(connectivity.State)) {
+	state := conn.GetState()
+	for {
+		w.connected.Store(state == connectivity.Ready)
+		if handler != nil {
+			handler(state)
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		state = conn.GetState()
+	}
+}
+
+// Registers the telemetry.exporter.connected gauge, 1 while the collector
+// connection is ready and 0 otherwise.
+func (w *connectionWatcher) register(meter metric.Meter) error {
+	_, err := meter.Int64ObservableGauge(
+		"telemetry.exporter.connected",
+		metric.WithDescription("Whether the connection to the OTLP collector is ready"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			var v int64
+			if w.connected.Load() {
+				v = 1
+			}
+			o.Observe(v)
+			return nil
+		}),
+	)
+	return err
+}
@@ -0,0 +1,232 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters the Controller switches between.
+const (
+	// exports to the configured collector
+	ControllerExporterOTLP = "otlp"
+	// logs every span instead of exporting it
+	ControllerExporterConsole = "console"
+)
+
+// Runtime switchboard of the telemetry pipeline, letting operators disable
+// tracing, change the sampling ratio, redirect spans to the console and
+// flush the providers without restarting the service. Components are
+// swapped atomically, so spans in flight are never blocked.
+type Controller struct {
+	disabled atomic.Bool
+	sampler  *DynamicSampler
+	exporter *switchingExporter
+	flush    func(context.Context) error
+}
+
+// Creates a controller changing the ratio of sampler, or of a DynamicSampler
+// wrapping the configured one when sampler is nil. It takes effect once
+// given to InitProvider with WithController.
+func NewController(sampler *DynamicSampler) *Controller {
+	return &Controller{sampler: sampler, exporter: &switchingExporter{}}
+}
+
+// Places the pipeline under the control of c.
+func WithController(c *Controller) Option {
+	return func(cfg *Config) {
+		cfg.Controller = c
+	}
+}
+
+// Turns tracing on or off, spans started while off not being recorded.
+func (c *Controller) SetTracing(enabled bool) {
+	c.disabled.Store(!enabled)
+}
+
+func (c *Controller) TracingEnabled() bool {
+	return !c.disabled.Load()
+}
+
+// Samples root traces with the given ratio, in the [0, 1] range.
+func (c *Controller) SetSamplingRatio(ratio float64) error {
+	if c.sampler == nil {
+		return errors.New("telemetry controller is not installed")
+	}
+	return c.sampler.SetRatio(ratio)
+}
+
+// Exports to the named exporter, ControllerExporterOTLP or
+// ControllerExporterConsole.
+func (c *Controller) SetExporter(name string) error {
+	return c.exporter.use(name)
+}
+
+func (c *Controller) Exporter() string {
+	return c.exporter.name()
+}
+
+// Exports everything the providers buffer.
+func (c *Controller) ForceFlush(ctx context.Context) error {
+	if c.flush == nil {
+		return errors.New("telemetry controller is not installed")
+	}
+	return c.flush(ctx)
+}
+
+// Wraps the sampler chain, so the ratio and the on/off switch apply last.
+func (c *Controller) wrapSampler(sampler sdktrace.Sampler) sdktrace.Sampler {
+	if c.sampler == nil {
+		c.sampler = NewDynamicSampler(sampler)
+		sampler = c.sampler
+	}
+	return controlledSampler{controller: c, next: sampler}
+}
+
+// Wraps the collector exporter, so spans can be redirected to the console.
+func (c *Controller) wrapExporter(exp sdktrace.SpanExporter) sdktrace.SpanExporter {
+	c.exporter.otlp = exp
+	c.exporter.current.Store(&selectedExporter{name: ControllerExporterOTLP, exporter: exp})
+	return c.exporter
+}
+
+// Admin handler of the controller, every request having to carry
+// "Authorization: Bearer <token>". GET returns the current state, while POST
+// to the following paths, relative to the mount point, changes it:
+//
+//	/tracing   enabled=true|false
+//	/sampler   ratio=0.25
+//	/exporter  name=otlp|console
+//	/flush
+func (c *Controller) Handler(token string) http.Handler {
+	return requireToken(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if err := c.apply(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		state := map[string]any{
+			"tracing":  c.TracingEnabled(),
+			"exporter": c.Exporter(),
+		}
+		if c.sampler != nil {
+			mode, ratio := c.sampler.State()
+			state["sampling_mode"], state["sampling_ratio"] = mode, ratio
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(state)
+	}))
+}
+
+// Serves next only to requests carrying "Authorization: Bearer <token>",
+// refusing every request when token is empty.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Controller) apply(r *http.Request) error {
+	switch action := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]; action {
+	case "tracing":
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			return fmt.Errorf("invalid enabled value %q", r.FormValue("enabled"))
+		}
+		c.SetTracing(enabled)
+	case "sampler":
+		ratio, err := strconv.ParseFloat(r.FormValue("ratio"), 64)
+		if err != nil {
+			return fmt.Errorf("invalid ratio %q", r.FormValue("ratio"))
+		}
+		return c.SetSamplingRatio(ratio)
+	case "exporter":
+		return c.SetExporter(r.FormValue("name"))
+	case "flush":
+		return c.ForceFlush(r.Context())
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+	return nil
+}
+
+// Sampler dropping every span while the controller has tracing off.
+type controlledSampler struct {
+	controller *Controller
+	next       sdktrace.Sampler
+}
+
+func (s controlledSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if s.controller.disabled.Load() {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.Drop,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s controlledSampler) Description() string {
+	return fmt.Sprintf("Controlled{%s}", s.next.Description())
+}
+
+type selectedExporter struct {
+	name     string
+	exporter sdktrace.SpanExporter
+}
+
+// Span exporter forwarding to the exporter currently selected.
+type switchingExporter struct {
+	otlp    sdktrace.SpanExporter
+	current atomic.Pointer[selectedExporter]
+}
+
+func (e *switchingExporter) use(name string) error {
+	switch name {
+	case ControllerExporterOTLP:
+		if e.otlp == nil {
+			return errors.New("telemetry controller is not installed")
+		}
+		e.current.Store(&selectedExporter{name: name, exporter: e.otlp})
+	case ControllerExporterConsole:
+		e.current.Store(&selectedExporter{name: name, exporter: logExporter{}})
+	default:
+		return fmt.Errorf("unknown exporter %q", name)
+	}
+	return nil
+}
+
+func (e *switchingExporter) name() string {
+	if current := e.current.Load(); current != nil {
+		return current.name
+	}
+	return ""
+}
+
+func (e *switchingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.current.Load().exporter.ExportSpans(ctx, spans)
+}
+
+func (e *switchingExporter) Shutdown(ctx context.Context) error {
+	return e.otlp.Shutdown(ctx)
+}
@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+const (
+	// time left before the context deadline when the span started
+	DeadlineRemainingKey = attribute.Key("context.deadline.remaining_ms")
+	// why the context was done before the work completed
+	CancelReasonKey = attribute.Key("context.cancel.reason")
+)
+
+// Records on the span in ctx how much time is left before the context
+// deadline, if it has one.
+func RecordDeadline(ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok {
+		trace.SpanFromContext(ctx).SetAttributes(DeadlineRemainingKey.Int64(time.Until(deadline).Milliseconds()))
+	}
+}
+
+// Adds a "context canceled" event to the span in ctx if ctx is done before
+// stop is called, so truncated traces are explainable. The reason is
+// "deadline_exceeded" or "canceled".
+func WatchCancellation(ctx context.Context) (stop func() bool) {
+	return watchCancellation(ctx, "canceled")
+}
+
+func watchCancellation(ctx context.Context, canceled string) func() bool {
+	span := trace.SpanFromContext(ctx)
+	return context.AfterFunc(ctx, func() {
+		reason := canceled
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = "deadline_exceeded"
+		}
+		attrs := []attribute.KeyValue{CancelReasonKey.String(reason)}
+		if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+			attrs = append(attrs, attribute.String("context.cancel.cause", cause.Error()))
+		}
+		span.AddEvent("context canceled", trace.WithAttributes(attrs...))
+	})
+}
+
+// Middleware recording the request deadline on entry, and a cancellation
+// event when the client disconnects or the deadline passes before the
+// handler returns. It must be installed after the tracing middleware.
+func DeadlineMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordDeadline(r.Context())
+		// the server only cancels request contexts when the client goes away
+		stop := watchCancellation(r.Context(), "client_disconnected")
+		defer stop()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// gRPC interceptor recording the deadline propagated by the client, and a
+// cancellation event when the call is canceled or times out before the
+// handler returns.
+func DeadlineUnaryServerInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	RecordDeadline(ctx)
+	stop := watchCancellation(ctx, "client_disconnected")
+	defer stop()
+	return handler(ctx, req)
+}
@@ -0,0 +1,150 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Sampling strategy applied by the DynamicSampler.
+type SamplingMode string
+
+const (
+	// keeps whatever sampler the DynamicSampler was created with
+	SamplingInitial SamplingMode = "initial"
+	// samples every trace
+	SamplingAlwaysOn SamplingMode = "always_on"
+	// drops every trace
+	SamplingAlwaysOff SamplingMode = "always_off"
+	// honors the parent decision, sampling root traces by ratio
+	SamplingRatio SamplingMode = "ratio"
+)
+
+type samplerState struct {
+	mode    SamplingMode
+	ratio   float64
+	sampler sdktrace.Sampler
+}
+
+// Sampler that can be reconfigured at runtime, so operators can crank
+// sampling up during incidents without restarting the service.
+type DynamicSampler struct {
+	mu      sync.Mutex // serializes writers
+	initial sdktrace.Sampler
+	current atomic.Pointer[samplerState]
+}
+
+var _ sdktrace.Sampler = (*DynamicSampler)(nil)
+
+// Creates a DynamicSampler delegating to initial until reconfigured. When
+// initial is a NewRuleSampler, its rules keep applying in every mode, the mode
+// only replacing its fallback.
+func NewDynamicSampler(initial sdktrace.Sampler) *DynamicSampler {
+	s := &DynamicSampler{initial: initial}
+	s.current.Store(&samplerState{mode: SamplingInitial, ratio: 1, sampler: initial})
+	return s
+}
+
+func (s *DynamicSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return s.current.Load().sampler.ShouldSample(p)
+}
+
+func (s *DynamicSampler) Description() string {
+	return fmt.Sprintf("DynamicSampler{%s}", s.current.Load().sampler.Description())
+}
+
+// Returns the active sampling mode and ratio.
+func (s *DynamicSampler) State() (SamplingMode, float64) {
+	st := s.current.Load()
+	return st.mode, st.ratio
+}
+
+// Switches to ratio sampling with the given ratio, in the [0, 1] range.
+func (s *DynamicSampler) SetRatio(ratio float64) error {
+	if math.IsNaN(ratio) || ratio < 0 || ratio > 1 {
+		return fmt.Errorf("sampling ratio %v out of range [0, 1]", ratio)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current.Store(s.build(SamplingRatio, ratio))
+	return nil
+}
+
+// Switches the sampling mode, keeping the last configured ratio.
+func (s *DynamicSampler) SetMode(mode SamplingMode) error {
+	switch mode {
+	case SamplingInitial, SamplingAlwaysOn, SamplingAlwaysOff, SamplingRatio:
+	default:
+		return fmt.Errorf("unknown sampling mode %q", mode)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current.Store(s.build(mode, s.current.Load().ratio))
+	return nil
+}
+
+func (s *DynamicSampler) build(mode SamplingMode, ratio float64) *samplerState {
+	st := &samplerState{mode: mode, ratio: ratio}
+	switch mode {
+	case SamplingAlwaysOn:
+		st.sampler = sdktrace.AlwaysSample()
+	case SamplingAlwaysOff:
+		st.sampler = sdktrace.NeverSample()
+	case SamplingRatio:
+		st.sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	default:
+		st.sampler = s.initial
+		return st
+	}
+	if rules, ok := s.initial.(*ruleSampler); ok {
+		st.sampler = rules.withFallback(st.sampler)
+	}
+	return st
+}
+
+// Admin handler exposing the sampler configuration, every request having to
+// carry "Authorization: Bearer <token>". GET returns the current state, POST
+// accepts "mode" and/or "ratio" form values to reconfigure it.
+func (s *DynamicSampler) Handler(token string) http.Handler {
+	return requireToken(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			// the ratio goes first so an explicit mode always wins
+			if v := r.FormValue("ratio"); v != "" {
+				ratio, err := strconv.ParseFloat(v, 64)
+				if err == nil {
+					err = s.SetRatio(ratio)
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			if mode := r.FormValue("mode"); mode != "" {
+				if err := s.SetMode(SamplingMode(mode)); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		mode, ratio := s.State()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"mode":        mode,
+			"ratio":       ratio,
+			"description": s.Description(),
+		})
+	}))
+}
@@ -0,0 +1,104 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDynamicSamplerKeepsRules(t *testing.T) {
+	s := NewDynamicSampler(NewRuleSampler(sdktrace.NeverSample(), SamplingRule{Route: "/admin/*", Ratio: 0}))
+	decide := func(name string) sdktrace.SamplingDecision {
+		return s.ShouldSample(sdktrace.SamplingParameters{
+			ParentContext: context.Background(),
+			TraceID:       trace.TraceID{1},
+			Name:          name,
+		}).Decision
+	}
+
+	for _, set := range []func() error{
+		func() error { return s.SetRatio(1) },
+		func() error { return s.SetMode(SamplingAlwaysOn) },
+	} {
+		if err := set(); err != nil {
+			t.Fatal(err)
+		}
+		if got := decide("GET /admin/flush"); got != sdktrace.Drop {
+			t.Errorf("%s: admin route decision = %v, want Drop", s.Description(), got)
+		}
+		if got := decide("GET /packages/1"); got != sdktrace.RecordAndSample {
+			t.Errorf("%s: other route decision = %v, want RecordAndSample", s.Description(), got)
+		}
+	}
+}
+
+func TestDynamicSamplerRejectsInvalidRatios(t *testing.T) {
+	s := NewDynamicSampler(sdktrace.AlwaysSample())
+	for _, ratio := range []float64{-0.1, 1.5, math.NaN()} {
+		if err := s.SetRatio(ratio); err == nil {
+			t.Errorf("SetRatio(%v) succeeded, want an error", ratio)
+		}
+	}
+	if mode, _ := s.State(); mode != SamplingInitial {
+		t.Errorf("mode = %s after rejected ratios, want %s", mode, SamplingInitial)
+	}
+}
+
+func TestDynamicSamplerHandler(t *testing.T) {
+	s := NewDynamicSampler(sdktrace.AlwaysSample())
+	h := s.Handler("secret")
+	post := func(token, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/debug/sampling", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if rec := post(token, "mode=always_off"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status %d, want %d", token, rec.Code, http.StatusUnauthorized)
+		}
+	}
+	if mode, _ := s.State(); mode != SamplingInitial {
+		t.Fatalf("unauthorized request changed the mode to %s", mode)
+	}
+
+	for _, tt := range []struct {
+		form  string
+		mode  SamplingMode
+		ratio float64
+	}{
+		{"mode=always_off", SamplingAlwaysOff, 1},
+		{"ratio=0.25", SamplingRatio, 0.25},
+		{"ratio=0.5&mode=always_on", SamplingAlwaysOn, 0.5},
+	} {
+		rec := post("secret", tt.form)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.form, rec.Code, rec.Body)
+		}
+		var state struct {
+			Mode  SamplingMode `json:"mode"`
+			Ratio float64      `json:"ratio"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Mode != tt.mode || state.Ratio != tt.ratio {
+			t.Errorf("%s: state %s %v, want %s %v", tt.form, state.Mode, state.Ratio, tt.mode, tt.ratio)
+		}
+	}
+	if rec := post("secret", "mode=sometimes"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,136 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute of the log records of domain events holding the event name.
+const EventNameKey = attribute.Key("event.name")
+
+// How a domain event is reported besides its span event.
+type EventConfig struct {
+	Description string
+	// severity of the log record, info when unset
+	Severity otellog.Severity
+	// counter incremented by each event, none when empty
+	Counter string
+	// keys of the event attributes recorded on the counter, none by default
+	// to keep its cardinality bounded
+	CounterAttributes []string
+}
+
+// Domain event defined by DefineEvent.
+type eventDefinition struct {
+	severity otellog.Severity
+	counter  metric.Int64Counter
+	keys     map[attribute.Key]bool
+}
+
+var events sync.Map
+
+// Configures the domain event name and registers it in the manifest, e.g.
+//
+//	DefineEvent("package.shipped", EventConfig{Counter: "packages.shipped"})
+func DefineEvent(name string, cfg EventConfig) {
+	def := &eventDefinition{severity: cfg.Severity, keys: map[attribute.Key]bool{}}
+	if def.severity == otellog.SeverityUndefined {
+		def.severity = otellog.SeverityInfo
+	}
+	for _, k := range cfg.CounterAttributes {
+		def.keys[attribute.Key(k)] = true
+	}
+	if cfg.Counter != "" {
+		def.counter = NewCounter(instrumentationName, Descriptor{
+			Name:        cfg.Counter,
+			Unit:        "{event}",
+			Description: "Occurrences of the " + name + " event.",
+			Attributes:  cfg.CounterAttributes,
+		})
+	}
+	events.Store(name, def)
+	RegisterEvents(Descriptor{Name: name, Description: cfg.Description})
+}
+
+// Reports the domain event name in every signal: as an event of the span of
+// ctx, as a log record correlated with it, and on the counter of the event
+// when DefineEvent gave it one. Undefined events are logged at info level.
+func Event(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	def := &eventDefinition{severity: otellog.SeverityInfo}
+	if d, ok := events.Load(name); ok {
+		def = d.(*eventDefinition)
+	}
+
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
+
+	var rec otellog.Record
+	rec.SetTimestamp(time.Now())
+	rec.SetSeverity(def.severity)
+	rec.SetSeverityText(def.severity.String())
+	rec.SetBody(otellog.StringValue(name))
+	rec.AddAttributes(otellog.String(string(EventNameKey), name))
+	rec.AddAttributes(logAttributes(attrs)...)
+	global.Logger(instrumentationName).Emit(ctx, rec)
+
+	if def.counter != nil {
+		var kept []attribute.KeyValue
+		for _, kv := range attrs {
+			if def.keys[kv.Key] {
+				kept = append(kept, kv)
+			}
+		}
+		def.counter.Add(ctx, 1, metric.WithAttributes(kept...))
+	}
+}
+
+// Converts trace attributes to log attributes.
+func logAttributes(attrs []attribute.KeyValue) []otellog.KeyValue {
+	out := make([]otellog.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, otellog.KeyValue{Key: string(kv.Key), Value: logValue(kv.Value)})
+	}
+	return out
+}
+
+func logValue(v attribute.Value) otellog.Value {
+	switch v.Type() {
+	case attribute.BOOL:
+		return otellog.BoolValue(v.AsBool())
+	case attribute.INT64:
+		return otellog.Int64Value(v.AsInt64())
+	case attribute.FLOAT64:
+		return otellog.Float64Value(v.AsFloat64())
+	case attribute.STRING:
+		return otellog.StringValue(v.AsString())
+	}
+	// slices keep their elements' type
+	var items []otellog.Value
+	switch v.Type() {
+	case attribute.BOOLSLICE:
+		for _, b := range v.AsBoolSlice() {
+			items = append(items, otellog.BoolValue(b))
+		}
+	case attribute.INT64SLICE:
+		for _, n := range v.AsInt64Slice() {
+			items = append(items, otellog.Int64Value(n))
+		}
+	case attribute.FLOAT64SLICE:
+		for _, f := range v.AsFloat64Slice() {
+			items = append(items, otellog.Float64Value(f))
+		}
+	case attribute.STRINGSLICE:
+		for _, s := range v.AsStringSlice() {
+			items = append(items, otellog.StringValue(s))
+		}
+	default:
+		return otellog.StringValue(v.Emit())
+	}
+	return otellog.SliceValue(items...)
+}
@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+)
+
+// Builds the span exporter of a tracing backend. Every backend is reached
+// over OTLP, adapters only differ in how the endpoint is found and reached.
+type exporterAdapter interface {
+	spanExporter(ctx context.Context) (sdktrace.SpanExporter, error)
+}
+
+// Creates an OTLP span exporter speaking protocol, "grpc" or
+// "http/protobuf", to endpoint, a host:port or a unix:// socket such as
+// unix:///var/run/otel/collector.sock, e.g. to hand to WithExporter.
+func NewOTLPSpanExporter(ctx context.Context, protocol, endpoint string, headers map[string]string) (sdktrace.SpanExporter, error) {
+	switch protocol {
+	case "grpc":
+		return otlpGRPCAdapter{endpoint: endpoint, headers: headers}.spanExporter(ctx)
+	case "http/protobuf":
+		return otlpHTTPAdapter{endpoint: endpoint, headers: headers, dialTimeout: 5 * time.Second}.spanExporter(ctx)
+	}
+	return nil, fmt.Errorf("unsupported OTLP protocol %q", protocol)
+}
+
+// Builds the metric exporter of a metrics backend.
+type metricExporterAdapter interface {
+	metricExporter(ctx context.Context) (sdkmetric.Exporter, error)
+}
+
+// Exports over OTLP/gRPC, through conn when set or to endpoint otherwise,
+// which may be a unix:// socket.
+type otlpGRPCAdapter struct {
+	conn     *grpc.ClientConn
+	endpoint string
+	headers  map[string]string
+	// upper bound of each export, the exporter's default when zero
+	timeout time.Duration
+	// metric export settings
+	aggregation sdkmetric.AggregationSelector
+	temporality sdkmetric.TemporalitySelector
+}
+
+func (a otlpGRPCAdapter) spanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(a.headers)}
+	if a.conn != nil {
+		opts = append(opts, otlptracegrpc.WithGRPCConn(a.conn))
+	} else {
+		checkUnixEndpoint(a.endpoint)
+		opts = append(opts, otlptracegrpc.WithEndpoint(a.endpoint), otlptracegrpc.WithInsecure())
+	}
+	if a.timeout > 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(a.timeout))
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func (a otlpGRPCAdapter) metricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithHeaders(a.headers)}
+	if a.conn != nil {
+		opts = append(opts, otlpmetricgrpc.WithGRPCConn(a.conn))
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(a.endpoint), otlpmetricgrpc.WithInsecure())
+	}
+	if a.timeout > 0 {
+		opts = append(opts, otlpmetricgrpc.WithTimeout(a.timeout))
+	}
+	if a.aggregation != nil {
+		opts = append(opts, otlpmetricgrpc.WithAggregationSelector(a.aggregation))
+	}
+	if a.temporality != nil {
+		opts = append(opts, otlpmetricgrpc.WithTemporalitySelector(a.temporality))
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+func (a otlpGRPCAdapter) logExporter(ctx context.Context) (sdklog.Exporter, error) {
+	opts := []otlploggrpc.Option{otlploggrpc.WithHeaders(a.headers)}
+	if a.conn != nil {
+		opts = append(opts, otlploggrpc.WithGRPCConn(a.conn))
+	} else {
+		opts = append(opts, otlploggrpc.WithEndpoint(a.endpoint), otlploggrpc.WithInsecure())
+	}
+	if a.timeout > 0 {
+		opts = append(opts, otlploggrpc.WithTimeout(a.timeout))
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
+
+// Exports over plaintext OTLP/HTTP to endpoint, which may be a unix://
+// socket.
+type otlpHTTPAdapter struct {
+	endpoint string
+	headers  map[string]string
+	// upper bound of each connection attempt to a socket
+	dialTimeout time.Duration
+}
+
+func (a otlpHTTPAdapter) spanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	if path, ok := unixSocketPath(a.endpoint); ok {
+		checkUnixEndpoint(a.endpoint)
+		return otlptrace.New(ctx, newUnixHTTPClient(path, a.headers, a.dialTimeout))
+	}
+	return otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpoint(a.endpoint),
+		otlptracehttp.WithHeaders(a.headers),
+		otlptracehttp.WithInsecure())
+}
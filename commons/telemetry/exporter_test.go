@@ -0,0 +1,191 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/teletest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Exports a single span through exp, giving up after timeout.
+func exportOne(t *testing.T, exp sdktrace.SpanExporter, timeout time.Duration) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	now := time.Now()
+	return exp.ExportSpans(ctx, tracetest.SpanStubs{{Name: "export-test", StartTime: now, EndTime: now}}.Snapshots())
+}
+
+// Returns the endpoint of the receiver for protocol.
+func receiverEndpoint(r *teletest.Receiver, protocol string) string {
+	if protocol == "grpc" {
+		return r.GRPCAddr
+	}
+	return strings.TrimPrefix(r.HTTPURL, "http://")
+}
+
+// Protocols of NewOTLPSpanExporter.
+var otlpProtocols = []string{"grpc", "http/protobuf"}
+
+func TestOTLPSpanExporterSendsHeaders(t *testing.T) {
+	for _, protocol := range otlpProtocols {
+		receiver := teletest.NewReceiver(t)
+		exp, err := NewOTLPSpanExporter(context.Background(), protocol, receiverEndpoint(receiver, protocol),
+			map[string]string{"x-api-key": "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := exportOne(t, exp, 5*time.Second); err != nil {
+			t.Fatalf("%s: %v", protocol, err)
+		}
+		if got := receiver.Exports()[0].Headers["x-api-key"]; got != "secret" {
+			t.Errorf("%s: x-api-key header = %q, want secret", protocol, got)
+		}
+	}
+}
+
+func TestOTLPSpanExporterCompression(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", "gzip")
+	for _, protocol := range otlpProtocols {
+		receiver := teletest.NewReceiver(t)
+		exp, err := NewOTLPSpanExporter(context.Background(), protocol, receiverEndpoint(receiver, protocol), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := exportOne(t, exp, 5*time.Second); err != nil {
+			t.Fatalf("%s: %v", protocol, err)
+		}
+		if got := receiver.Exports()[0].Compression; got != "gzip" {
+			t.Errorf("%s: compression = %q, want gzip", protocol, got)
+		}
+	}
+}
+
+func TestOTLPSpanExporterRetriesUnavailable(t *testing.T) {
+	for _, protocol := range otlpProtocols {
+		t.Run(protocol, func(t *testing.T) {
+			// the first retry waits for about 5s
+			t.Parallel()
+			receiver := teletest.NewReceiver(t)
+			receiver.FailNext(1)
+			exp, err := NewOTLPSpanExporter(context.Background(), protocol, receiverEndpoint(receiver, protocol), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := exportOne(t, exp, 20*time.Second); err != nil {
+				t.Fatalf("export not retried: %v", err)
+			}
+			if n := len(receiver.Exports()); n != 1 {
+				t.Errorf("received %d exports, want the retried one", n)
+			}
+		})
+	}
+}
+
+// The collector connection authenticates the collector against WithCACert
+// and sends the bearer token and configured headers with every export.
+func TestCollectorConnectionTLSAndToken(t *testing.T) {
+	serverTLS, caFile := selfSignedCert(t)
+	receiver := teletest.NewReceiver(t, teletest.WithReceiverTLS(serverTLS))
+	token := TokenProviderFunc(func(context.Context) (string, error) { return "token-1", nil })
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		ok   bool
+	}{
+		{"tls with token", []Option{WithCACert(caFile), WithTokenProvider(token), WithHeaders(map[string]string{"x-tenant": "acme"})}, true},
+		{"untrusted collector", []Option{WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})}, false},
+		{"plaintext", nil, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewConfig("export-test", tt.opts...)
+			conn, err := dialCollector(cfg, receiver.GRPCAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			exp, err := otlpGRPCAdapter{conn: conn, headers: cfg.Headers}.spanExporter(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := len(receiver.Exports())
+			err = exportOne(t, exp, 2*time.Second)
+			if !tt.ok {
+				if err == nil || len(receiver.Exports()) != before {
+					t.Errorf("export succeeded, want it refused")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			headers := receiver.Exports()[before].Headers
+			if headers["authorization"] != "Bearer token-1" || headers["x-tenant"] != "acme" {
+				t.Errorf("headers = %v, want the bearer token and x-tenant", headers)
+			}
+		})
+	}
+}
+
+// Returns the TLS config of a server presenting a certificate for
+// 127.0.0.1, and the path of the PEM file of that certificate.
+func selfSignedCert(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, caFile
+}
+
+func BenchmarkExporterThroughput(b *testing.B) {
+	for _, protocol := range otlpProtocols {
+		b.Run(protocol, func(b *testing.B) {
+			receiver, err := teletest.StartReceiver("127.0.0.1:0", teletest.WithReceiverDiscard())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer receiver.Close()
+			exp, err := NewOTLPSpanExporter(context.Background(), protocol, receiverEndpoint(receiver, protocol), nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer func() { _ = exp.Shutdown(context.Background()) }()
+			teletest.BenchmarkExporter(b, exp, 512)
+		})
+	}
+}
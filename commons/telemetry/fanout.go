@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Span processor dispatching every span to several processors, typically one
+// batch processor per exporter, so a slow or failing backend does not affect
+// the others while migrating between backends in dual-write mode.
+type fanOutProcessor struct {
+	processors []sdktrace.SpanProcessor
+}
+
+// Creates a span processor forwarding spans to all the given processors.
+func NewFanOutSpanProcessor(processors ...sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return &fanOutProcessor{processors: processors}
+}
+
+func (p *fanOutProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, sp := range p.processors {
+		isolate(func() { sp.OnStart(parent, s) })
+	}
+}
+
+func (p *fanOutProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, sp := range p.processors {
+		isolate(func() { sp.OnEnd(s) })
+	}
+}
+
+func (p *fanOutProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, sp := range p.processors {
+		errs = append(errs, sp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *fanOutProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, sp := range p.processors {
+		errs = append(errs, sp.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Keeps a panicking processor from taking the others down.
+func isolate(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			otel.Handle(fmt.Errorf("span processor panic: %v", r))
+		}
+	}()
+	fn()
+}
+
+// Exporter prefixing its errors with a name, telling backends apart in logs.
+type namedExporter struct {
+	sdktrace.SpanExporter
+	name string
+}
+
+// Wraps exp so its export errors mention the given name.
+func NamedExporter(name string, exp sdktrace.SpanExporter) sdktrace.SpanExporter {
+	return namedExporter{SpanExporter: exp, name: name}
+}
+
+func (e namedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if err := e.SpanExporter.ExportSpans(ctx, spans); err != nil {
+		return fmt.Errorf("%s exporter: %w", e.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package telemetry
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Baggage member asking FaultMiddleware for a fault.
+const FaultBaggageKey = "fault"
+
+// Attributes of the fault.injected span event.
+const (
+	FaultTypeKey   = attribute.Key("fault.type")
+	FaultDelayKey  = attribute.Key("fault.delay_ms")
+	FaultStatusKey = attribute.Key("fault.status_code")
+)
+
+// Fault asked for by a request.
+type Fault struct {
+	// "delay", "error" or "abort"
+	Type   string
+	Delay  time.Duration
+	Status int
+	// chance of the fault being injected, in the (0, 1] range
+	Probability float64
+}
+
+// Parses a fault written as
+//
+//	delay:200ms   holds the request back
+//	error:503     answers with the status, 500 when omitted
+//	abort         drops the connection without a response
+//
+// optionally followed by "@<probability>", e.g. error:503@0.5.
+func ParseFault(s string) (Fault, error) {
+	f := Fault{Probability: 1}
+	s, prob, ok := strings.Cut(strings.TrimSpace(s), "@")
+	if ok {
+		p, err := strconv.ParseFloat(prob, 64)
+		if err != nil || p <= 0 || p > 1 {
+			return Fault{}, fmt.Errorf("invalid fault probability %q", prob)
+		}
+		f.Probability = p
+	}
+	kind, arg, _ := strings.Cut(s, ":")
+	switch f.Type = kind; kind {
+	case "delay":
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return Fault{}, fmt.Errorf("invalid fault delay %q", arg)
+		}
+		f.Delay = d
+	case "error":
+		f.Status = http.StatusInternalServerError
+		if arg != "" {
+			code, err := strconv.Atoi(arg)
+			if err != nil || code < 400 || code > 599 {
+				return Fault{}, fmt.Errorf("invalid fault status %q", arg)
+			}
+			f.Status = code
+		}
+	case "abort":
+	default:
+		return Fault{}, fmt.Errorf("unknown fault %q", kind)
+	}
+	return f, nil
+}
+
+// Middleware injecting the fault named by the "fault" baggage member of the
+// request, e.g. fault=delay:200ms, and recording it as a fault.injected event
+// on the request span, so failure modes can be demonstrated from a client.
+// It must be installed after the tracing middleware, which extracts the
+// baggage, and only where clients may be trusted with it.
+func FaultMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := baggage.FromContext(r.Context()).Member(FaultBaggageKey).Value()
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		f, err := ParseFault(value)
+		if err != nil {
+			span.AddEvent("fault.invalid", trace.WithAttributes(semconv.ExceptionMessage(err.Error())))
+			next.ServeHTTP(w, r)
+			return
+		}
+		if f.Probability < 1 && rand.Float64() >= f.Probability {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		attrs := []attribute.KeyValue{FaultTypeKey.String(f.Type)}
+		switch f.Type {
+		case "delay":
+			attrs = append(attrs, FaultDelayKey.Int64(f.Delay.Milliseconds()))
+		case "error":
+			attrs = append(attrs, FaultStatusKey.Int(f.Status))
+		}
+		span.AddEvent("fault.injected", trace.WithAttributes(attrs...))
+
+		switch f.Type {
+		case "delay":
+			select {
+			case <-time.After(f.Delay):
+			case <-r.Context().Done():
+				return
+			}
+			next.ServeHTTP(w, r)
+		case "error":
+			http.Error(w, "injected fault", f.Status)
+		case "abort":
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
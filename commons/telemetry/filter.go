@@ -0,0 +1,50 @@
+package telemetry
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Reports whether a finished span must be dropped before export.
+type SpanPredicate func(sdktrace.ReadOnlySpan) bool
+
+// Drops spans with any of the given names, e.g. "GET /healthz".
+func DropSpansNamed(names ...string) SpanPredicate {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return func(s sdktrace.ReadOnlySpan) bool {
+		return set[s.Name()]
+	}
+}
+
+// Drops spans shorter than min unless they ended with an error status.
+func DropShortSpans(min time.Duration) SpanPredicate {
+	return func(s sdktrace.ReadOnlySpan) bool {
+		return s.Status().Code != codes.Error && s.EndTime().Sub(s.StartTime()) < min
+	}
+}
+
+// Span processor dropping the spans matching any predicate before they reach
+// the wrapped processor, reducing export volume without touching samplers.
+type filterProcessor struct {
+	sdktrace.SpanProcessor
+	drop []SpanPredicate
+}
+
+// Wraps next so spans matching any of the predicates are never exported.
+func NewFilterSpanProcessor(next sdktrace.SpanProcessor, drop ...SpanPredicate) sdktrace.SpanProcessor {
+	return &filterProcessor{SpanProcessor: next, drop: drop}
+}
+
+func (p *filterProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, drop := range p.drop {
+		if drop(s) {
+			return
+		}
+	}
+	p.SpanProcessor.OnEnd(s)
+}
@@ -0,0 +1,50 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// Upper bound applied by Flush when ctx carries no deadline.
+const DefaultFlushTimeout = 5 * time.Second
+
+type flusher interface {
+	ForceFlush(context.Context) error
+}
+
+// Exports every span, metric and log record still buffered by the global providers,
+// waiting until they are delivered or ctx is done. Short-lived programs call
+// it before exiting instead of sleeping.
+func Flush(ctx context.Context) error {
+	var providers []flusher
+	if f, ok := otel.GetTracerProvider().(flusher); ok {
+		providers = append(providers, f)
+	}
+	if f, ok := otel.GetMeterProvider().(flusher); ok {
+		providers = append(providers, f)
+	}
+	if f, ok := global.GetLoggerProvider().(flusher); ok {
+		providers = append(providers, f)
+	}
+	return forceFlush(ctx, providers...)
+}
+
+// Flushes every provider in order, within DefaultFlushTimeout when ctx
+// carries no deadline.
+func forceFlush(ctx context.Context, providers ...flusher) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultFlushTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+	for _, p := range providers {
+		errs = append(errs, p.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handle of work started with Go.
+type Task struct {
+	done chan struct{}
+	err  error
+}
+
+// Waits for the task to finish and returns its error.
+func (t *Task) Wait() error {
+	<-t.done
+	return t.err
+}
+
+// Closed once the task finished.
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Runs fn in a new goroutine within a span named name, child of the span in
+// ctx. The context given to fn keeps the values of ctx, trace context and
+// baggage included, but is detached from its cancellation so the work
+// outlives the request which spawned it. Panics are recovered and recorded
+// on the span, and returned by Wait as errors. When wg is not nil, the
+// goroutine is tracked by it too.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error, wg ...*sync.WaitGroup) *Task {
+	ctx, span := Tracer(instrumentationName, "").Start(context.WithoutCancel(ctx), name)
+	t := &Task{done: make(chan struct{})}
+	for _, g := range wg {
+		g.Add(1)
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.err = fmt.Errorf("goroutine %s panicked: %v", name, r)
+				span.RecordError(t.err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, "panic")
+			}
+			span.End()
+			close(t.done)
+			for _, g := range wg {
+				g.Done()
+			}
+		}()
+
+		if t.err = fn(ctx); t.err != nil {
+			span.RecordError(t.err)
+			span.SetStatus(codes.Error, t.err.Error())
+		}
+	}()
+	return t
+}
@@ -0,0 +1,163 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes of the GraphQL spans.
+const (
+	GraphQLFieldPathKey  = attribute.Key("graphql.field.path")
+	GraphQLFieldNameKey  = attribute.Key("graphql.field.name")
+	GraphQLParentTypeKey = attribute.Key("graphql.field.parent_type")
+	// number of errors of the execution, or of the validation
+	GraphQLErrorCountKey = attribute.Key("graphql.errors")
+)
+
+const (
+	graphQLExecuteSpan    = "graphql.execute"
+	graphQLResolvePrefix  = "graphql.resolve "
+	graphQLValidationFail = "graphql.validation_failed"
+)
+
+// Schema extension tracing GraphQL requests, add it with
+// schema.AddExtensions. Every execution gets a graphql.execute span and every
+// resolved field a "graphql.resolve <Type>.<field>" child of the span of its
+// parent field, carrying the field path and the error its resolver returned.
+// Fields without a resolver of their own, read from their parent object, get
+// no span.
+func GraphQLExtension() graphql.Extension {
+	return graphQLTracing{}
+}
+
+type graphQLTracing struct{}
+
+type graphQLStateKey struct{}
+
+// Spans of one execution, by response path of their field.
+type graphQLState struct {
+	operation string
+	mu        sync.Mutex
+	execute   context.Context
+	fields    map[string]trace.Span
+}
+
+func (graphQLTracing) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return context.WithValue(ctx, graphQLStateKey{}, &graphQLState{
+		operation: p.OperationName,
+		fields:    map[string]trace.Span{},
+	})
+}
+
+func (graphQLTracing) Name() string { return "opentelemetry" }
+
+func (graphQLTracing) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (graphQLTracing) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func(errs []gqlerrors.FormattedError) {
+		if len(errs) > 0 {
+			trace.SpanFromContext(ctx).AddEvent(graphQLValidationFail, trace.WithAttributes(
+				GraphQLErrorCountKey.Int(len(errs)),
+				semconv.ExceptionMessage(errs[0].Message),
+			))
+		}
+	}
+}
+
+func (graphQLTracing) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	opts := []trace.SpanStartOption{}
+	state, _ := ctx.Value(graphQLStateKey{}).(*graphQLState)
+	if state != nil && state.operation != "" {
+		opts = append(opts, trace.WithAttributes(semconv.GraphqlOperationName(state.operation)))
+	}
+	ctx, span := Tracer(instrumentationName, "").Start(ctx, graphQLExecuteSpan, opts...)
+	if state != nil {
+		state.execute = ctx
+	}
+	return ctx, func(result *graphql.Result) {
+		if n := len(result.Errors); n > 0 {
+			span.SetAttributes(GraphQLErrorCountKey.Int(n))
+			span.SetStatus(codes.Error, result.Errors[0].Message)
+		}
+		span.End()
+	}
+}
+
+func (graphQLTracing) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	state, _ := ctx.Value(graphQLStateKey{}).(*graphQLState)
+	root := info.Path.Prev == nil
+	if state == nil || state.execute == nil || !(root || hasResolver(info)) {
+		return ctx, func(any, error) {}
+	}
+
+	path := graphQLPath(info.Path)
+	// graphql-go hands the context of the previous field to the next one, so
+	// the parent is looked up by path for siblings not to nest
+	state.mu.Lock()
+	parent := state.execute
+	for p := info.Path.Prev; p != nil; p = p.Prev {
+		if span, ok := state.fields[graphQLPath(p)]; ok {
+			parent = trace.ContextWithSpan(state.execute, span)
+			break
+		}
+	}
+	state.mu.Unlock()
+
+	if root {
+		if op, ok := info.Operation.(*ast.OperationDefinition); ok {
+			trace.SpanFromContext(state.execute).SetAttributes(semconv.GraphqlOperationTypeKey.String(op.Operation))
+		}
+	}
+	ctx, span := Tracer(instrumentationName, "").Start(parent, graphQLResolvePrefix+info.ParentType.Name()+"."+info.FieldName,
+		trace.WithAttributes(
+			GraphQLFieldNameKey.String(info.FieldName),
+			GraphQLFieldPathKey.String(path),
+			GraphQLParentTypeKey.String(info.ParentType.Name()),
+		))
+	state.mu.Lock()
+	state.fields[path] = span
+	state.mu.Unlock()
+
+	return ctx, func(_ any, err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (graphQLTracing) HasResult() bool { return false }
+
+func (graphQLTracing) GetResult(context.Context) any { return nil }
+
+func hasResolver(info *graphql.ResolveInfo) bool {
+	parent, ok := info.ParentType.(*graphql.Object)
+	if !ok {
+		return false
+	}
+	field, ok := parent.Fields()[info.FieldName]
+	return ok && field.Resolve != nil
+}
+
+// Returns the response path as "package.items.0.name".
+func graphQLPath(p *graphql.ResponsePath) string {
+	keys := p.AsArray()
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprint(k)
+	}
+	return strings.Join(parts, ".")
+}
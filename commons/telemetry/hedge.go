@@ -0,0 +1,148 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sends a duplicate of idempotent requests which got no response after delay,
+// keeping the first response and cancelling the other attempt, to cut tail
+// latency. Both attempts are children of a "<method> hedged" span, which
+// they link to, and the winner is recorded as hedge.winner.
+func WithHedging(delay time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.hedgeDelay = delay
+	}
+}
+
+// Attempt of a hedged request, as seen by the spans of the attempts.
+type hedgeAttempt struct {
+	call trace.SpanContext
+	n    int
+}
+
+type hedgeAttemptKey struct{}
+
+// Round tripper racing the requests of next, which starts the client spans.
+type hedgeTransport struct {
+	next  http.RoundTripper
+	delay time.Duration
+	clock Clock
+}
+
+type hedgeResult struct {
+	resp   *http.Response
+	err    error
+	n      int
+	cancel context.CancelFunc
+}
+
+func (t *hedgeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !retryable(r) {
+		return t.next.RoundTrip(r)
+	}
+	ctx, span := Tracer(instrumentationName, "").Start(r.Context(), r.Method+" hedged")
+	defer span.End()
+
+	results := make(chan hedgeResult, 2)
+	var cancels [2]context.CancelFunc
+	send := func(n int) error {
+		actx, cancel := context.WithCancel(context.WithValue(ctx, hedgeAttemptKey{},
+			hedgeAttempt{call: span.SpanContext(), n: n}))
+		cancels[n] = cancel
+		req := r.Clone(actx)
+		if n > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				cancel()
+				return err
+			}
+			req.Body = body
+		}
+		go func() {
+			resp, err := t.next.RoundTrip(req)
+			results <- hedgeResult{resp: resp, err: err, n: n, cancel: cancel}
+		}()
+		return nil
+	}
+
+	if err := send(0); err != nil {
+		return nil, err
+	}
+	timer := t.clock.After(t.delay)
+	pending, hedged := 1, false
+	for {
+		select {
+		case <-timer:
+			if hedged {
+				continue
+			}
+			if err := send(1); err == nil {
+				hedged = true
+				pending++
+			}
+		case res := <-results:
+			pending--
+			if res.err != nil && pending > 0 {
+				// the other attempt may still succeed
+				res.cancel()
+				continue
+			}
+			span.SetAttributes(attribute.Bool("hedge.sent", hedged), attribute.Int("hedge.winner", res.n))
+			if pending > 0 {
+				cancels[1-res.n]()
+				go discardLosers(results, pending)
+			}
+			if res.err != nil {
+				res.cancel()
+				span.RecordError(res.err)
+				span.SetStatus(codes.Error, res.err.Error())
+				return nil, res.err
+			}
+			// the winner context lives as long as its body is read
+			res.resp.Body = cancelOnClose{ReadCloser: res.resp.Body, cancel: res.cancel}
+			return res.resp, nil
+		}
+	}
+}
+
+// Releases the attempts which lost the race once they returned.
+func discardLosers(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		res := <-results
+		res.cancel()
+		if res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// Attributes and link of the client span of a hedged attempt.
+func hedgeAttemptSpan(ctx context.Context, span trace.Span) {
+	attempt, ok := ctx.Value(hedgeAttemptKey{}).(hedgeAttempt)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("hedge.attempt", attempt.n))
+	span.AddLink(trace.Link{
+		SpanContext: attempt.call,
+		Attributes:  []attribute.KeyValue{attribute.String("hedge.attempt", strconv.Itoa(attempt.n))},
+	})
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,212 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/semconvx"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option of the clients created by NewHTTPClient and NewPeerClient.
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	peer            string
+	connectionSpans bool
+	retryAttempts   int
+	retryBackoff    time.Duration
+	hedgeDelay      time.Duration
+	shadowURL       *url.URL
+	shadowRatio     float64
+	clock           Clock
+
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	http2               *bool
+}
+
+// Records the DNS lookup, the connection and the TLS handshake of every new
+// connection as child spans of the client span, showing connection problems
+// in the trace waterfall instead of as span events.
+func WithConnectionSpans() ClientOption {
+	return func(c *clientConfig) {
+		c.connectionSpans = true
+	}
+}
+
+// Times the retry and hedging delays with clock instead of the system clock.
+func WithClientClock(clock Clock) ClientOption {
+	return func(c *clientConfig) {
+		c.clock = clock
+	}
+}
+
+// Creates a traced HTTP client sending requests through base, the default
+// transport when nil.
+func NewHTTPClient(base http.RoundTripper, opts ...ClientOption) *http.Client {
+	return newHTTPClient(clientConfig{clock: SystemClock}, base, opts)
+}
+
+func newHTTPClient(cfg clientConfig, base http.RoundTripper, opts []ClientOption) *http.Client {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	base, err := cfg.tuneBase(base)
+	if err != nil {
+		otel.Handle(err)
+	}
+	var otelOpts []otelhttp.Option
+	if cfg.peer != "" {
+		otelOpts = append(otelOpts,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + cfg.peer
+			}),
+			otelhttp.WithSpanOptions(trace.WithAttributes(semconvx.PeerService(cfg.peer))),
+		)
+	}
+	if cfg.connectionSpans {
+		otelOpts = append(otelOpts, otelhttp.WithClientTrace(newConnectionTrace))
+	}
+	var transport http.RoundTripper = otelhttp.NewTransport(newAttemptTransport(cfg.peer, base), otelOpts...)
+	if cfg.hedgeDelay > 0 {
+		transport = &hedgeTransport{next: transport, delay: cfg.hedgeDelay, clock: cfg.clock}
+	}
+	if cfg.retryAttempts > 1 {
+		transport = newRetryTransport(transport, cfg.retryAttempts, cfg.retryBackoff, cfg.clock)
+	}
+	if cfg.shadowURL != nil {
+		transport = newShadowTransport(transport, otelhttp.NewTransport(base, otelOpts...), cfg.shadowURL, cfg.shadowRatio)
+	}
+	return &http.Client{Transport: transport}
+}
+
+// Round tripper under the client spans, marking the spans of resent requests
+// with http.request.resend_count and those of hedged attempts with their
+// attempt number and a link to the hedged call. It counts whether requests
+// reused a pooled connection in the http.client.connection.uses counter.
+type attemptTransport struct {
+	next  http.RoundTripper
+	uses  metric.Int64Counter
+	attrs []attribute.KeyValue
+}
+
+func newAttemptTransport(peer string, next http.RoundTripper) *attemptTransport {
+	t := &attemptTransport{
+		next: next,
+		uses: NewCounter(instrumentationName, Descriptor{
+			Name:        "http.client.connection.uses",
+			Unit:        "{request}",
+			Description: "HTTP client requests by whether they reused a pooled connection.",
+			Attributes:  []string{string(semconv.PeerServiceKey), "http.connection.reused"},
+		}),
+	}
+	if peer != "" {
+		t.attrs = append(t.attrs, semconvx.PeerService(peer))
+	}
+	return t
+}
+
+func (t *attemptTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	if n, ok := ctx.Value(resendCountKey{}).(int); ok {
+		span.SetAttributes(semconv.HTTPRequestResendCount(n))
+	}
+	hedgeAttemptSpan(ctx, span)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			attrs := append(t.attrs[:len(t.attrs):len(t.attrs)], attribute.Bool("http.connection.reused", info.Reused))
+			t.uses.Add(ctx, 1, metric.WithAttributes(attrs...))
+		},
+	})
+	return t.next.RoundTrip(r.WithContext(ctx))
+}
+
+// Client trace starting one span per connection phase under the span in ctx.
+func newConnectionTrace(ctx context.Context) *httptrace.ClientTrace {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationName)
+	var mu sync.Mutex
+	var dns, tlsSpan trace.Span
+	connects := map[string]trace.Span{}
+	start := func(name string, attrs ...attribute.KeyValue) trace.Span {
+		_, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+		return span
+	}
+	end := func(span trace.Span, err error) {
+		if span == nil {
+			return
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dns = start("http.dns", semconv.ServerAddress(info.Host))
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			end(dns, info.Err)
+			dns = nil
+		},
+		// dual-stack dialing connects to several addresses concurrently
+		ConnectStart: func(network, addr string) {
+			mu.Lock()
+			defer mu.Unlock()
+			attrs := []attribute.KeyValue{semconv.NetworkTransportKey.String(network)}
+			if host, port, err := net.SplitHostPort(addr); err == nil {
+				attrs = append(attrs, semconv.NetworkPeerAddress(host))
+				if p, err := strconv.Atoi(port); err == nil {
+					attrs = append(attrs, semconv.NetworkPeerPort(p))
+				}
+			}
+			connects[network+addr] = start("http.connect", attrs...)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			end(connects[network+addr], err)
+			delete(connects, network+addr)
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsSpan = start("http.tls")
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if tlsSpan != nil && err == nil {
+				tlsSpan.SetAttributes(
+					attribute.String("tls.protocol.version", tls.VersionName(state.Version)),
+					attribute.Bool("tls.resumed", state.DidResume),
+				)
+			}
+			end(tlsSpan, err)
+			tlsSpan = nil
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ID generator producing AWS X-Ray compatible trace IDs: the first 4 bytes
+// hold the span start time in epoch seconds, the remaining 12 are random.
+type xrayIDGenerator struct {
+	sync.Mutex
+	randSource *rand.Rand
+}
+
+// Creates an IDGenerator whose trace IDs are accepted by AWS X-Ray.
+func NewXRayIDGenerator() sdktrace.IDGenerator {
+	var seed int64
+	_ = binary.Read(crand.Reader, binary.LittleEndian, &seed)
+	return &xrayIDGenerator{randSource: rand.New(rand.NewSource(seed))}
+}
+
+func (g *xrayIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.Lock()
+	defer g.Unlock()
+	tid := trace.TraceID{}
+	binary.BigEndian.PutUint32(tid[0:4], uint32(time.Now().Unix()))
+	_, _ = g.randSource.Read(tid[4:])
+	sid := trace.SpanID{}
+	_, _ = g.randSource.Read(sid[:])
+	return tid, sid
+}
+
+func (g *xrayIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.Lock()
+	defer g.Unlock()
+	sid := trace.SpanID{}
+	_, _ = g.randSource.Read(sid[:])
+	return sid
+}
+
+// ID generator producing the same sequence of IDs for a given seed.
+type deterministicIDGenerator struct {
+	sync.Mutex
+	randSource *rand.Rand
+}
+
+// Creates an IDGenerator yielding a reproducible sequence of trace and span
+// IDs, meant for tests and CI runs comparing exported traces.
+func NewDeterministicIDGenerator(seed int64) sdktrace.IDGenerator {
+	return &deterministicIDGenerator{randSource: rand.New(rand.NewSource(seed))}
+}
+
+func (g *deterministicIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.Lock()
+	defer g.Unlock()
+	tid := trace.TraceID{}
+	_, _ = g.randSource.Read(tid[:])
+	sid := trace.SpanID{}
+	_, _ = g.randSource.Read(sid[:])
+	return tid, sid
+}
+
+func (g *deterministicIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.Lock()
+	defer g.Unlock()
+	sid := trace.SpanID{}
+	_, _ = g.randSource.Read(sid[:])
+	return sid
+}
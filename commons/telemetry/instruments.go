@@ -0,0 +1,37 @@
+package telemetry
+
+import (
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Creates the counter described by d within the instrumentation scope, and
+// registers it into the telemetry manifest. Meant for business-level
+// metrics, e.g. lookups by result, recorded next to the RPC metrics.
+func NewCounter(scope string, d Descriptor) metric.Int64Counter {
+	counter, err := Meter(scope, "").Int64Counter(d.Name,
+		metric.WithUnit(d.Unit),
+		metric.WithDescription(d.Description),
+	)
+	HandleErr(err, "Failed to create the "+d.Name+" counter")
+	d.Kind = "counter"
+	RegisterMetrics(d)
+	return counter
+}
+
+// Creates the histogram described by d within the instrumentation scope,
+// with the given bucket boundaries or the SDK defaults when none are given,
+// and registers it into the telemetry manifest.
+func NewHistogram(scope string, d Descriptor, boundaries ...float64) metric.Float64Histogram {
+	opts := []metric.Float64HistogramOption{
+		metric.WithUnit(d.Unit),
+		metric.WithDescription(d.Description),
+	}
+	if len(boundaries) > 0 {
+		opts = append(opts, metric.WithExplicitBucketBoundaries(boundaries...))
+	}
+	histogram, err := Meter(scope, "").Float64Histogram(d.Name, opts...)
+	HandleErr(err, "Failed to create the "+d.Name+" histogram")
+	d.Kind = "histogram"
+	RegisterMetrics(d)
+	return histogram
+}
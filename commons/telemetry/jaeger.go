@@ -3,53 +3,93 @@ package telemetry
 import (
 	"context"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"time"
 
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/jaeger"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
-// Returns a new OpenTelemetry resource describing this application.
-func newResource(ctx context.Context) *resource.Resource {
-	res, err := resource.New(ctx,
-		resource.WithFromEnv(),
-		resource.WithProcess(),
-		resource.WithTelemetrySDK(),
-		resource.WithHost(),
-		resource.WithAttributes(semconv.ServiceNameKey.String(os.Getenv("SERVICE_NAME")),
-			attribute.String("environment", os.Getenv("GO_ENV")),
-		),
-	)
-	if err != nil {
-		log.Fatalf("%s: %v", "Failed to create resource", err)
+// Ports of the OTLP receivers Jaeger serves natively.
+const (
+	jaegerOTLPGRPCPort = "4317"
+	jaegerOTLPHTTPPort = "4318"
+)
+
+// Upper bound for probing one Jaeger port.
+const jaegerProbeTimeout = 500 * time.Millisecond
+
+// Returns the Jaeger host, taken from OPEN_TELEMETRY_COLLECTOR_URL which may
+// still hold a legacy collector URL such as http://jaeger:14268/api/traces.
+func jaegerHost() string {
+	raw := os.Getenv("OPEN_TELEMETRY_COLLECTOR_URL")
+	if raw == "" {
+		return "localhost"
+	}
+	if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+		return u.Hostname()
 	}
-	return res
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		return host
+	}
+	return raw
+}
+
+// Exports straight to Jaeger, without a collector, through its native OTLP
+// receivers. Jaeger's gRPC port is probed first, then its HTTP port; when
+// neither answers, gRPC is used and exports are retried until Jaeger is up.
+type jaegerAdapter struct {
+	grpcAddr string
+	httpAddr string
 }
 
-// Creates Jaeger exporter
-func exporterToJaeger() (*jaeger.Exporter, error) {
-	return jaeger.New(
-		jaeger.WithCollectorEndpoint(
-			jaeger.WithEndpoint(os.Getenv("OPEN_TELEMETRY_COLLECTOR_URL")),
-		),
-	)
+// Returns the adapter of the Jaeger instance running on host.
+func newJaegerAdapter(host string) jaegerAdapter {
+	return jaegerAdapter{
+		grpcAddr: net.JoinHostPort(host, jaegerOTLPGRPCPort),
+		httpAddr: net.JoinHostPort(host, jaegerOTLPHTTPPort),
+	}
 }
 
-// Initiates OpenTelemetry provider sending data to OpenTelemetry Collector.
-func InitProviderWithJaegerExporter(ctx context.Context) (func(context.Context) error, error) {
-	exp, err := exporterToJaeger()
+func (a jaegerAdapter) spanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	var adapter exporterAdapter = otlpGRPCAdapter{endpoint: a.grpcAddr}
+	switch {
+	case reachable(a.grpcAddr):
+	case reachable(a.httpAddr):
+		adapter = otlpHTTPAdapter{endpoint: a.httpAddr}
+	default:
+		log.Printf("telemetry: Jaeger unreachable on %s and %s, exporting over gRPC once it is up", a.grpcAddr, a.httpAddr)
+	}
+	return adapter.spanExporter(ctx)
+}
+
+func reachable(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, jaegerProbeTimeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// Initiates OpenTelemetry provider sending spans directly to Jaeger over
+// OTLP. The name dates back to the deprecated Jaeger exporter it replaces;
+// spans carry the same resource as with InitProvider, so the service is
+// listed under the same name in the Jaeger UI.
+func InitProviderWithJaegerExporter(ctx context.Context, opts ...Option) (func(context.Context) error, error) {
+	cfg := NewConfig(os.Getenv("SERVICE_NAME"), opts...)
+	if err := cfg.Batch.validate(); err != nil {
+		return nil, err
+	}
+	exp, err := newJaegerAdapter(jaegerHost()).spanExporter(ctx)
 	if err != nil {
-		log.Fatalf("error: %s", err.Error())
+		return nil, err
 	}
-	tp := trace.NewTracerProvider(
-		trace.WithSampler(GetSampler()),
-		trace.WithBatcher(exp),
-		trace.WithResource(newResource(ctx)),
-	)
+	tp := newTracerProvider(cfg, newResource(ctx, cfg), exp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 	otel.SetTracerProvider(tp)
 	return tp.Shutdown, nil
 }
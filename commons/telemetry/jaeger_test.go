@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/teletest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Jaeger lists services by the service.name of the exported resource, so
+// spans sent through the Jaeger adapter must carry the same resource as
+// those sent through InitProvider's OTLP exporter, whichever Jaeger port
+// answers.
+func TestJaegerAdapterKeepsServiceNaming(t *testing.T) {
+	const service = "otel-example-server"
+	ctx := context.Background()
+	cfg := NewConfig(service)
+	res := newResource(ctx, cfg)
+
+	// a port nothing listens on, making the adapter fall back to HTTP
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := lis.Addr().String()
+	_ = lis.Close()
+
+	export := func(t *testing.T, adapter exporterAdapter, receiver *teletest.Receiver) *resourcepb.Resource {
+		t.Helper()
+		exp, err := adapter.spanExporter(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tp := newTracerProvider(cfg, res, exp)
+		_, span := tp.Tracer("jaeger-test").Start(ctx, "GET /packages/{id}")
+		span.End()
+		if err := tp.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if !receiver.WaitForSpans(1, 5*time.Second) {
+			t.Fatal("span not exported")
+		}
+		exports := receiver.Exports()
+		return exports[len(exports)-1].ResourceSpans[0].Resource
+	}
+
+	otlpReceiver := teletest.NewReceiver(t)
+	want := export(t, otlpGRPCAdapter{endpoint: otlpReceiver.GRPCAddr}, otlpReceiver)
+
+	for _, tt := range []struct {
+		name     string
+		protocol string
+		adapter  func(r *teletest.Receiver) jaegerAdapter
+	}{
+		{"grpc", "grpc", func(r *teletest.Receiver) jaegerAdapter {
+			return jaegerAdapter{grpcAddr: r.GRPCAddr, httpAddr: closed}
+		}},
+		{"http fallback", "http", func(r *teletest.Receiver) jaegerAdapter {
+			return jaegerAdapter{grpcAddr: closed, httpAddr: strings.TrimPrefix(r.HTTPURL, "http://")}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := teletest.NewReceiver(t)
+			got := export(t, tt.adapter(receiver), receiver)
+			if p := receiver.Exports()[0].Protocol; p != tt.protocol {
+				t.Errorf("exported over %s, want %s", p, tt.protocol)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("resource = %v, want %v", got, want)
+			}
+			for _, kv := range got.GetAttributes() {
+				if kv.Key == string(semconv.ServiceNameKey) && kv.Value.GetStringValue() != service {
+					t.Errorf("service.name = %q, want %q", kv.Value.GetStringValue(), service)
+				}
+			}
+		})
+	}
+}
+
+func TestJaegerHost(t *testing.T) {
+	for _, tt := range []struct {
+		env, want string
+	}{
+		{"", "localhost"},
+		{"http://jaeger:14268/api/traces", "jaeger"},
+		{"jaeger:4317", "jaeger"},
+		{"jaeger", "jaeger"},
+	} {
+		t.Setenv("OPEN_TELEMETRY_COLLECTOR_URL", tt.env)
+		if got := jaegerHost(); got != tt.want {
+			t.Errorf("jaegerHost() with %q = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// name of an instrumented background job
+	JobNameKey = attribute.Key("job.name")
+	// schedule of the job, e.g. "@every 30s"
+	JobScheduleKey = attribute.Key("job.schedule")
+	// outcome of a job run: success, error or panic
+	JobOutcomeKey = attribute.Key("job.outcome")
+)
+
+type jobConfig struct {
+	schedule string
+	attrs    []attribute.KeyValue
+	clock    Clock
+}
+
+// Configures InstrumentJob.
+type JobOption func(*jobConfig)
+
+// Records the job schedule, e.g. "@every 30s" or "0 * * * *", on every run.
+func WithJobSchedule(schedule string) JobOption {
+	return func(c *jobConfig) {
+		c.schedule = schedule
+	}
+}
+
+// Adds attributes to the span of every run.
+func WithJobAttributes(attrs ...attribute.KeyValue) JobOption {
+	return func(c *jobConfig) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+// Times the runs with clock instead of the system clock.
+func WithJobClock(clock Clock) JobOption {
+	return func(c *jobConfig) {
+		c.clock = clock
+	}
+}
+
+// Wraps a background job so every run gets its own root span named
+// "job <name>" and its duration is recorded in the job.duration histogram.
+// When the context given to a run carries a span, e.g. the request which
+// triggered it, the run span links to it. Panics are recovered, recorded as
+// span errors and returned as errors.
+func InstrumentJob(name string, fn func(ctx context.Context) error, opts ...JobOption) func(ctx context.Context) error {
+	cfg := jobConfig{clock: SystemClock}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	attrs := append([]attribute.KeyValue{JobNameKey.String(name)}, cfg.attrs...)
+	if cfg.schedule != "" {
+		attrs = append(attrs, JobScheduleKey.String(cfg.schedule))
+	}
+
+	duration, err := Meter(instrumentationName, "").Float64Histogram(
+		"job.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of background job runs."),
+	)
+	HandleErr(err, "Failed to create the job duration histogram")
+	RegisterMetrics(Descriptor{
+		Name:        "job.duration",
+		Kind:        "histogram",
+		Unit:        "s",
+		Description: "Duration of background job runs.",
+		Attributes:  []string{string(JobNameKey), string(JobOutcomeKey)},
+	})
+	RegisterSpans(Descriptor{
+		Name:        "job " + name,
+		Kind:        "internal",
+		Description: "Run of the " + name + " background job.",
+		Attributes:  []string{string(JobNameKey), string(JobScheduleKey), string(JobOutcomeKey)},
+	})
+
+	return func(ctx context.Context) (err error) {
+		startOpts := []trace.SpanStartOption{trace.WithNewRoot(), trace.WithAttributes(attrs...)}
+		if link := trace.LinkFromContext(ctx, attribute.String("link.type", "scheduled_by")); link.SpanContext.IsValid() {
+			startOpts = append(startOpts, trace.WithLinks(link))
+		}
+		start := cfg.clock.Now()
+		startOpts = append(startOpts, trace.WithTimestamp(start))
+		ctx, span := Tracer(instrumentationName, "").Start(ctx, "job "+name, startOpts...)
+
+		outcome := "success"
+		defer func() {
+			if r := recover(); r != nil {
+				outcome = "panic"
+				err = fmt.Errorf("job %s panicked: %v", name, r)
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, "panic")
+			}
+			span.SetAttributes(JobOutcomeKey.String(outcome))
+			end := cfg.clock.Now()
+			duration.Record(ctx, end.Sub(start).Seconds(),
+				metric.WithAttributes(JobNameKey.String(name), JobOutcomeKey.String(outcome)))
+			span.End(trace.WithTimestamp(end))
+		}()
+
+		if err = fn(ctx); err != nil {
+			outcome = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
@@ -0,0 +1,145 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute whose value is only computed when the owning span is about to end.
+type LazyAttribute struct {
+	Key   attribute.Key
+	Value func() attribute.Value
+}
+
+// Helper function to build a LazyAttribute.
+func Lazy(key string, value func() attribute.Value) LazyAttribute {
+	return LazyAttribute{Key: attribute.Key(key), Value: value}
+}
+
+type lazyAttributesKey struct{}
+
+type lazyAttributes struct {
+	mu     sync.Mutex
+	attrs  []LazyAttribute
+	events []lazyEvent
+}
+
+// Event whose attributes are only built when the owning span is about to end.
+type lazyEvent struct {
+	name  string
+	time  time.Time
+	attrs func() []attribute.KeyValue
+}
+
+// Attribute of the events recorded by AddEventf carrying the formatted
+// message.
+const EventMessageKey = attribute.Key("message")
+
+// Returns a context able to collect lazy attributes for the span it carries.
+// Unsampled spans get no collector so registering attributes on them is free.
+func ContextWithLazyAttributes(ctx context.Context) context.Context {
+	if !isSampled(trace.SpanFromContext(ctx)) {
+		return ctx
+	}
+	return context.WithValue(ctx, lazyAttributesKey{}, &lazyAttributes{})
+}
+
+// Registers attributes to be resolved once the span in ctx ends.
+// Attributes are silently dropped when the span is not sampled.
+func AddLazyAttributes(ctx context.Context, attrs ...LazyAttribute) {
+	la, ok := ctx.Value(lazyAttributesKey{}).(*lazyAttributes)
+	if !ok {
+		return
+	}
+	la.mu.Lock()
+	la.attrs = append(la.attrs, attrs...)
+	la.mu.Unlock()
+}
+
+// Evaluates the registered lazy attributes and events and sets them on the
+// span in ctx.
+// It must be called before the span ends, and each attribute is resolved once.
+func ResolveLazyAttributes(ctx context.Context) {
+	la, ok := ctx.Value(lazyAttributesKey{}).(*lazyAttributes)
+	if !ok {
+		return
+	}
+	la.mu.Lock()
+	attrs, events := la.attrs, la.events
+	la.attrs, la.events = nil, nil
+	la.mu.Unlock()
+
+	span := trace.SpanFromContext(ctx)
+	if !isSampled(span) {
+		return
+	}
+	for _, e := range events {
+		span.AddEvent(e.name, trace.WithTimestamp(e.time), trace.WithAttributes(e.attrs()...))
+	}
+	if len(attrs) == 0 {
+		return
+	}
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, attribute.KeyValue{Key: a.Key, Value: a.Value()})
+	}
+	span.SetAttributes(kvs...)
+}
+
+// Records an event on the span in ctx whose attributes are only built when
+// the span is about to end, keeping the time of the call. Nothing is built
+// when the span is not sampled, and the attributes are built right away when
+// ctx has no lazy attributes collector.
+func AddLazyEvent(ctx context.Context, name string, attrs func() []attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	if !isSampled(span) {
+		return
+	}
+	la, ok := ctx.Value(lazyAttributesKey{}).(*lazyAttributes)
+	if !ok {
+		span.AddEvent(name, trace.WithAttributes(attrs()...))
+		return
+	}
+	la.mu.Lock()
+	la.events = append(la.events, lazyEvent{name: name, time: time.Now(), attrs: attrs})
+	la.mu.Unlock()
+}
+
+// Records an event carrying fmt.Sprintf(format, args...) as its message,
+// formatted as late as AddLazyEvent builds attributes. args must not change
+// until the span ends.
+func AddEventf(ctx context.Context, name, format string, args ...any) {
+	AddLazyEvent(ctx, name, func() []attribute.KeyValue {
+		return []attribute.KeyValue{EventMessageKey.String(fmt.Sprintf(format, args...))}
+	})
+}
+
+// Middleware resolving lazy attributes registered by inner handlers.
+// It must be installed after the tracing middleware so the server span is
+// still open when the handler returns.
+func LazyAttributesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := ContextWithLazyAttributes(r.Context())
+		defer ResolveLazyAttributes(ctx)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Calls fn with the span in ctx only when it is recording, so the attributes
+// and events of sampled-out requests, and the work building them, are
+// skipped altogether.
+func IfRecording(ctx context.Context, fn func(span trace.Span)) {
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		fn(span)
+	}
+}
+
+func isSampled(span trace.Span) bool {
+	return span.IsRecording() && span.SpanContext().IsSampled()
+}
@@ -0,0 +1,135 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Longest attribute value kept by default, longer strings are truncated.
+const DefaultAttributeValueLengthLimit = 4096
+
+// Returns the default span limits of the SDK, with attribute values capped
+// to DefaultAttributeValueLengthLimit. The OTEL_SPAN_* environment variables
+// are applied by envconfig.
+func defaultSpanLimits() sdktrace.SpanLimits {
+	return sdktrace.SpanLimits{
+		AttributeValueLengthLimit:   DefaultAttributeValueLengthLimit,
+		AttributeCountLimit:         sdktrace.DefaultAttributeCountLimit,
+		EventCountLimit:             sdktrace.DefaultEventCountLimit,
+		LinkCountLimit:              sdktrace.DefaultLinkCountLimit,
+		AttributePerEventCountLimit: sdktrace.DefaultAttributePerEventCountLimit,
+		AttributePerLinkCountLimit:  sdktrace.DefaultAttributePerLinkCountLimit,
+	}
+}
+
+// Replaces all the span limits at once.
+func WithSpanLimits(limits sdktrace.SpanLimits) Option {
+	return func(c *Config) {
+		c.SpanLimits = limits
+	}
+}
+
+// Caps the number of attributes per span, later attributes are dropped.
+func WithMaxAttributes(n int) Option {
+	return func(c *Config) {
+		c.SpanLimits.AttributeCountLimit = n
+	}
+}
+
+// Caps the number of events per span, later events are dropped.
+func WithMaxEvents(n int) Option {
+	return func(c *Config) {
+		c.SpanLimits.EventCountLimit = n
+	}
+}
+
+// Caps the number of links per span, later links are dropped.
+func WithMaxLinks(n int) Option {
+	return func(c *Config) {
+		c.SpanLimits.LinkCountLimit = n
+	}
+}
+
+// Caps the number of attributes per span event, later attributes are dropped.
+func WithMaxEventAttributes(n int) Option {
+	return func(c *Config) {
+		c.SpanLimits.AttributePerEventCountLimit = n
+	}
+}
+
+// Caps the number of attributes per span link, later attributes are dropped.
+func WithMaxLinkAttributes(n int) Option {
+	return func(c *Config) {
+		c.SpanLimits.AttributePerLinkCountLimit = n
+	}
+}
+
+// Truncates string attribute values longer than n, -1 meaning unlimited.
+func WithAttributeValueLengthLimit(n int) Option {
+	return func(c *Config) {
+		c.SpanLimits.AttributeValueLengthLimit = n
+	}
+}
+
+// Counts the attributes, events and links dropped by the span limits, and
+// the attribute values likely truncated, in the telemetry.span.limited
+// counter.
+func WithTruncationMetric(enabled bool) Option {
+	return func(c *Config) {
+		c.TruncationMetric = enabled
+	}
+}
+
+// Span processor counting what the span limits removed from ended spans.
+type truncationProcessor struct {
+	limits  sdktrace.SpanLimits
+	counter metric.Int64Counter
+}
+
+func newTruncationProcessor(limits sdktrace.SpanLimits) sdktrace.SpanProcessor {
+	counter, err := Meter(instrumentationName, "").Int64Counter(
+		"telemetry.span.limited",
+		metric.WithDescription("Span attributes, events and links dropped or truncated by the span limits."),
+	)
+	HandleErr(err, "Failed to create the span limits counter")
+	RegisterMetrics(Descriptor{
+		Name:        "telemetry.span.limited",
+		Kind:        "counter",
+		Description: "Span attributes, events and links dropped or truncated by the span limits.",
+		Attributes:  []string{"limit"},
+	})
+	return &truncationProcessor{limits: limits, counter: counter}
+}
+
+func (p *truncationProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *truncationProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	ctx := context.Background()
+	p.add(ctx, "attribute_count", s.DroppedAttributes())
+	p.add(ctx, "event_count", s.DroppedEvents())
+	p.add(ctx, "link_count", s.DroppedLinks())
+	if maxLen := p.limits.AttributeValueLengthLimit; maxLen >= 0 {
+		// the SDK does not report truncations, values at the limit most
+		// likely were
+		truncated := 0
+		for _, kv := range s.Attributes() {
+			if kv.Value.Type() == attribute.STRING && len(kv.Value.AsString()) == maxLen {
+				truncated++
+			}
+		}
+		p.add(ctx, "attribute_value_length", truncated)
+	}
+}
+
+func (p *truncationProcessor) add(ctx context.Context, limit string, n int) {
+	if n > 0 {
+		p.counter.Add(ctx, int64(n), metric.WithAttributes(attribute.String("limit", limit)))
+	}
+}
+
+func (p *truncationProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *truncationProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,20 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Starts a span as a child of ctx that is also linked to the given spans.
+// Links pointing to invalid span contexts are skipped, so callers can pass the
+// result of trace.LinkFromContext without checking it first.
+func StartLinkedSpan(ctx context.Context, tracer trace.Tracer, name string, links ...trace.Link) (context.Context, trace.Span) {
+	valid := make([]trace.Link, 0, len(links))
+	for _, l := range links {
+		if l.SpanContext.IsValid() {
+			valid = append(valid, l)
+		}
+	}
+	return tracer.Start(ctx, name, trace.WithLinks(valid...))
+}
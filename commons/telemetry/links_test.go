@@ -0,0 +1,63 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sosalejandro/otel-example/commons/teletest"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartLinkedSpanExportsLinks(t *testing.T) {
+	receiver := teletest.NewReceiver(t)
+	ctx := context.Background()
+	exp, err := NewOTLPSpanExporter(ctx, "grpc", receiver.GRPCAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	tracer := tp.Tracer("links-test")
+
+	batch1, span1 := tracer.Start(ctx, "batch 1")
+	span1.End()
+	batch2, span2 := tracer.Start(ctx, "batch 2")
+	span2.End()
+	_, fanIn := StartLinkedSpan(ctx, tracer, "fan-in",
+		trace.LinkFromContext(batch1),
+		// invalid, skipped
+		trace.LinkFromContext(ctx),
+		trace.LinkFromContext(batch2, attribute.String("batch", "2")),
+	)
+	fanIn.End()
+	if err := tp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !receiver.WaitForSpans(3, 5*time.Second) {
+		t.Fatalf("received %d spans, want 3", len(receiver.Spans()))
+	}
+
+	for _, s := range receiver.Spans() {
+		if s.Name != "fan-in" {
+			continue
+		}
+		want := []trace.SpanContext{span1.SpanContext(), span2.SpanContext()}
+		if len(s.Links) != len(want) {
+			t.Fatalf("fan-in has %d links, want %d", len(s.Links), len(want))
+		}
+		for i, l := range s.Links {
+			traceID, spanID := want[i].TraceID(), want[i].SpanID()
+			if !bytes.Equal(l.TraceId, traceID[:]) || !bytes.Equal(l.SpanId, spanID[:]) {
+				t.Errorf("link %d points to %x/%x, want %s/%s", i, l.TraceId, l.SpanId, traceID, spanID)
+			}
+		}
+		if attrs := s.Links[1].Attributes; len(attrs) != 1 || attrs[0].Key != "batch" || attrs[0].Value.GetStringValue() != "2" {
+			t.Errorf("second link attributes = %v, want batch=2", attrs)
+		}
+		return
+	}
+	t.Fatal("fan-in span not exported")
+}
@@ -0,0 +1,171 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand/v2"
+	"regexp"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// Reason recorded in telemetry.logs.dropped for records dropped by the
+// severity ratios, DropReasonRateLimited for those above the template rate.
+const DropReasonSampled = "sampled"
+
+// Number of message templates whose rate is tracked at once.
+const maxLogTemplates = 4096
+
+// Settings of the sampling of log records before export.
+type LogSamplingConfig struct {
+	// share of the records kept by severity name, TRACE, DEBUG, INFO, WARN,
+	// ERROR or FATAL, all kept for unlisted severities
+	Ratios map[string]float64
+	// records per second kept for each message template, unlimited when 0
+	TemplateRate float64
+	// records of a template kept at once above TemplateRate
+	TemplateBurst int
+}
+
+// Keeps the given share of the log records of each severity, e.g.
+//
+//	WithLogSampling(map[string]float64{"DEBUG": 0, "INFO": 0.1})
+//
+// Records of a sampled trace are kept or dropped together.
+func WithLogSampling(ratios map[string]float64) Option {
+	return func(c *Config) {
+		if c.LogSampling == nil {
+			c.LogSampling = &LogSamplingConfig{}
+		}
+		c.LogSampling.Ratios = ratios
+	}
+}
+
+// Caps the log records exported for each message template, the body with its
+// numbers, IDs and quoted values masked, to perSecond, allowing bursts of
+// burst records, so a line logged in a loop cannot flood the collector.
+func WithLogRateLimit(perSecond float64, burst int) Option {
+	return func(c *Config) {
+		if c.LogSampling == nil {
+			c.LogSampling = &LogSamplingConfig{}
+		}
+		c.LogSampling.TemplateRate = perSecond
+		c.LogSampling.TemplateBurst = burst
+	}
+}
+
+// Log processor handing next the records kept by the severity ratios and the
+// template rate limit.
+type logSamplingProcessor struct {
+	next   sdklog.Processor
+	ratios [6]float64
+	rate   float64
+	burst  float64
+
+	mu      sync.Mutex
+	buckets map[string]*logBucket
+
+	dropped metric.Int64Counter
+}
+
+// Token bucket of one message template.
+type logBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Severity names by range of severity numbers, four numbers each.
+var severityRanges = []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+func newLogSamplingProcessor(next sdklog.Processor, cfg LogSamplingConfig) *logSamplingProcessor {
+	p := &logSamplingProcessor{
+		next:    next,
+		rate:    cfg.TemplateRate,
+		burst:   float64(max(cfg.TemplateBurst, 1)),
+		buckets: map[string]*logBucket{},
+		dropped: NewCounter(instrumentationName, Descriptor{
+			Name:        "telemetry.logs.dropped",
+			Unit:        "{record}",
+			Description: "Log records dropped before export, by reason.",
+			Attributes:  []string{string(DropReasonKey)},
+		}),
+	}
+	for i, name := range severityRanges {
+		p.ratios[i] = 1
+		if ratio, ok := cfg.Ratios[name]; ok {
+			p.ratios[i] = ratio
+		}
+	}
+	return p
+}
+
+func (p *logSamplingProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if !p.sampled(r) {
+		p.dropped.Add(ctx, 1, metric.WithAttributes(DropReasonKey.String(DropReasonSampled)))
+		return nil
+	}
+	if p.rate > 0 && r.Body().Kind() == otellog.KindString && !p.take(logTemplate(r.Body().AsString())) {
+		p.dropped.Add(ctx, 1, metric.WithAttributes(DropReasonKey.String(DropReasonRateLimited)))
+		return nil
+	}
+	return p.next.OnEmit(ctx, r)
+}
+
+// Decides on the ratio of the severity of r, from its trace ID when it has
+// one so the records of a trace share the decision.
+func (p *logSamplingProcessor) sampled(r *sdklog.Record) bool {
+	i := (int(r.Severity()) - 1) / 4
+	if i < 0 || i >= len(p.ratios) || p.ratios[i] >= 1 {
+		return true
+	}
+	if id := r.TraceID(); id.IsValid() {
+		// as TraceIDRatioBased does
+		return binary.BigEndian.Uint64(id[8:16])>>1 < uint64(p.ratios[i]*(1<<63))
+	}
+	return rand.Float64() < p.ratios[i]
+}
+
+// Takes a token from the bucket of template, refilled according to the
+// elapsed time.
+func (p *logSamplingProcessor) take(template string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	b, ok := p.buckets[template]
+	if !ok {
+		if len(p.buckets) >= maxLogTemplates {
+			// forgets every template rather than tracking unbounded ones
+			clear(p.buckets)
+		}
+		b = &logBucket{tokens: p.burst, last: now}
+		p.buckets[template] = b
+	}
+	b.tokens = min(p.burst, b.tokens+now.Sub(b.last).Seconds()*p.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (p *logSamplingProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *logSamplingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// Quoted values, ID-like tokens and numbers varying between the records of a
+// message template.
+var logTemplateValues = regexp.MustCompile(`"[^"]*"|` + idLikeTokens.String() + `|\b[0-9]+(\.[0-9]+)?`)
+
+// Returns the template of a log message, its varying values masked.
+func logTemplate(msg string) string {
+	return logTemplateValues.ReplaceAllString(msg, "*")
+}
@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Describes a span, metric instrument, event or attribute key a service can emit.
+type Descriptor struct {
+	Name        string   `json:"name"`
+	Kind        string   `json:"kind,omitempty"`
+	Unit        string   `json:"unit,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Attributes  []string `json:"attributes,omitempty"`
+}
+
+// Machine-readable description of all the telemetry a service can emit.
+type Manifest struct {
+	Service    string       `json:"service"`
+	Spans      []Descriptor `json:"spans"`
+	Metrics    []Descriptor `json:"metrics"`
+	Events     []Descriptor `json:"events"`
+	Attributes []Descriptor `json:"attributes"`
+}
+
+type manifestRegistry struct {
+	mu         sync.RWMutex
+	spans      map[string]Descriptor
+	metrics    map[string]Descriptor
+	events     map[string]Descriptor
+	attributes map[string]Descriptor
+}
+
+// central registry every module registers its telemetry into
+var registry = &manifestRegistry{
+	spans:      map[string]Descriptor{},
+	metrics:    map[string]Descriptor{},
+	events:     map[string]Descriptor{},
+	attributes: map[string]Descriptor{},
+}
+
+func (m *manifestRegistry) register(set map[string]Descriptor, ds []Descriptor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range ds {
+		set[d.Name] = d
+	}
+}
+
+// Registers span names into the telemetry manifest.
+func RegisterSpans(ds ...Descriptor) { registry.register(registry.spans, ds) }
+
+// Registers metric instruments into the telemetry manifest.
+func RegisterMetrics(ds ...Descriptor) { registry.register(registry.metrics, ds) }
+
+// Registers span event names into the telemetry manifest.
+func RegisterEvents(ds ...Descriptor) { registry.register(registry.events, ds) }
+
+// Registers attribute keys into the telemetry manifest. Keys referenced by
+// spans, metrics or events are listed even when not registered explicitly.
+func RegisterAttributes(ds ...Descriptor) { registry.register(registry.attributes, ds) }
+
+// Returns a snapshot of everything registered so far.
+func GetManifest(serviceName string) Manifest {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	attributes := make(map[string]Descriptor, len(registry.attributes))
+	for name, d := range registry.attributes {
+		attributes[name] = d
+	}
+	for _, set := range []map[string]Descriptor{registry.spans, registry.metrics, registry.events} {
+		for _, d := range set {
+			for _, key := range d.Attributes {
+				if _, ok := attributes[key]; !ok {
+					attributes[key] = Descriptor{Name: key}
+				}
+			}
+		}
+	}
+
+	return Manifest{
+		Service:    serviceName,
+		Spans:      sortedDescriptors(registry.spans),
+		Metrics:    sortedDescriptors(registry.metrics),
+		Events:     sortedDescriptors(registry.events),
+		Attributes: sortedDescriptors(attributes),
+	}
+}
+
+// Serves the telemetry manifest as JSON.
+func ManifestHandler(serviceName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(GetManifest(serviceName)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func sortedDescriptors(set map[string]Descriptor) []Descriptor {
+	ds := make([]Descriptor, 0, len(set))
+	for _, d := range set {
+		ds = append(ds, d)
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Name < ds[j].Name })
+	return ds
+}
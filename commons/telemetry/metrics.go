@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"github.com/sosalejandro/otel-example/commons/semconvx"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Bucket boundaries, in seconds, suited to sub-second HTTP services.
+var latencyBoundaries = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Middleware recording the latency of every request into the
+// http.server.request.duration histogram. It must be installed after the
+// tracing middleware so measurements can carry the request span as exemplar,
+// and after TenantMiddleware for them to carry the tenant.
+func LatencyMiddleware(serverName string) mux.MiddlewareFunc {
+	latency := NewHistogram(serverName, Descriptor{
+		Name:        "http.server.request.duration",
+		Unit:        "s",
+		Description: "Duration of HTTP server requests.",
+		Attributes: []string{
+			string(semconv.HTTPRequestMethodKey),
+			string(semconv.HTTPResponseStatusCodeKey),
+			string(semconv.HTTPRouteKey),
+			string(TenantKey),
+		},
+	}, latencyBoundaries...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := httpsnoop.CaptureMetrics(next, w, r)
+
+			attrs := semconvx.GetAttrs().Add(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPResponseStatusCode(m.Code),
+			).Route(r)
+			if tenant := TenantFromContext(r.Context()); tenant != "" {
+				attrs.Add(TenantKey.String(tenant))
+			}
+			// the histogram copies the attributes into its own set
+			latency.Record(r.Context(), m.Duration.Seconds(), metric.WithAttributes(attrs.Attrs()...))
+			attrs.Release()
+		})
+	}
+}
@@ -7,87 +7,302 @@ import (
 	"time"
 
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"google.golang.org/grpc"
 )
 
-// Initializes an OTLP exporter, and configures the corresponding trace and
-// metric providers.
-func InitProvider(serverName string) func() {
+// Instrumentation scope of the telemetry emitted by this package.
+const instrumentationName = "github.com/sosalejandro/otel-example/commons/telemetry"
+
+// Initializes an OTLP exporter, and configures the corresponding trace,
+// metric and log providers.
+func InitProvider(serverName string, opts ...Option) func() {
+	shutdown, _ := InitProviderWithFlush(serverName, opts...)
+	return shutdown
+}
+
+// Same as InitProvider, also returning a handle exporting everything the
+// providers buffer, which short-lived programs call before exiting so their
+// last spans and metric deltas are delivered.
+func InitProviderWithFlush(serverName string, opts ...Option) (shutdown func(), flush func(context.Context) error) {
 	ctx := context.Background()
+	cfg := NewConfig(serverName, opts...)
+	// keeps the recent pipeline errors for CurrentStatus
+	otel.SetErrorHandler(status)
+	if cfg.ConfigFile != "" {
+		reload, err := newConfigReloader(&cfg)
+		HandleErr(err, "Invalid telemetry config file")
+		cfg.reload = reload
+	}
+	HandleErr(ValidateConfig(cfg), "Invalid telemetry config")
 
-	res, err := resource.New(ctx,
-		resource.WithFromEnv(),
-		resource.WithProcess(),
-		resource.WithTelemetrySDK(),
-		resource.WithHost(),
-		resource.WithAttributes(
-			// the service name used to display traces in backends
-			semconv.ServiceNameKey.String(serverName),
-			attribute.String("environment", os.Getenv("GO_ENV")),
-		),
-	)
-	HandleErr(err, "failed to create resource")
+	res := newResource(ctx, cfg)
 
 	otelAgentAddr, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT")
 	if !ok {
 		otelAgentAddr = "0.0.0.0:4317"
 	}
+	if cfg.reload != nil && cfg.reload.current.endpoint() != "" {
+		otelAgentAddr = cfg.reload.current.endpoint()
+	}
+
+	rejections := &partialSuccessReporter{}
+	if cfg.DegradedPeriod > 0 {
+		cfg.degraded = &degradedMode{ratio: cfg.DegradedSamplingRatio, period: cfg.DegradedPeriod}
+		rejections.degraded = cfg.degraded
+	}
+	conn, err := dialCollector(cfg, otelAgentAddr, grpc.WithChainUnaryInterceptor(rejections.intercept))
+	HandleErr(err, "Failed to create the collector connection")
+
+	var metrics metricExporterAdapter = otlpGRPCAdapter{
+		conn:        conn,
+		headers:     cfg.Headers,
+		timeout:     cfg.OTLPTimeout,
+		aggregation: cfg.Aggregation,
+		temporality: cfg.Temporality,
+	}
+	if cfg.RemoteWrite != nil {
+		metrics = remoteWriteAdapter{config: *cfg.RemoteWrite, aggregation: cfg.Aggregation}
+	}
+	metricExp, err := metrics.metricExporter(ctx)
+	HandleErr(err, "Failed to create the metric exporter")
 
-	metricExp, err := otlpmetricgrpc.New(
-		ctx,
-		otlpmetricgrpc.WithInsecure(),
-		otlpmetricgrpc.WithEndpoint(otelAgentAddr))
-	HandleErr(err, "Failed to create the collector metric exporter")
+	exemplarFilter := exemplar.AlwaysOffFilter
+	if cfg.Exemplars {
+		exemplarFilter = exemplar.TraceBasedFilter
+	}
 
-	meterProvider := sdkmetric.NewMeterProvider(
+	meterOpts := []sdkmetric.Option{
 		sdkmetric.WithResource(res),
-		sdkmetric.WithReader(
-			sdkmetric.NewPeriodicReader(
-				metricExp,
-				sdkmetric.WithInterval(2*time.Second),
-			),
-		),
-	)
+		sdkmetric.WithReader(cfg.metricReader(metricExp)),
+		sdkmetric.WithView(mergeViews(cfg.MetricViews, cfg.reload.attributeDrops())),
+		sdkmetric.WithExemplarFilter(exemplarFilter),
+	}
+	if cfg.StatsD != nil {
+		statsdExp, err := newStatsDExporter(*cfg.StatsD)
+		HandleErr(err, "Failed to create the StatsD bridge")
+		meterOpts = append(meterOpts, sdkmetric.WithReader(cfg.periodicReader(statsdExp)))
+	}
+	meterProvider := sdkmetric.NewMeterProvider(meterOpts...)
 	otel.SetMeterProvider(meterProvider)
 
-	traceClient := otlptracegrpc.NewClient(
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithEndpoint(otelAgentAddr),
-		otlptracegrpc.WithDialOption(grpc.WithBlock()))
-	traceExp, err := otlptrace.New(ctx, traceClient)
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	watcher := &connectionWatcher{}
+	if err := watcher.register(meterProvider.Meter(instrumentationName)); err != nil {
+		otel.Handle(err)
+	}
+	go watcher.watch(watchCtx, conn, cfg.ConnectionStateHandler)
+	status.set(cfg, otelAgentAddr, watcher)
+
+	traceExp, err := otlpGRPCAdapter{conn: conn, headers: cfg.Headers, timeout: cfg.OTLPTimeout}.spanExporter(ctx)
 	HandleErr(err, "Failed to create the collector trace exporter")
+	if cfg.BreakerFailures > 0 {
+		// inside the persistent queue, which keeps the spans the open
+		// circuit refuses
+		traceExp = NewCircuitBreakerExporter(traceExp, cfg.BreakerFailures, cfg.BreakerCooldown)
+	}
+	if cfg.QueueDir != "" {
+		traceExp, err = newPersistentExporter(cfg.QueueDir, cfg.QueueMaxBytes, traceExp)
+		HandleErr(err, "Failed to create the persistent span queue")
+	}
+	if cfg.Controller != nil {
+		traceExp = cfg.Controller.wrapExporter(traceExp)
+	}
 
-	bsp := sdktrace.NewBatchSpanProcessor(traceExp)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(GetSampler()),
-		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(bsp),
+	tracerProvider := newTracerProvider(cfg, res, traceExp)
+	secondaryProviders := newNamedProviders(cfg, res, func() (sdktrace.SpanExporter, error) {
+		return otlpGRPCAdapter{conn: conn, headers: cfg.Headers, timeout: cfg.OTLPTimeout}.spanExporter(ctx)
+	})
+
+	logExp, err := otlpGRPCAdapter{conn: conn, headers: cfg.Headers, timeout: cfg.OTLPTimeout}.logExporter(ctx)
+	HandleErr(err, "Failed to create the collector log exporter")
+	var logProcessor sdklog.Processor = sdklog.NewBatchProcessor(logExp)
+	if cfg.Redactor != nil {
+		logProcessor = NewRedactingLogProcessor(cfg.Redactor, logProcessor)
+	}
+	if cfg.LogSampling != nil {
+		logProcessor = newLogSamplingProcessor(logProcessor, *cfg.LogSampling)
+	}
+	if cfg.reload != nil {
+		logProcessor = NewRedactingLogProcessor(cfg.reload.redactor, logProcessor)
+		logProcessor = &severityFilterProcessor{Processor: logProcessor, min: &cfg.reload.minSeverity}
+	}
+	loggerProvider := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(logProcessor),
 	)
+	global.SetLoggerProvider(loggerProvider)
+
+	if cfg.Profiler != nil {
+		HandleErr(cfg.Profiler.Start(res), "Failed to start the profiler")
+	}
 
 	// set global propagator to tracecontext (the default is no-op).
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 	otel.SetTracerProvider(tracerProvider)
 
+	flushers := []flusher{tracerProvider, meterProvider, loggerProvider}
+	for _, tp := range secondaryProviders {
+		flushers = append(flushers, tp)
+	}
+	flush = func(ctx context.Context) error {
+		return forceFlush(ctx, flushers...)
+	}
+	if cfg.Controller != nil {
+		cfg.Controller.flush = flush
+	}
+	if cfg.reload != nil {
+		cfg.reload.start(flush)
+	}
 	return func() {
+		if cfg.reload != nil {
+			cfg.reload.Close()
+		}
 		cxt, cancel := context.WithTimeout(ctx, time.Second)
 		defer cancel()
-		if err := traceExp.Shutdown(cxt); err != nil {
+		// flushes pending spans and shuts every exporter down
+		if err := tracerProvider.Shutdown(cxt); err != nil {
+			otel.Handle(err)
+		}
+		if err := shutdownNamedProviders(cxt, secondaryProviders); err != nil {
 			otel.Handle(err)
 		}
 		// pushes any last exports to the receiver
 		if err := meterProvider.Shutdown(cxt); err != nil {
 			otel.Handle(err)
 		}
+		if err := loggerProvider.Shutdown(cxt); err != nil {
+			otel.Handle(err)
+		}
+		if cfg.Profiler != nil {
+			if err := cfg.Profiler.Stop(); err != nil {
+				otel.Handle(err)
+			}
+		}
+		// exporters do not own the shared connection
+		stopWatching()
+		if err := conn.Close(); err != nil {
+			otel.Handle(err)
+		}
+	}, flush
+}
+
+// Creates the tracer provider shared by every init path, exporting through
+// exp with the sampler, ID generator and span pipeline from cfg.
+func newTracerProvider(cfg Config, res *resource.Resource, exp sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	sampler := cfg.Sampler
+	if sampler == nil {
+		sampler = GetSampler()
+		if remote, ok := JaegerRemoteFromEnv(); ok {
+			sampler = NewJaegerRemoteSampler(cfg.ServiceName, remote)
+		}
+	}
+	if len(cfg.TenantSampling) > 0 {
+		sampler = NewTenantSampler(sampler, cfg.TenantSampling)
+	}
+	if cfg.degraded != nil {
+		sampler = newDegradedSampler(sampler, cfg.degraded)
+	}
+	if cfg.Controller != nil {
+		sampler = cfg.Controller.wrapSampler(sampler)
+	}
+	status.setSampler(sampler)
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(res),
+		sdktrace.WithRawSpanLimits(cfg.SpanLimits),
+		sdktrace.WithSpanProcessor(tenantSpanProcessor{}),
+		sdktrace.WithSpanProcessor(ambientSpanProcessor{}),
+		sdktrace.WithSpanProcessor(newSpanProcessor(cfg, exp)),
+		sdktrace.WithSpanProcessor(statusSpanProcessor{}),
+	}
+	if cfg.TruncationMetric {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(newTruncationProcessor(cfg.SpanLimits)))
+	}
+	if len(cfg.SLOs) > 0 {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(newSLOProcessor(cfg.SLOs)))
+	}
+	if cfg.IDGenerator != nil {
+		tpOpts = append(tpOpts, sdktrace.WithIDGenerator(cfg.IDGenerator))
+	}
+	if cfg.ZPages != nil {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(redacting(cfg, cfg.ZPages.processor)))
+	}
+	if cfg.SpanTail != nil {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(redacting(cfg, cfg.SpanTail)))
+	}
+	if cfg.Profiler != nil {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(profileIDProcessor{}))
+	}
+	if cfg.SpanNameCheck {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(NewSpanNameCheckProcessor(nil)))
+	}
+	return sdktrace.NewTracerProvider(tpOpts...)
+}
+
+// Wraps p with the redaction rules of cfg, so the spans served by zPages and
+// the span tail are scrubbed like the exported ones.
+func redacting(cfg Config, p sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	if cfg.Redactor != nil {
+		p = NewRedactingSpanProcessor(cfg.Redactor, p)
+	}
+	if cfg.reload != nil {
+		p = NewRedactingSpanProcessor(cfg.reload.redactor, p)
+	}
+	return p
+}
+
+// Assembles the span processing pipeline: the configured processors, then
+// filtering, then redaction, then compression, then throttling, then batching
+// towards the collector and any additional exporter.
+func newSpanProcessor(cfg Config, exp sdktrace.SpanExporter) sdktrace.SpanProcessor {
+	batchOpts := cfg.Batch.options()
+	newProcessor := func(exp sdktrace.SpanExporter) sdktrace.SpanProcessor {
+		if cfg.SyncExport {
+			return sdktrace.NewSimpleSpanProcessor(exp)
+		}
+		return sdktrace.NewBatchSpanProcessor(exp, batchOpts...)
+	}
+
+	var bsp sdktrace.SpanProcessor
+	if cfg.ErrorPriority && !cfg.SyncExport {
+		bsp = NewErrorPrioritySpanProcessor(exp, batchOpts...)
+	} else {
+		bsp = newProcessor(exp)
+	}
+	exporters := cfg.Exporters
+	if cfg.SyncExport {
+		exporters = append(exporters[:len(exporters):len(exporters)], NamedSpanExporter{Name: "log", Exporter: logExporter{}})
+	}
+	if len(exporters) > 0 {
+		processors := []sdktrace.SpanProcessor{bsp}
+		for _, e := range exporters {
+			processors = append(processors, newProcessor(NamedExporter(e.Name, e.Exporter)))
+		}
+		bsp = NewFanOutSpanProcessor(processors...)
+	}
+	if cfg.SpanRateLimit > 0 {
+		bsp = NewThrottlingSpanProcessor(bsp, cfg.SpanRateLimit, cfg.SpanRateBurst)
+	}
+	if len(cfg.CompressionPatterns) > 0 {
+		bsp = NewCompressingSpanProcessor(bsp, cfg.CompressionMaxDuration, cfg.CompressionPatterns...)
+	}
+	bsp = redacting(cfg, bsp)
+	if len(cfg.SpanFilters) > 0 {
+		bsp = NewFilterSpanProcessor(bsp, cfg.SpanFilters...)
+	}
+	if len(cfg.SpanPipeline) > 0 {
+		var err error
+		bsp, err = buildSpanPipeline(cfg.SpanPipeline, bsp)
+		HandleErr(err, "Invalid span pipeline")
 	}
+	return bsp
 }
 
 func HandleErr(err error, message string) {
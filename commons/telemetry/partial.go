@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	collmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	colltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// Attribute naming the kind of telemetry rejected by the collector.
+const SignalKey = attribute.Key("signal")
+
+// Switches to ratio sampling for period whenever the collector rejects
+// spans, easing the load until it accepts them again.
+func WithDegradedSampling(ratio float64, period time.Duration) Option {
+	return func(c *Config) {
+		c.DegradedSamplingRatio = ratio
+		c.DegradedPeriod = period
+	}
+}
+
+// Reports the data the collector rejected in partial success responses,
+// which the exporters otherwise only hand to the global error handler, as
+// the telemetry.exporter.rejected counter and a warning log.
+type partialSuccessReporter struct {
+	once     sync.Once
+	rejected metric.Int64Counter
+	// entered when spans are rejected, nil when disabled
+	degraded *degradedMode
+}
+
+// gRPC interceptor inspecting the export responses of the collector.
+func (p *partialSuccessReporter) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		return err
+	}
+	switch r := reply.(type) {
+	case *colltracepb.ExportTraceServiceResponse:
+		if ps := r.GetPartialSuccess(); ps != nil {
+			p.report(ctx, "traces", ps.GetRejectedSpans(), ps.GetErrorMessage())
+			if ps.GetRejectedSpans() > 0 && p.degraded != nil {
+				p.degraded.enter()
+			}
+		}
+	case *collmetricpb.ExportMetricsServiceResponse:
+		if ps := r.GetPartialSuccess(); ps != nil {
+			p.report(ctx, "metrics", ps.GetRejectedDataPoints(), ps.GetErrorMessage())
+		}
+	}
+	return nil
+}
+
+func (p *partialSuccessReporter) report(ctx context.Context, signal string, rejected int64, message string) {
+	if rejected == 0 && message == "" {
+		return
+	}
+	p.once.Do(func() {
+		var err error
+		p.rejected, err = Meter(instrumentationName, "").Int64Counter(
+			"telemetry.exporter.rejected",
+			metric.WithDescription("Spans and metric data points rejected by the collector."),
+		)
+		if err != nil {
+			otel.Handle(err)
+		}
+		RegisterMetrics(Descriptor{
+			Name:        "telemetry.exporter.rejected",
+			Kind:        "counter",
+			Description: "Spans and metric data points rejected by the collector.",
+			Attributes:  []string{string(SignalKey)},
+		})
+	})
+	if p.rejected != nil && rejected > 0 {
+		p.rejected.Add(ctx, rejected, metric.WithAttributes(SignalKey.String(signal)))
+	}
+	slog.Warn("collector rejected telemetry", "signal", signal, "rejected", rejected, "message", message)
+}
+
+// Sampling state lowered for a while after the collector rejected spans.
+type degradedMode struct {
+	ratio  float64
+	period time.Duration
+	// end of the degraded period as Unix nanoseconds
+	until atomic.Int64
+}
+
+func (d *degradedMode) enter() {
+	if d.until.Swap(time.Now().Add(d.period).UnixNano()) < time.Now().UnixNano() {
+		slog.Warn("collector rejecting spans, reducing sampling", "ratio", d.ratio, "period", d.period)
+	}
+}
+
+func (d *degradedMode) active() bool {
+	return time.Now().UnixNano() < d.until.Load()
+}
+
+// Sampler additionally sampling root spans at the degraded ratio while the
+// degraded mode is active.
+type degradedSampler struct {
+	base    sdktrace.Sampler
+	reduced sdktrace.Sampler
+	mode    *degradedMode
+}
+
+func newDegradedSampler(base sdktrace.Sampler, mode *degradedMode) sdktrace.Sampler {
+	return degradedSampler{base: base, reduced: sdktrace.TraceIDRatioBased(mode.ratio), mode: mode}
+}
+
+func (s degradedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.base.ShouldSample(p)
+	if res.Decision != sdktrace.RecordAndSample || !s.mode.active() {
+		return res
+	}
+	// children follow the decision taken for their root
+	if psc := trace.SpanContextFromContext(p.ParentContext); psc.IsValid() {
+		return res
+	}
+	if s.reduced.ShouldSample(p).Decision == sdktrace.Drop {
+		res.Decision = sdktrace.Drop
+	}
+	return res
+}
+
+func (s degradedSampler) Description() string {
+	return "Degraded{" + s.base.Description() + "}"
+}
@@ -0,0 +1,13 @@
+package telemetry
+
+import (
+	"net/http"
+)
+
+// Creates an HTTP client for calls to the downstream service named peer.
+// Client spans are named after the method and the peer, e.g.
+// "GET warehouse", and carry the peer.service attribute so backends can draw
+// the service graph even when the peer is not instrumented.
+func NewPeerClient(peer string, base http.RoundTripper, opts ...ClientOption) *http.Client {
+	return newHTTPClient(clientConfig{peer: peer, clock: SystemClock}, base, opts)
+}
@@ -0,0 +1,213 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Creates a span processor handing spans to next, configured by params.
+type SpanProcessorFactory func(next sdktrace.SpanProcessor, params map[string]string) (sdktrace.SpanProcessor, error)
+
+// Span processor of the pipeline, identified by the name its factory was
+// registered under.
+type ProcessorSpec struct {
+	Name   string
+	Params map[string]string
+}
+
+var spanProcessors = struct {
+	mu        sync.RWMutex
+	factories map[string]SpanProcessorFactory
+}{factories: map[string]SpanProcessorFactory{
+	"filter":       newFilterFromParams,
+	"redact":       newRedactorFromParams,
+	"baggage-copy": newBaggageCopyFromParams,
+	"throttle":     newThrottleFromParams,
+}}
+
+// Registers factory under name for WithSpanPipeline, replacing any factory
+// registered under the same name. The built-in ones are filter, redact,
+// baggage-copy and throttle.
+func RegisterSpanProcessor(name string, factory SpanProcessorFactory) {
+	spanProcessors.mu.Lock()
+	defer spanProcessors.mu.Unlock()
+	spanProcessors.factories[name] = factory
+}
+
+func spanProcessorFactory(name string) (SpanProcessorFactory, bool) {
+	spanProcessors.mu.RLock()
+	defer spanProcessors.mu.RUnlock()
+	factory, ok := spanProcessors.factories[name]
+	return factory, ok
+}
+
+// Returns the names of the registered span processors, sorted.
+func SpanProcessorNames() []string {
+	spanProcessors.mu.RLock()
+	defer spanProcessors.mu.RUnlock()
+	names := make([]string, 0, len(spanProcessors.factories))
+	for name := range spanProcessors.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Parses a pipeline such as
+// "filter:names=GET /healthz|GET /readyz;baggage-copy:keys=destination",
+// processors being separated by semicolons, parameters by commas and the
+// values of list parameters by pipes.
+func ParseSpanPipeline(s string) ([]ProcessorSpec, error) {
+	var specs []ProcessorSpec
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, rawParams, _ := strings.Cut(item, ":")
+		spec := ProcessorSpec{Name: strings.TrimSpace(name), Params: map[string]string{}}
+		for _, pair := range strings.Split(rawParams, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid parameter %q of span processor %q, expected key=value", pair, spec.Name)
+			}
+			spec.Params[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Builds the span processors of specs in front of next, spans going through
+// them in order.
+func buildSpanPipeline(specs []ProcessorSpec, next sdktrace.SpanProcessor) (sdktrace.SpanProcessor, error) {
+	for i := len(specs) - 1; i >= 0; i-- {
+		spec := specs[i]
+		factory, ok := spanProcessorFactory(spec.Name)
+		if !ok {
+			return nil, fmt.Errorf("unknown span processor %q, registered ones are %s", spec.Name, strings.Join(SpanProcessorNames(), ", "))
+		}
+		p, err := factory(next, spec.Params)
+		if err != nil {
+			return nil, fmt.Errorf("span processor %q: %w", spec.Name, err)
+		}
+		next = p
+	}
+	return next, nil
+}
+
+// Returns the pipe-separated values of a list parameter.
+func listParam(params map[string]string, key string) []string {
+	var values []string
+	for _, v := range strings.Split(params[key], "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Fails on the parameters that are not among allowed, catching typos.
+func checkParams(params map[string]string, allowed ...string) error {
+	for key := range params {
+		if !slices.Contains(allowed, key) {
+			return fmt.Errorf("unknown parameter %q, expected one of %s", key, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// filter: drops the spans named after one of names, and those shorter than
+// min_duration unless they failed.
+func newFilterFromParams(next sdktrace.SpanProcessor, params map[string]string) (sdktrace.SpanProcessor, error) {
+	if err := checkParams(params, "names", "min_duration"); err != nil {
+		return nil, err
+	}
+	var drop []SpanPredicate
+	if names := listParam(params, "names"); len(names) > 0 {
+		drop = append(drop, DropSpansNamed(names...))
+	}
+	if v := params["min_duration"]; v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_duration %q: %w", v, err)
+		}
+		drop = append(drop, DropShortSpans(d))
+	}
+	if len(drop) == 0 {
+		return nil, fmt.Errorf("expected names or min_duration")
+	}
+	return NewFilterSpanProcessor(next, drop...), nil
+}
+
+// redact: drops the attributes listed in drop, hashes those in hash.
+func newRedactorFromParams(next sdktrace.SpanProcessor, params map[string]string) (sdktrace.SpanProcessor, error) {
+	if err := checkParams(params, "drop", "hash"); err != nil {
+		return nil, err
+	}
+	drop, hash := listParam(params, "drop"), listParam(params, "hash")
+	if len(drop) == 0 && len(hash) == 0 {
+		return nil, fmt.Errorf("expected drop or hash")
+	}
+	return NewRedactingSpanProcessor(NewRedactor(DenyKeys(RedactDrop, drop...), DenyKeys(RedactHash, hash...)), next), nil
+}
+
+// baggage-copy: sets the baggage members listed in keys, all of them when
+// empty, as attributes of the spans started under them.
+func newBaggageCopyFromParams(next sdktrace.SpanProcessor, params map[string]string) (sdktrace.SpanProcessor, error) {
+	if err := checkParams(params, "keys"); err != nil {
+		return nil, err
+	}
+	return &baggageCopyProcessor{SpanProcessor: next, keys: listParam(params, "keys")}, nil
+}
+
+// throttle: forwards at most rate spans per second, in bursts of up to burst.
+func newThrottleFromParams(next sdktrace.SpanProcessor, params map[string]string) (sdktrace.SpanProcessor, error) {
+	if err := checkParams(params, "rate", "burst"); err != nil {
+		return nil, err
+	}
+	rate, err := strconv.ParseFloat(params["rate"], 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("invalid rate %q, expected a positive number", params["rate"])
+	}
+	burst := 1
+	if v := params["burst"]; v != "" {
+		if burst, err = strconv.Atoi(v); err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid burst %q, expected a positive integer", v)
+		}
+	}
+	return NewThrottlingSpanProcessor(next, rate, burst), nil
+}
+
+// Span processor copying baggage members onto the spans as they start.
+type baggageCopyProcessor struct {
+	sdktrace.SpanProcessor
+	keys []string
+}
+
+func (p *baggageCopyProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	bag := baggage.FromContext(parent)
+	if len(p.keys) == 0 {
+		for _, m := range bag.Members() {
+			s.SetAttributes(attribute.String(m.Key(), m.Value()))
+		}
+	} else {
+		for _, k := range p.keys {
+			if m := bag.Member(k); m.Key() != "" {
+				s.SetAttributes(attribute.String(k, m.Value()))
+			}
+		}
+	}
+	p.SpanProcessor.OnStart(parent, s)
+}
@@ -0,0 +1,184 @@
+package telemetry
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Attribute telling whether a pooled connection is serving a request.
+const ConnectionStateKey = attribute.Key("http.connection.state")
+
+// Middleware tracking the requests being served in the
+// http.server.active_requests UpDownCounter, a capacity signal telling how
+// close the service is to saturation.
+func InFlightMiddleware(serverName string) mux.MiddlewareFunc {
+	active, err := Meter(serverName, "").Int64UpDownCounter(
+		"http.server.active_requests",
+		metric.WithUnit("{request}"),
+		metric.WithDescription("Number of HTTP server requests in flight."),
+	)
+	HandleErr(err, "Failed to create the in-flight requests counter")
+	RegisterMetrics(Descriptor{
+		Name:        "http.server.active_requests",
+		Kind:        "updowncounter",
+		Unit:        "{request}",
+		Description: "Number of HTTP server requests in flight.",
+		Attributes:  []string{string(semconv.HTTPRequestMethodKey)},
+	})
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attrs := metric.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method))
+			active.Add(r.Context(), 1, attrs)
+			defer active.Add(r.Context(), -1, attrs)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Instruments the connection pool of t, used for calls to the service named
+// peer: the http.client.open_connections gauge reports its active and idle
+// connections, and the http.client.connection.wait_duration histogram how
+// long requests waited for a connection. t must not be shared with another
+// peer. HTTP/2 connections, shared by concurrent requests, count as active
+// while open.
+func InstrumentTransport(peer string, t *http.Transport) http.RoundTripper {
+	meter := Meter(instrumentationName, "")
+	p := &connPool{}
+	peerAttr := semconv.PeerService(peer)
+	_, err := meter.Int64ObservableUpDownCounter(
+		"http.client.open_connections",
+		metric.WithUnit("{connection}"),
+		metric.WithDescription("Number of connections of the HTTP client pool, by state."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			open, active := p.open.Load(), p.active.Load()
+			o.Observe(active, metric.WithAttributes(peerAttr, ConnectionStateKey.String("active")))
+			o.Observe(open-active, metric.WithAttributes(peerAttr, ConnectionStateKey.String("idle")))
+			return nil
+		}),
+	)
+	HandleErr(err, "Failed to create the connection pool gauge")
+	wait, err := meter.Float64Histogram(
+		"http.client.connection.wait_duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time requests waited for a connection of the HTTP client pool."),
+		metric.WithExplicitBucketBoundaries(latencyBoundaries...),
+	)
+	HandleErr(err, "Failed to create the connection wait histogram")
+	RegisterMetrics(
+		Descriptor{
+			Name:        "http.client.open_connections",
+			Kind:        "updowncounter",
+			Unit:        "{connection}",
+			Description: "Number of connections of the HTTP client pool, by state.",
+			Attributes:  []string{string(semconv.PeerServiceKey), string(ConnectionStateKey)},
+		},
+		Descriptor{
+			Name:        "http.client.connection.wait_duration",
+			Kind:        "histogram",
+			Unit:        "s",
+			Description: "Time requests waited for a connection of the HTTP client pool.",
+			Attributes:  []string{string(semconv.PeerServiceKey)},
+		},
+	)
+
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		p.open.Add(1)
+		return &pooledConn{Conn: conn, pool: p}, nil
+	}
+	return &poolTransport{base: t, pool: p, wait: wait, attrs: metric.WithAttributes(peerAttr)}
+}
+
+// Connection counts of an instrumented pool.
+type connPool struct {
+	open   atomic.Int64
+	active atomic.Int64
+}
+
+// Connection of an instrumented pool, tracking whether it serves a request.
+type pooledConn struct {
+	net.Conn
+	pool   *connPool
+	active atomic.Bool
+	once   sync.Once
+}
+
+func (c *pooledConn) setActive(active bool) {
+	if c.active.CompareAndSwap(!active, active) {
+		if active {
+			c.pool.active.Add(1)
+		} else {
+			c.pool.active.Add(-1)
+		}
+	}
+}
+
+func (c *pooledConn) Close() error {
+	c.once.Do(func() {
+		c.setActive(false)
+		c.pool.open.Add(-1)
+	})
+	return c.Conn.Close()
+}
+
+// Finds the pooled connection under conn, TLS connections wrapping it.
+func unwrapPooledConn(conn net.Conn) *pooledConn {
+	for conn != nil {
+		if c, ok := conn.(*pooledConn); ok {
+			return c
+		}
+		u, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = u.NetConn()
+	}
+	return nil
+}
+
+// Round tripper following the connections its requests get from the pool.
+type poolTransport struct {
+	base  http.RoundTripper
+	pool  *connPool
+	wait  metric.Float64Histogram
+	attrs metric.MeasurementOption
+}
+
+func (t *poolTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	var requested time.Time
+	var conn *pooledConn
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) { requested = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.wait.Record(ctx, time.Since(requested).Seconds(), t.attrs)
+			if conn = unwrapPooledConn(info.Conn); conn != nil {
+				conn.setActive(true)
+			}
+		},
+		PutIdleConn: func(error) {
+			if conn != nil {
+				conn.setActive(false)
+			}
+		},
+	}
+	return t.base.RoundTrip(r.WithContext(httptrace.WithClientTrace(ctx, trace)))
+}
@@ -0,0 +1,48 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Names of the pprof labels set by ProfilingLabelsMiddleware.
+const (
+	PprofTraceIDLabel = "trace_id"
+	PprofSpanIDLabel  = "span_id"
+)
+
+// Middleware running the rest of the request with the trace and span IDs of
+// its span set as pprof labels, so CPU profiles can be sliced by trace, e.g.
+// go tool pprof -tagfocus trace_id=<id>. It must be installed after the
+// tracing middleware.
+func ProfilingLabelsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc := trace.SpanContextFromContext(r.Context())
+		if !sc.IsValid() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		labels := runtimepprof.Labels(
+			PprofTraceIDLabel, sc.TraceID().String(),
+			PprofSpanIDLabel, sc.SpanID().String(),
+		)
+		runtimepprof.Do(r.Context(), labels, func(ctx context.Context) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
+
+// Returns the net/http/pprof handlers, to be mounted under /debug/pprof/.
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
@@ -0,0 +1,64 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	priorityBatchSize    = 32
+	priorityBatchTimeout = 500 * time.Millisecond
+)
+
+// Span processor routing spans with an error status through a dedicated
+// fast-path batch queue, so they reach the backend within seconds while
+// regular spans keep the standard batching.
+type errorPriorityProcessor struct {
+	normal   sdktrace.SpanProcessor
+	priority sdktrace.SpanProcessor
+}
+
+// Creates a span processor exporting error spans ahead of the regular batch
+// queue. The options configure the regular queue only; both queues share the
+// given exporter.
+func NewErrorPrioritySpanProcessor(exporter sdktrace.SpanExporter, opts ...sdktrace.BatchSpanProcessorOption) sdktrace.SpanProcessor {
+	return &errorPriorityProcessor{
+		normal: sdktrace.NewBatchSpanProcessor(exporter, opts...),
+		priority: sdktrace.NewBatchSpanProcessor(
+			sharedExporter{exporter},
+			sdktrace.WithMaxExportBatchSize(priorityBatchSize),
+			sdktrace.WithBatchTimeout(priorityBatchTimeout),
+		),
+	}
+}
+
+func (p *errorPriorityProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *errorPriorityProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.Status().Code == codes.Error {
+		p.priority.OnEnd(s)
+		return
+	}
+	p.normal.OnEnd(s)
+}
+
+// Shuts the priority queue down first, leaving the shared exporter to be
+// closed by the regular queue.
+func (p *errorPriorityProcessor) Shutdown(ctx context.Context) error {
+	return errors.Join(p.priority.Shutdown(ctx), p.normal.Shutdown(ctx))
+}
+
+func (p *errorPriorityProcessor) ForceFlush(ctx context.Context) error {
+	return errors.Join(p.priority.ForceFlush(ctx), p.normal.ForceFlush(ctx))
+}
+
+// Exporter whose lifecycle is owned by another processor.
+type sharedExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (sharedExporter) Shutdown(context.Context) error { return nil }
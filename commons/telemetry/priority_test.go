@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// An error span is exported by the fast-path queue while the spans of the
+// regular queue still wait for their batch.
+func TestErrorPriorityExportsErrorSpansFirst(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(
+		NewErrorPrioritySpanProcessor(exp, sdktrace.WithBatchTimeout(time.Hour)),
+	))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	tracer := tp.Tracer("priority-test")
+
+	_, ok := tracer.Start(context.Background(), "lookup found")
+	ok.End()
+	_, failed := tracer.Start(context.Background(), "lookup failed")
+	failed.SetStatus(codes.Error, "package not found")
+	failed.End()
+
+	deadline := time.Now().Add(5 * priorityBatchTimeout)
+	for len(exp.GetSpans()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "lookup failed" {
+		t.Fatalf("exported %v, want only the error span ahead of the regular batch", spans)
+	}
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(exp.GetSpans()); n != 2 {
+		t.Errorf("exported %d spans after the flush, want 2", n)
+	}
+}
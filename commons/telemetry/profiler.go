@@ -0,0 +1,193 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attribute holding the ID profile samples of the span are labelled
+// with, the span ID which ProfilingLabelsMiddleware sets as pprof label.
+const ProfileIDKey = attribute.Key("profile_id")
+
+// Continuous profiler, such as a Pyroscope or Parca agent, started along the
+// providers and stopped on shutdown.
+type Profiler interface {
+	// starts profiling, describing the profiles with the service resource
+	Start(res *resource.Resource) error
+	// stops profiling, sending what was collected
+	Stop() error
+}
+
+// Starts the continuous profiler p with the providers. Local root spans get
+// their ID as profile_id attribute, so backends link them to the profile
+// samples ProfilingLabelsMiddleware labels with it.
+func WithProfiler(p Profiler) Option {
+	return func(c *Config) {
+		c.Profiler = p
+	}
+}
+
+// Span processor setting profile_id on local root spans.
+type profileIDProcessor struct{}
+
+func (profileIDProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if psc := trace.SpanContextFromContext(parent); psc.IsValid() && !psc.IsRemote() {
+		return
+	}
+	s.SetAttributes(ProfileIDKey.String(s.SpanContext().SpanID().String()))
+}
+
+func (profileIDProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (profileIDProcessor) Shutdown(context.Context) error { return nil }
+
+func (profileIDProcessor) ForceFlush(context.Context) error { return nil }
+
+// Resource attributes sent as profile tags.
+var profileTagKeys = []attribute.Key{
+	semconv.ServiceVersionKey,
+	semconv.ServiceNamespaceKey,
+	semconv.DeploymentEnvironmentKey,
+	semconv.HostNameKey,
+	semconv.K8SPodNameKey,
+}
+
+// Profiler pushing CPU profiles to a Pyroscope server through its ingest
+// API, one profile per interval.
+type PyroscopeProfiler struct {
+	// base URL of the server, e.g. http://pyroscope:4040
+	ServerURL string
+	// duration of each profile, 10s when zero
+	Interval time.Duration
+	// client pushing the profiles, http.DefaultClient when nil
+	Client *http.Client
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+func (p *PyroscopeProfiler) Start(res *resource.Resource) error {
+	if p.ServerURL == "" {
+		return fmt.Errorf("pyroscope profiler needs a server URL")
+	}
+	if p.Interval <= 0 {
+		p.Interval = 10 * time.Second
+	}
+	if p.Client == nil {
+		p.Client = http.DefaultClient
+	}
+	name := pyroscopeAppName(res)
+	p.stop = make(chan struct{})
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		for !p.profile(name) {
+		}
+	}()
+	return nil
+}
+
+func (p *PyroscopeProfiler) Stop() error {
+	if p.stop == nil {
+		return nil
+	}
+	close(p.stop)
+	p.done.Wait()
+	return nil
+}
+
+// Collects and pushes one CPU profile, reporting whether the profiler was
+// stopped meanwhile.
+func (p *PyroscopeProfiler) profile(name string) (stopped bool) {
+	var buf bytes.Buffer
+	from := time.Now()
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		// someone else is profiling, e.g. through /debug/pprof/profile
+		log.Printf("telemetry: skipping continuous profile: %v", err)
+		select {
+		case <-p.stop:
+			return true
+		case <-time.After(p.Interval):
+			return false
+		}
+	}
+	select {
+	case <-p.stop:
+		stopped = true
+	case <-time.After(p.Interval):
+	}
+	pprof.StopCPUProfile()
+
+	if err := p.push(name, from, time.Now(), buf.Bytes()); err != nil {
+		log.Printf("telemetry: failed to push profile: %v", err)
+	}
+	return stopped
+}
+
+func (p *PyroscopeProfiler) push(name string, from, until time.Time, profile []byte) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("profile", "cpu.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(profile); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	q.Set("name", name)
+	q.Set("from", strconv.FormatInt(from.Unix(), 10))
+	q.Set("until", strconv.FormatInt(until.Unix(), 10))
+	q.Set("spyName", "gospy")
+	q.Set("format", "pprof")
+	res, err := p.Client.Post(strings.TrimSuffix(p.ServerURL, "/")+"/ingest?"+q.Encode(), form.FormDataContentType(), &body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("pyroscope answered %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Names the profiles after the service, tagged with its resource
+// attributes, e.g. otel-example-server.cpu{service_version=1.2.0}.
+func pyroscopeAppName(res *resource.Resource) string {
+	set := res.Set()
+	service := "unknown_service"
+	if v, ok := set.Value(semconv.ServiceNameKey); ok {
+		service = v.AsString()
+	}
+	var tags []string
+	for _, key := range profileTagKeys {
+		if v, ok := set.Value(key); ok && v.AsString() != "" {
+			tags = append(tags, strings.ReplaceAll(string(key), ".", "_")+"="+v.AsString())
+		}
+	}
+	sort.Strings(tags)
+	return service + ".cpu{" + strings.Join(tags, ",") + "}"
+}
@@ -0,0 +1,121 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Resource attribute naming the secondary provider spans come from, so
+// backends can tell them apart from the spans of the main pipeline.
+const ProviderNameKey = attribute.Key("telemetry.provider")
+
+// Secondary tracer provider isolated from the main pipeline, e.g. for
+// verbose debug-only spans. Its spans are batched in a queue of their own,
+// so a noisy component cannot crowd out the spans of the service, and skip
+// the sampler, filters and rate limit of the main pipeline. Redaction and
+// the controller's tracing switch still apply.
+type ProviderConfig struct {
+	// sampler of the provider's spans, AlwaysSample when nil
+	Sampler sdktrace.Sampler
+	// exporters of the provider's spans, the collector when empty
+	Exporters []NamedSpanExporter
+}
+
+// Creates a secondary tracer provider along the main one, returned by
+// Provider(name) once InitProvider ran, e.g.
+//
+//	telemetry.WithNamedProvider("debug", telemetry.ProviderConfig{
+//		Sampler: sdktrace.TraceIDRatioBased(0.01),
+//	})
+func WithNamedProvider(name string, pc ProviderConfig) Option {
+	return func(c *Config) {
+		if c.Providers == nil {
+			c.Providers = map[string]ProviderConfig{}
+		}
+		c.Providers[name] = pc
+	}
+}
+
+var namedProviders = struct {
+	mu        sync.RWMutex
+	providers map[string]trace.TracerProvider
+}{}
+
+// Returns the secondary tracer provider created as name by
+// WithNamedProvider, a no-op one when there is none, so instrumentation
+// using it is switched off by leaving the provider out, e.g.
+//
+//	tracer := telemetry.Provider("debug").Tracer("app1/cache")
+func Provider(name string) trace.TracerProvider {
+	namedProviders.mu.RLock()
+	defer namedProviders.mu.RUnlock()
+	if tp, ok := namedProviders.providers[name]; ok {
+		return tp
+	}
+	return noop.NewTracerProvider()
+}
+
+// Creates the secondary tracer providers of cfg, exporting through a
+// collector exporter of their own unless they have exporters, and makes
+// them available through Provider.
+func newNamedProviders(cfg Config, res *resource.Resource, collector func() (sdktrace.SpanExporter, error)) []*sdktrace.TracerProvider {
+	created := make([]*sdktrace.TracerProvider, 0, len(cfg.Providers))
+	registry := make(map[string]trace.TracerProvider, len(cfg.Providers))
+	for name, pc := range cfg.Providers {
+		exporters := pc.Exporters
+		if len(exporters) == 0 {
+			exp, err := collector()
+			HandleErr(err, "Failed to create the span exporter of provider "+name)
+			exporters = []NamedSpanExporter{{Name: "otlp", Exporter: exp}}
+		}
+		processors := make([]sdktrace.SpanProcessor, 0, len(exporters))
+		for _, e := range exporters {
+			processors = append(processors, sdktrace.NewBatchSpanProcessor(NamedExporter(name+"/"+e.Name, e.Exporter), cfg.Batch.options()...))
+		}
+		var processor sdktrace.SpanProcessor = NewFanOutSpanProcessor(processors...)
+		if cfg.Redactor != nil {
+			processor = NewRedactingSpanProcessor(cfg.Redactor, processor)
+		}
+		if cfg.reload != nil {
+			processor = NewRedactingSpanProcessor(cfg.reload.redactor, processor)
+		}
+
+		sampler := pc.Sampler
+		if sampler == nil {
+			sampler = sdktrace.AlwaysSample()
+		}
+		if cfg.Controller != nil {
+			sampler = controlledSampler{controller: cfg.Controller, next: sampler}
+		}
+		named, err := resource.Merge(res, resource.NewSchemaless(ProviderNameKey.String(name)))
+		HandleErr(err, "Failed to create the resource of provider "+name)
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithSampler(sampler),
+			sdktrace.WithResource(named),
+			sdktrace.WithRawSpanLimits(cfg.SpanLimits),
+			sdktrace.WithSpanProcessor(processor),
+		)
+		created = append(created, tp)
+		registry[name] = tp
+	}
+	namedProviders.mu.Lock()
+	namedProviders.providers = registry
+	namedProviders.mu.Unlock()
+	return created
+}
+
+// Shuts the secondary providers down, flushing their pending spans.
+func shutdownNamedProviders(ctx context.Context, providers []*sdktrace.TracerProvider) error {
+	var errs []error
+	for _, tp := range providers {
+		errs = append(errs, tp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
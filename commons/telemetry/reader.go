@@ -0,0 +1,57 @@
+package telemetry
+
+import (
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Interval between metric exports unless configured otherwise, short so the
+// demo dashboards update quickly.
+const DefaultMetricInterval = 2 * time.Second
+
+// Sets the interval between two metric exports, overriding
+// OTEL_METRIC_EXPORT_INTERVAL.
+func WithMetricInterval(d time.Duration) Option {
+	return func(c *Config) {
+		c.MetricInterval = d
+	}
+}
+
+// Bounds the duration of one metric export, overriding
+// OTEL_METRIC_EXPORT_TIMEOUT.
+func WithMetricExportTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.MetricExportTimeout = d
+	}
+}
+
+// Collects metrics through reader instead of exporting them periodically to
+// the collector, e.g. an sdkmetric.NewManualReader() read on demand by tests.
+func WithMetricReader(reader sdkmetric.Reader) Option {
+	return func(c *Config) {
+		c.MetricReader = reader
+	}
+}
+
+// Returns the reader of the meter provider: the configured one, or a
+// periodic reader pushing to exp.
+func (c Config) metricReader(exp sdkmetric.Exporter) sdkmetric.Reader {
+	if c.MetricReader != nil {
+		return c.MetricReader
+	}
+	return c.periodicReader(exp)
+}
+
+// Returns a reader pushing to exp at the configured interval.
+func (c Config) periodicReader(exp sdkmetric.Exporter) sdkmetric.Reader {
+	interval := c.MetricInterval
+	if interval <= 0 {
+		interval = DefaultMetricInterval
+	}
+	opts := []sdkmetric.PeriodicReaderOption{sdkmetric.WithInterval(interval)}
+	if c.MetricExportTimeout > 0 {
+		opts = append(opts, sdkmetric.WithTimeout(c.MetricExportTimeout))
+	}
+	return sdkmetric.NewPeriodicReader(exp, opts...)
+}
@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Deferred in main and at the top of goroutines, records a panic on the span
+// of ctx with its stack trace, emits a fatal log record, flushes every
+// provider so the telemetry leading to the crash is exported, then panics
+// again:
+//
+//	defer telemetry.RecoverAndFlush(ctx)
+//
+// The span of ctx is ended before the flush, as its own deferred End would
+// only run afterwards. It must be deferred directly, recover having no effect
+// otherwise.
+func RecoverAndFlush(ctx context.Context) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := string(debug.Stack())
+	msg := fmt.Sprint(r)
+	exceptionType := fmt.Sprintf("%T", r)
+	if err, ok := r.(error); ok {
+		msg = err.Error()
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(
+		semconv.ExceptionType(exceptionType),
+		semconv.ExceptionMessage(msg),
+		semconv.ExceptionStacktrace(stack),
+		semconv.ExceptionEscaped(true),
+	))
+	span.SetStatus(codes.Error, "panic")
+	span.End()
+
+	var rec otellog.Record
+	rec.SetTimestamp(time.Now())
+	rec.SetSeverity(otellog.SeverityFatal)
+	rec.SetSeverityText(otellog.SeverityFatal.String())
+	rec.SetBody(otellog.StringValue("panic: " + msg))
+	rec.AddAttributes(
+		otellog.String(string(semconv.ExceptionTypeKey), exceptionType),
+		otellog.String(string(semconv.ExceptionMessageKey), msg),
+		otellog.String(string(semconv.ExceptionStacktraceKey), stack),
+	)
+	global.Logger(instrumentationName).Emit(ctx, rec)
+
+	if err := Flush(context.Background()); err != nil {
+		log.Printf("telemetry: flush before crashing: %v", err)
+	}
+	panic(r)
+}
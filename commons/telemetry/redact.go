@@ -0,0 +1,395 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"slices"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Common PII patterns usable with RedactValues. Matches of
+// CardNumberPattern are only redacted when they pass the Luhn check, so
+// timestamps and order IDs of the same length are kept.
+var (
+	EmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	CardNumberPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+)
+
+// Checks the matches of the patterns above must pass to be redacted.
+var patternChecks = map[*regexp.Regexp]func(string) bool{
+	CardNumberPattern: luhnValid,
+}
+
+// What happens to a redacted attribute.
+type RedactionAction int
+
+const (
+	// removes the attribute altogether
+	RedactDrop RedactionAction = iota
+	// replaces the value with its keyed HMAC-SHA256 digest, keeping it
+	// correlatable without it being reversible by guessing
+	RedactHash
+)
+
+// Environment variable holding the key of the RedactHash digests, shared by
+// the replicas of a service so their digests match.
+const RedactionKeyEnv = "TELEMETRY_REDACTION_KEY"
+
+type valueRule struct {
+	pattern *regexp.Regexp
+	// check matches must pass to be redacted, all are when nil
+	check  func(string) bool
+	action RedactionAction
+}
+
+// Set of allow/deny rules scrubbing attributes before they leave the process.
+type Redactor struct {
+	allow  map[attribute.Key]bool
+	deny   map[attribute.Key]RedactionAction
+	values []valueRule
+	// key of the RedactHash digests
+	key []byte
+	// rules set by Update, replacing the ones above
+	updated atomic.Pointer[Redactor]
+}
+
+// Configures a Redactor.
+type RedactionOption func(*Redactor)
+
+// Redacts the attributes with the given keys.
+func DenyKeys(action RedactionAction, keys ...string) RedactionOption {
+	return func(r *Redactor) {
+		for _, k := range keys {
+			r.deny[attribute.Key(k)] = action
+		}
+	}
+}
+
+// Exempts the attributes with the given keys from any redaction.
+func AllowKeys(keys ...string) RedactionOption {
+	return func(r *Redactor) {
+		for _, k := range keys {
+			r.allow[attribute.Key(k)] = true
+		}
+	}
+}
+
+// Redacts the parts of string values matching pattern. Dropping a match
+// removes the whole attribute, hashing replaces the match only.
+func RedactValues(pattern *regexp.Regexp, action RedactionAction) RedactionOption {
+	return func(r *Redactor) {
+		r.values = append(r.values, valueRule{pattern: pattern, check: patternChecks[pattern], action: action})
+	}
+}
+
+// Keys the RedactHash digests with key instead of RedactionKeyEnv.
+func HashKey(key []byte) RedactionOption {
+	return func(r *Redactor) {
+		r.key = key
+	}
+}
+
+// Creates a Redactor from the given rules. Digests are keyed with the
+// HashKey option, RedactionKeyEnv, or else a random key, so they only
+// correlate within the process.
+func NewRedactor(opts ...RedactionOption) *Redactor {
+	return newRedactor(nil, opts)
+}
+
+// Creates a Redactor keyed with key unless opts set another one.
+func newRedactor(key []byte, opts []RedactionOption) *Redactor {
+	r := &Redactor{
+		allow: map[attribute.Key]bool{},
+		deny:  map[attribute.Key]RedactionAction{},
+		key:   key,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(r.key) == 0 {
+		r.key = []byte(os.Getenv(RedactionKeyEnv))
+	}
+	if len(r.key) == 0 {
+		r.key = make([]byte, 32)
+		_, _ = rand.Read(r.key)
+	}
+	return r
+}
+
+// Replaces the rules of r with those of opts, e.g. when reloading them from
+// a file, keeping the key of r unless opts set another one. Processors using
+// r apply the new rules right away.
+func (r *Redactor) Update(opts ...RedactionOption) {
+	r.updated.Store(newRedactor(r.key, opts))
+}
+
+// Returns the redactor holding the current rules.
+func (r *Redactor) rules() *Redactor {
+	if u := r.updated.Load(); u != nil {
+		return u
+	}
+	return r
+}
+
+// Returns a scrubbed copy of attrs, or attrs itself when nothing matched.
+func (r *Redactor) RedactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	r = r.rules()
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		redacted, keep := r.redact(kv)
+		if out == nil {
+			if keep && redacted == kv {
+				continue
+			}
+			out = make([]attribute.KeyValue, i, len(attrs))
+			copy(out, attrs[:i])
+		}
+		if keep {
+			out = append(out, redacted)
+		}
+	}
+	if out == nil {
+		return attrs
+	}
+	return out
+}
+
+// Applies the value rules to s, reporting whether it must be dropped.
+func (r *Redactor) RedactString(s string) (string, bool) {
+	r = r.rules()
+	for _, rule := range r.values {
+		matches := rule.pattern.FindAllString(s, -1)
+		if rule.check != nil {
+			matches = slices.DeleteFunc(matches, func(m string) bool { return !rule.check(m) })
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		if rule.action == RedactDrop {
+			return "", false
+		}
+		s = rule.pattern.ReplaceAllStringFunc(s, func(m string) string {
+			if rule.check != nil && !rule.check(m) {
+				return m
+			}
+			return r.hash(m)
+		})
+	}
+	return s, true
+}
+
+func (r *Redactor) redact(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	r = r.rules()
+	if r.allow[kv.Key] {
+		return kv, true
+	}
+	if action, ok := r.deny[kv.Key]; ok {
+		if action == RedactDrop {
+			return kv, false
+		}
+		return kv.Key.String(r.hash(kv.Value.Emit())), true
+	}
+	if kv.Value.Type() != attribute.STRING || len(r.values) == 0 {
+		return kv, true
+	}
+	s, keep := r.RedactString(kv.Value.AsString())
+	if !keep {
+		return kv, false
+	}
+	if s == kv.Value.AsString() {
+		return kv, true
+	}
+	return kv.Key.String(s), true
+}
+
+// Returns a scrubbed copy of the log attribute kv, the key rules applying to
+// the keys of nested maps too, and whether it must be kept.
+func (r *Redactor) RedactLogAttribute(kv otellog.KeyValue) (otellog.KeyValue, bool) {
+	r = r.rules()
+	key := attribute.Key(kv.Key)
+	if r.allow[key] {
+		return kv, true
+	}
+	if action, ok := r.deny[key]; ok {
+		if action == RedactDrop {
+			return kv, false
+		}
+		return otellog.String(kv.Key, r.hash(kv.Value.String())), true
+	}
+	v, keep := r.RedactLogValue(kv.Value)
+	return otellog.KeyValue{Key: kv.Key, Value: v}, keep
+}
+
+// Applies the value rules to the strings of v, and the key rules to the
+// entries of its maps, reporting whether it must be dropped.
+func (r *Redactor) RedactLogValue(v otellog.Value) (otellog.Value, bool) {
+	r = r.rules()
+	switch v.Kind() {
+	case otellog.KindString:
+		if len(r.values) == 0 {
+			return v, true
+		}
+		s, keep := r.RedactString(v.AsString())
+		if !keep || s == v.AsString() {
+			return v, keep
+		}
+		return otellog.StringValue(s), true
+	case otellog.KindMap:
+		entries := v.AsMap()
+		out := make([]otellog.KeyValue, 0, len(entries))
+		for _, kv := range entries {
+			if redacted, keep := r.RedactLogAttribute(kv); keep {
+				out = append(out, redacted)
+			}
+		}
+		return otellog.MapValue(out...), true
+	case otellog.KindSlice:
+		items := v.AsSlice()
+		out := make([]otellog.Value, 0, len(items))
+		for _, item := range items {
+			if redacted, keep := r.RedactLogValue(item); keep {
+				out = append(out, redacted)
+			}
+		}
+		return otellog.SliceValue(out...), true
+	}
+	return v, true
+}
+
+// Returns the truncated HMAC-SHA256 digest of s under the key of r.
+func (r *Redactor) hash(s string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(s))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Reports whether the digits of s, separators aside, pass the Luhn checksum
+// of card numbers.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// Span processor handing a scrubbed copy of every finished span to the
+// wrapped processor.
+type redactingProcessor struct {
+	sdktrace.SpanProcessor
+	redactor *Redactor
+}
+
+// Wraps next so span, event and link attributes are redacted before export.
+func NewRedactingSpanProcessor(redactor *Redactor, next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return &redactingProcessor{SpanProcessor: next, redactor: redactor}
+}
+
+// Hands the wrapped processor a view of the running span whose attributes
+// read redacted, as zPages lists the active spans.
+func (p *redactingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.SpanProcessor.OnStart(parent, redactedSpan{ReadWriteSpan: s, redactor: p.redactor})
+}
+
+func (p *redactingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	snap := snapshotOf(s)
+	snap.attributes = p.redactor.RedactAttributes(snap.attributes)
+	snap.events = p.redactor.redactEvents(snap.events)
+	snap.links = p.redactor.redactLinks(snap.links)
+	p.SpanProcessor.OnEnd(snap)
+}
+
+// Running span whose attributes, events and links read redacted.
+type redactedSpan struct {
+	sdktrace.ReadWriteSpan
+	redactor *Redactor
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return s.redactor.RedactAttributes(s.ReadWriteSpan.Attributes())
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	return s.redactor.redactEvents(s.ReadWriteSpan.Events())
+}
+
+func (s redactedSpan) Links() []sdktrace.Link {
+	return s.redactor.redactLinks(s.ReadWriteSpan.Links())
+}
+
+// Returns scrubbed copies of events, which may be shared with other
+// processors.
+func (r *Redactor) redactEvents(events []sdktrace.Event) []sdktrace.Event {
+	events = slices.Clone(events)
+	for i := range events {
+		events[i].Attributes = r.RedactAttributes(events[i].Attributes)
+	}
+	return events
+}
+
+// Returns scrubbed copies of links, which may be shared with other
+// processors.
+func (r *Redactor) redactLinks(links []sdktrace.Link) []sdktrace.Link {
+	links = slices.Clone(links)
+	for i := range links {
+		links[i].Attributes = r.RedactAttributes(links[i].Attributes)
+	}
+	return links
+}
+
+// Log processor scrubbing the body and attributes of every record before
+// handing it to the wrapped processor.
+type redactingLogProcessor struct {
+	sdklog.Processor
+	redactor *Redactor
+}
+
+// Wraps next so log record bodies and attributes are redacted with the rules
+// applied to spans before export. A body matching a dropping rule is emptied.
+func NewRedactingLogProcessor(redactor *Redactor, next sdklog.Processor) sdklog.Processor {
+	return &redactingLogProcessor{Processor: next, redactor: redactor}
+}
+
+func (p *redactingLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if body, keep := p.redactor.RedactLogValue(r.Body()); !keep {
+		r.SetBody(otellog.Value{})
+	} else {
+		r.SetBody(body)
+	}
+
+	attrs := make([]otellog.KeyValue, 0, r.AttributesLen())
+	changed := false
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		redacted, keep := p.redactor.RedactLogAttribute(kv)
+		if keep {
+			attrs = append(attrs, redacted)
+		}
+		changed = changed || !keep || !redacted.Equal(kv)
+		return true
+	})
+	if changed {
+		r.SetAttributes(attrs...)
+	}
+	return p.Processor.OnEmit(ctx, r)
+}
@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// zPages and the span tail are served over HTTP, so they must only see the
+// redacted attributes, for running spans as well as finished ones.
+func TestDebugViewsRedactSpans(t *testing.T) {
+	const email = "jane@example.com"
+	zp := NewZPages()
+	tail := NewSpanTail(8)
+	cfg := NewConfig("redact-test",
+		WithRedaction(NewRedactor(DenyKeys(RedactDrop, "user.email"))),
+		WithZPages(zp),
+		WithSpanTail(tail),
+	)
+	exp := tracetest.NewInMemoryExporter()
+	tp := newTracerProvider(cfg, newResource(context.Background(), cfg), exp)
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	// ending when it started keeps the span in the first latency bucket
+	start := time.Now()
+	_, span := tp.Tracer("redact-test").Start(context.Background(), "GET /users/{id}", trace.WithTimestamp(start))
+	span.SetAttributes(attribute.String("user.email", email), attribute.String("user.plan", "pro"))
+	span.AddEvent("lookup", trace.WithAttributes(attribute.String("user.email", email)))
+
+	// ztype 0 lists the running spans, 1 the finished ones by latency
+	tracez := func(query string) string {
+		rec := httptest.NewRecorder()
+		zp.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/tracez?zspanname=GET+%2Fusers%2F%7Bid%7D&"+query, nil))
+		return rec.Body.String()
+	}
+	running := tracez("ztype=0")
+	if !strings.Contains(running, "user.plan") {
+		t.Fatalf("running span not listed by zPages:\n%s", running)
+	}
+	if strings.Contains(running, email) {
+		t.Errorf("zPages lists the running span unredacted")
+	}
+
+	span.End(trace.WithTimestamp(start))
+	finished := tracez("ztype=1&zlatencybucket=0")
+	if !strings.Contains(finished, "user.plan") {
+		t.Fatalf("finished span not listed by zPages:\n%s", finished)
+	}
+	if strings.Contains(finished, email) {
+		t.Errorf("zPages lists the finished span unredacted")
+	}
+	for _, s := range tail.ring {
+		if _, ok := s.Attributes["user.email"]; ok {
+			t.Errorf("span tail keeps user.email")
+		}
+	}
+}
+
+func TestRedactorHashesWithKey(t *testing.T) {
+	const email = "jane@example.com"
+	digest := func(r *Redactor) string {
+		s, _ := r.RedactString("contact " + email)
+		return s
+	}
+	a := NewRedactor(HashKey([]byte("key-a")), RedactValues(EmailPattern, RedactHash))
+	if got := digest(a); strings.Contains(got, email) || got != digest(NewRedactor(HashKey([]byte("key-a")), RedactValues(EmailPattern, RedactHash))) {
+		t.Errorf("digest %q not stable under the same key", got)
+	}
+	if digest(a) == digest(NewRedactor(HashKey([]byte("key-b")), RedactValues(EmailPattern, RedactHash))) {
+		t.Errorf("digests under different keys match")
+	}
+
+	t.Setenv(RedactionKeyEnv, "key-a")
+	fromEnv := NewRedactor(RedactValues(EmailPattern, RedactHash))
+	if digest(fromEnv) != digest(a) {
+		t.Errorf("digest keyed with %s differs from the HashKey one", RedactionKeyEnv)
+	}
+	// reloaded rules keep the key
+	a.Update(DenyKeys(RedactHash, "user.email"))
+	if got, _ := a.redact(attribute.String("user.email", email)); got.Value.AsString() != strings.TrimPrefix(digest(fromEnv), "contact ") {
+		t.Errorf("digest after Update = %q, want the one of the original key", got.Value.AsString())
+	}
+}
+
+func TestRedactorCardNumbersPassLuhn(t *testing.T) {
+	r := NewRedactor(HashKey([]byte("key")), RedactValues(CardNumberPattern, RedactHash))
+	for _, tt := range []struct {
+		s        string
+		redacted bool
+	}{
+		{"paid with 4111 1111 1111 1111", true},
+		{"paid with 5500-0000-0000-0004", true},
+		{"order 4111111111111112", false},
+		{"at 1700000000123456789", false},
+	} {
+		got, keep := r.RedactString(tt.s)
+		if !keep {
+			t.Fatalf("%q dropped", tt.s)
+		}
+		if (got != tt.s) != tt.redacted {
+			t.Errorf("RedactString(%q) = %q, redacted %t, want %t", tt.s, got, got != tt.s, tt.redacted)
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// event reported after every reload changing the settings
+	configReloadedEvent = "telemetry.config.reloaded"
+	// attributes of configReloadedEvent
+	ConfigFileKey    = attribute.Key("telemetry.config.file")
+	ConfigChangesKey = attribute.Key("telemetry.config.changes")
+)
+
+const (
+	// writes are coalesced for this long before the file is reloaded
+	reloadDebounce = 100 * time.Millisecond
+	// upper bound of the flush preceding an exporter endpoint change
+	reloadFlushTimeout = 5 * time.Second
+	// scheme of the collector target when the endpoint may change
+	reloadableScheme = "otel-collector"
+)
+
+// Settings of the config file read by WithConfigFile, e.g. telemetry.yaml.
+// Absent sections keep what the code configured.
+type FileConfig struct {
+	Sampler *FileSamplerConfig `yaml:"sampler"`
+	// minimum severity of exported log records: debug, info, warn, error or
+	// fatal
+	LogLevel  string               `yaml:"log_level"`
+	Redaction *FileRedactionConfig `yaml:"redaction"`
+	Views     *FileViewsConfig     `yaml:"metric_views"`
+	Exporter  *FileExporterConfig  `yaml:"exporter"`
+}
+
+type FileSamplerConfig struct {
+	// initial, always_on, always_off or ratio
+	Mode SamplingMode `yaml:"mode"`
+	// ratio of sampled root traces, setting the mode to ratio when it is
+	// not given
+	Ratio *float64 `yaml:"ratio"`
+}
+
+// Attribute keys redacted on top of the rules set by WithRedaction.
+type FileRedactionConfig struct {
+	Drop  []string `yaml:"drop"`
+	Hash  []string `yaml:"hash"`
+	Allow []string `yaml:"allow"`
+}
+
+// Metric views, instrument names taking * and ? wildcards. Renames and
+// boundaries shape the streams the SDK creates, changing them needs a
+// restart.
+type FileViewsConfig struct {
+	DropAttributes      map[string][]string  `yaml:"drop_attributes"`
+	Rename              map[string]string    `yaml:"rename"`
+	HistogramBoundaries map[string][]float64 `yaml:"histogram_boundaries"`
+}
+
+type FileExporterConfig struct {
+	// collector endpoint, overriding OTEL_EXPORTER_OTLP_ENDPOINT
+	Endpoint string `yaml:"endpoint"`
+}
+
+// Loads the telemetry settings from the YAML file at path when InitProvider
+// runs, then again on SIGHUP and whenever the file changes. Sampler, log
+// level, redaction rules and dropped metric attributes apply right away; a
+// new exporter endpoint is switched to once everything buffered was flushed
+// to the previous one. Every reload changing settings is reported as a
+// telemetry.config.reloaded event, and an invalid file is logged and
+// ignored until fixed. Spans are sampled through the Controller, one being
+// created when none is given.
+func WithConfigFile(path string) Option {
+	return func(c *Config) {
+		c.ConfigFile = path
+	}
+}
+
+// Reads and validates the config file at path.
+func LoadConfigFile(path string) (FileConfig, error) {
+	var fc FileConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fc, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// an empty file keeps every setting of the code
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return fc, fmt.Errorf("%s: %w", path, err)
+	}
+	if issues := fc.issues(); len(issues) > 0 {
+		return fc, fmt.Errorf("%s: %w", path, &ValidationError{Fields: issues})
+	}
+	return fc, nil
+}
+
+func (fc FileConfig) issues() []FieldError {
+	var issues []FieldError
+	if s := fc.Sampler; s != nil {
+		switch s.Mode {
+		case "", SamplingInitial, SamplingAlwaysOn, SamplingAlwaysOff, SamplingRatio:
+		default:
+			issues = append(issues, FieldError{Field: "sampler.mode", Value: string(s.Mode), Allowed: "initial, always_on, always_off or ratio"})
+		}
+		if s.Ratio != nil && (*s.Ratio < 0 || *s.Ratio > 1) {
+			issues = append(issues, FieldError{Field: "sampler.ratio", Value: strconv.FormatFloat(*s.Ratio, 'g', -1, 64), Allowed: "a ratio within [0, 1]"})
+		}
+	}
+	if _, ok := logLevels[strings.ToUpper(fc.LogLevel)]; fc.LogLevel != "" && !ok {
+		issues = append(issues, FieldError{Field: "log_level", Value: fc.LogLevel, Allowed: "debug, info, warn, error or fatal"})
+	}
+	if v := fc.Views; v != nil {
+		for _, name := range sortedKeys(v.HistogramBoundaries) {
+			agg := sdkmetric.AggregationExplicitBucketHistogram{Boundaries: v.HistogramBoundaries[name]}
+			if reason := aggregationIssue(agg); reason != "" {
+				issues = append(issues, FieldError{Field: "metric_views.histogram_boundaries", Value: name, Reason: reason})
+			}
+		}
+		for _, name := range sortedKeys(v.Rename) {
+			if strings.ContainsAny(name, "*?") {
+				issues = append(issues, FieldError{Field: "metric_views.rename", Value: name, Allowed: "an instrument name without wildcards"})
+			}
+		}
+	}
+	if e := fc.Exporter; e != nil && e.Endpoint != "" {
+		issues = append(issues, endpointIssues("exporter.endpoint", e.Endpoint)...)
+	}
+	return issues
+}
+
+// Views of the file, prepended to those of the code at startup.
+func (fc FileConfig) views() []sdkmetric.View {
+	if fc.Views == nil {
+		return nil
+	}
+	var views []sdkmetric.View
+	for _, from := range sortedKeys(fc.Views.Rename) {
+		views = append(views, RenameInstrument(from, fc.Views.Rename[from]))
+	}
+	for _, name := range sortedKeys(fc.Views.HistogramBoundaries) {
+		views = append(views, HistogramBoundaries(name, fc.Views.HistogramBoundaries[name]...))
+	}
+	return views
+}
+
+func (fc FileConfig) endpoint() string {
+	if fc.Exporter == nil {
+		return ""
+	}
+	return fc.Exporter.Endpoint
+}
+
+// Settings of a config file applied while running, and the watch reloading
+// them.
+type configReloader struct {
+	path        string
+	controller  *Controller
+	redactor    *Redactor
+	minSeverity atomic.Int32
+	drops       *attributeDrops
+	// collector target when the endpoint may change
+	resolver *manual.Resolver
+	flush    func(context.Context) error
+
+	mu      sync.Mutex // serializes reloads
+	current FileConfig
+	stop    chan struct{}
+	done    sync.WaitGroup
+}
+
+// Loads the config file of cfg, applying what only takes effect at startup
+// to cfg.
+func newConfigReloader(cfg *Config) (*configReloader, error) {
+	fc, err := LoadConfigFile(cfg.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Controller == nil {
+		cfg.Controller = NewController(nil)
+	}
+	cfg.MetricViews = append(fc.views(), cfg.MetricViews...)
+	r := &configReloader{
+		path:       cfg.ConfigFile,
+		controller: cfg.Controller,
+		redactor:   NewRedactor(),
+		drops:      &attributeDrops{},
+		resolver:   manual.NewBuilderWithScheme(reloadableScheme),
+		current:    fc,
+		stop:       make(chan struct{}),
+	}
+	DefineEvent(configReloadedEvent, EventConfig{
+		Description: "Telemetry config file reloaded with changed settings.",
+		Counter:     "telemetry.config.reloads",
+	})
+	r.applyFilters(fc)
+	return r, nil
+}
+
+// Returns the target dialing addr through the resolver of r, which later
+// points it to the endpoints of the file.
+func (r *configReloader) target(addr string) string {
+	r.resolver.InitialState(collectorState(addr))
+	return reloadableScheme + ":///collector"
+}
+
+func collectorState(addr string) resolver.State {
+	addr = strings.TrimPrefix(addr, "dns:///")
+	serverName := "localhost"
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		serverName = host
+	}
+	return resolver.State{Addresses: []resolver.Address{{Addr: addr, ServerName: serverName}}}
+}
+
+// Dials the collector addresses of the resolver, unix:// ones included.
+func dialCollectorAddress(ctx context.Context, addr string) (net.Conn, error) {
+	var d net.Dialer
+	if path, ok := unixSocketPath(addr); ok {
+		return d.DialContext(ctx, "unix", path)
+	}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// Applies the settings of the file needing the providers, then watches it
+// until Close.
+func (r *configReloader) start(flush func(context.Context) error) {
+	r.flush = flush
+	r.applySampler(r.current)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		// editors replace files, the directory is watched instead
+		err = watcher.Add(filepath.Dir(r.path))
+	}
+	if err != nil {
+		log.Printf("telemetry: not watching %s, reloading it on SIGHUP only: %v", r.path, err)
+		watcher = nil
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+		defer signal.Stop(hup)
+		var events chan fsnotify.Event
+		var errs chan error
+		if watcher != nil {
+			defer watcher.Close()
+			events, errs = watcher.Events, watcher.Errors
+		}
+		debounce := time.NewTimer(time.Hour)
+		debounce.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-hup:
+				r.reload()
+			case ev := <-events:
+				if filepath.Clean(ev.Name) == filepath.Clean(r.path) && !ev.Has(fsnotify.Chmod) {
+					debounce.Reset(reloadDebounce)
+				}
+			case err := <-errs:
+				log.Printf("telemetry: watching %s: %v", r.path, err)
+			case <-debounce.C:
+				r.reload()
+			}
+		}
+	}()
+}
+
+// Stops watching the file.
+func (r *configReloader) Close() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	r.done.Wait()
+}
+
+// Reloads the file, keeping the current settings when it is invalid.
+func (r *configReloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next, err := LoadConfigFile(r.path)
+	if err != nil {
+		log.Printf("telemetry: keeping the current config, reloading failed: %v", err)
+		return
+	}
+	prev := r.current
+	var changes []string
+	if !reflect.DeepEqual(prev.Sampler, next.Sampler) {
+		r.applySampler(next)
+		changes = append(changes, "sampler")
+	}
+	r.applyFilters(next)
+	if prev.LogLevel != next.LogLevel {
+		changes = append(changes, "log_level")
+	}
+	if !reflect.DeepEqual(prev.Redaction, next.Redaction) {
+		changes = append(changes, "redaction")
+	}
+	if !reflect.DeepEqual(viewDrops(prev), viewDrops(next)) {
+		changes = append(changes, "metric_views.drop_attributes")
+	}
+	if !reflect.DeepEqual(startupViews(prev), startupViews(next)) {
+		log.Printf("telemetry: metric view renames and boundaries of %s apply after a restart", r.path)
+	}
+	if prev.endpoint() != next.endpoint() && next.endpoint() != "" {
+		r.swapEndpoint(next.endpoint())
+		changes = append(changes, "exporter.endpoint")
+	}
+	r.current = next
+	if len(changes) > 0 {
+		Event(context.Background(), configReloadedEvent, ConfigFileKey.String(r.path), ConfigChangesKey.StringSlice(changes))
+	}
+}
+
+// Views of the file only applied at startup.
+func startupViews(fc FileConfig) FileViewsConfig {
+	if fc.Views == nil {
+		return FileViewsConfig{}
+	}
+	return FileViewsConfig{Rename: fc.Views.Rename, HistogramBoundaries: fc.Views.HistogramBoundaries}
+}
+
+func viewDrops(fc FileConfig) map[string][]string {
+	if fc.Views == nil {
+		return nil
+	}
+	return fc.Views.DropAttributes
+}
+
+// Applies the sampler section, reverting to the code sampler without one.
+func (r *configReloader) applySampler(fc FileConfig) {
+	if r.controller.sampler == nil {
+		return
+	}
+	s := fc.Sampler
+	if s == nil {
+		s = &FileSamplerConfig{Mode: SamplingInitial}
+	}
+	if s.Ratio != nil {
+		if err := r.controller.SetSamplingRatio(*s.Ratio); err != nil {
+			log.Printf("telemetry: %v", err)
+		}
+	}
+	if s.Mode != "" {
+		if err := r.controller.sampler.SetMode(s.Mode); err != nil {
+			log.Printf("telemetry: %v", err)
+		}
+	}
+}
+
+// Applies the log level, redaction rules and dropped metric attributes.
+func (r *configReloader) applyFilters(fc FileConfig) {
+	r.minSeverity.Store(int32(logLevels[strings.ToUpper(fc.LogLevel)]))
+	var opts []RedactionOption
+	if red := fc.Redaction; red != nil {
+		opts = append(opts, DenyKeys(RedactDrop, red.Drop...), DenyKeys(RedactHash, red.Hash...), AllowKeys(red.Allow...))
+	}
+	r.redactor.Update(opts...)
+	r.drops.set(viewDrops(fc))
+}
+
+// Returns the dropped metric attributes of the file, nil without one.
+func (r *configReloader) attributeDrops() *attributeDrops {
+	if r == nil {
+		return nil
+	}
+	return r.drops
+}
+
+// Points the collector connection to endpoint once what the providers
+// buffer was exported to the previous one.
+func (r *configReloader) swapEndpoint(endpoint string) {
+	ctx, cancel := context.WithTimeout(context.Background(), reloadFlushTimeout)
+	defer cancel()
+	if r.flush != nil {
+		if err := r.flush(ctx); err != nil {
+			log.Printf("telemetry: flushing before switching to %s: %v", endpoint, err)
+		}
+	}
+	checkUnixEndpoint(endpoint)
+	r.resolver.UpdateState(collectorState(endpoint))
+	status.setCollector(endpoint)
+	log.Printf("telemetry: exporting to %s", endpoint)
+}
+
+// Log processor dropping the records below the minimum severity of the
+// config file.
+type severityFilterProcessor struct {
+	sdklog.Processor
+	min *atomic.Int32
+}
+
+func (p *severityFilterProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if sev := r.Severity(); sev != otellog.SeverityUndefined && int32(sev) < p.min.Load() {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, r)
+}
+
+// Attribute keys dropped from the streams of the instruments matching each
+// name, read by the attribute filters of every stream.
+type attributeDrops struct {
+	current atomic.Pointer[map[string][]string]
+}
+
+func (d *attributeDrops) set(drops map[string][]string) {
+	d.current.Store(&drops)
+}
+
+// Keys dropped from the streams of the instrument called name.
+func (d *attributeDrops) keys(name string) map[attribute.Key]bool {
+	drops := d.current.Load()
+	if drops == nil {
+		return nil
+	}
+	keys := map[attribute.Key]bool{}
+	for pattern, ks := range *drops {
+		if ok, _ := path.Match(pattern, name); ok {
+			for _, k := range ks {
+				keys[attribute.Key(k)] = true
+			}
+		}
+	}
+	return keys
+}
+
+type cachedDrops struct {
+	drops *map[string][]string
+	keys  map[attribute.Key]bool
+}
+
+// Wraps filter so it also drops the keys currently listed for the
+// instrument called name, matched again only after a reload.
+func (d *attributeDrops) filter(name string, filter attribute.Filter) attribute.Filter {
+	var cache atomic.Pointer[cachedDrops]
+	return func(kv attribute.KeyValue) bool {
+		drops := d.current.Load()
+		c := cache.Load()
+		if c == nil || c.drops != drops {
+			c = &cachedDrops{drops: drops, keys: d.keys(name)}
+			cache.Store(c)
+		}
+		if c.keys[kv.Key] {
+			return false
+		}
+		return filter == nil || filter(kv)
+	}
+}
@@ -0,0 +1,103 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/samplers/jaegerremote"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Settings of the sampler fetching its strategies from Jaeger or the
+// collector's jaegerremotesampling extension.
+type JaegerRemoteConfig struct {
+	// URL of the sampling endpoint, e.g. http://localhost:5778/sampling
+	Endpoint string
+	// interval between two strategy fetches, 1 minute when zero
+	PollingInterval time.Duration
+	// sampler used until the first strategy is fetched, GetSampler() when
+	// nil
+	Fallback sdktrace.Sampler
+}
+
+// Samples according to the strategies managed centrally in Jaeger, polled
+// from endpoint every pollingInterval. fallback decides until the first
+// strategy is fetched, GetSampler() when nil.
+func WithJaegerRemoteSampling(endpoint string, pollingInterval time.Duration, fallback sdktrace.Sampler) Option {
+	return func(c *Config) {
+		c.Sampler = NewJaegerRemoteSampler(c.ServiceName, JaegerRemoteConfig{
+			Endpoint:        endpoint,
+			PollingInterval: pollingInterval,
+			Fallback:        fallback,
+		})
+	}
+}
+
+// Creates a sampler polling the strategies of the service from Jaeger.
+func NewJaegerRemoteSampler(serviceName string, c JaegerRemoteConfig) sdktrace.Sampler {
+	fallback := c.Fallback
+	if fallback == nil {
+		fallback = GetSampler()
+	}
+	interval := c.PollingInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return jaegerremote.New(serviceName,
+		jaegerremote.WithSamplingServerURL(c.Endpoint),
+		jaegerremote.WithSamplingRefreshInterval(interval),
+		jaegerremote.WithInitialSampler(fallback),
+	)
+}
+
+// Reads the remote sampler settings when OTEL_TRACES_SAMPLER is
+// jaeger_remote, from OTEL_TRACES_SAMPLER_ARG, e.g.
+// endpoint=http://localhost:5778/sampling,pollingIntervalMs=5000,initialSamplingRate=0.25.
+// Invalid arguments are reported and ignored.
+func JaegerRemoteFromEnv() (JaegerRemoteConfig, bool) {
+	if os.Getenv("OTEL_TRACES_SAMPLER") != "jaeger_remote" {
+		return JaegerRemoteConfig{}, false
+	}
+	c, issues := parseJaegerRemoteArgs(os.Getenv("OTEL_TRACES_SAMPLER_ARG"))
+	for _, issue := range issues {
+		otel.Handle(fmt.Errorf("ignoring %w", issue))
+	}
+	return c, true
+}
+
+// Parses the jaeger_remote arguments of OTEL_TRACES_SAMPLER_ARG, returning
+// the invalid ones apart.
+func parseJaegerRemoteArgs(s string) (JaegerRemoteConfig, []FieldError) {
+	c := JaegerRemoteConfig{Endpoint: "http://localhost:5778/sampling"}
+	var issues []FieldError
+	for _, arg := range strings.Split(s, ",") {
+		arg = strings.TrimSpace(arg)
+		key, value, _ := strings.Cut(arg, "=")
+		switch key {
+		case "":
+		case "endpoint":
+			c.Endpoint = value
+		case "pollingIntervalMs":
+			ms, err := strconv.Atoi(value)
+			if err != nil || ms <= 0 {
+				issues = append(issues, FieldError{Field: "OTEL_TRACES_SAMPLER_ARG", Value: arg, Allowed: "pollingIntervalMs as a positive number of milliseconds"})
+				continue
+			}
+			c.PollingInterval = time.Duration(ms) * time.Millisecond
+		case "initialSamplingRate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				issues = append(issues, FieldError{Field: "OTEL_TRACES_SAMPLER_ARG", Value: arg, Allowed: "initialSamplingRate within [0, 1]"})
+				continue
+			}
+			c.Fallback = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(rate))
+		default:
+			issues = append(issues, FieldError{Field: "OTEL_TRACES_SAMPLER_ARG", Value: arg, Allowed: "endpoint, pollingIntervalMs or initialSamplingRate"})
+		}
+	}
+	return c, issues
+}
@@ -0,0 +1,289 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Settings of the Prometheus remote-write metric exporter.
+type RemoteWriteConfig struct {
+	// URL of the remote-write receiver, e.g. http://prometheus:9090/api/v1/write
+	Endpoint string
+	// headers sent with every write, e.g. an authorization header
+	Headers map[string]string
+	// credentials of basic authentication, unused when empty
+	Username, Password string
+	// client sending the writes, http.DefaultClient when nil
+	Client *http.Client
+}
+
+type RemoteWriteOption func(*RemoteWriteConfig)
+
+// Authenticates the writes with basic authentication.
+func WithRemoteWriteBasicAuth(username, password string) RemoteWriteOption {
+	return func(c *RemoteWriteConfig) {
+		c.Username, c.Password = username, password
+	}
+}
+
+// Sends the given headers with every write, e.g. a bearer token or a tenant
+// header such as X-Scope-OrgID.
+func WithRemoteWriteHeaders(headers map[string]string) RemoteWriteOption {
+	return func(c *RemoteWriteConfig) {
+		if c.Headers == nil {
+			c.Headers = map[string]string{}
+		}
+		for k, v := range headers {
+			c.Headers[k] = v
+		}
+	}
+}
+
+// Pushes metrics to endpoint through the Prometheus remote-write protocol
+// instead of OTLP, for environments where nothing scrapes the service.
+// Setting OTEL_METRICS_EXPORTER=prometheusremotewrite with
+// PROMETHEUS_REMOTE_WRITE_URL does the same.
+func WithRemoteWrite(endpoint string, opts ...RemoteWriteOption) Option {
+	return func(c *Config) {
+		rw := &RemoteWriteConfig{Endpoint: endpoint}
+		for _, opt := range opts {
+			opt(rw)
+		}
+		c.RemoteWrite = rw
+	}
+}
+
+// Reads the remote-write settings selected through OTEL_METRICS_EXPORTER,
+// nil when metrics go through OTLP.
+func remoteWriteFromEnv() *RemoteWriteConfig {
+	if os.Getenv("OTEL_METRICS_EXPORTER") != "prometheusremotewrite" {
+		return nil
+	}
+	return &RemoteWriteConfig{Endpoint: os.Getenv("PROMETHEUS_REMOTE_WRITE_URL")}
+}
+
+// Builds the remote-write metric exporter.
+type remoteWriteAdapter struct {
+	config      RemoteWriteConfig
+	aggregation sdkmetric.AggregationSelector
+}
+
+func (a remoteWriteAdapter) metricExporter(context.Context) (sdkmetric.Exporter, error) {
+	if a.config.Endpoint == "" {
+		return nil, fmt.Errorf("remote-write exporter needs an endpoint")
+	}
+	client := a.config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &remoteWriteExporter{config: a.config, client: client, aggregation: a.aggregation}, nil
+}
+
+// Metric exporter translating OTLP data points into Prometheus time series.
+// Exponential histograms have no remote-write equivalent and are skipped.
+type remoteWriteExporter struct {
+	config      RemoteWriteConfig
+	client      *http.Client
+	aggregation sdkmetric.AggregationSelector
+
+	mu       sync.Mutex
+	shutdown bool
+}
+
+// Prometheus expects cumulative series.
+func (e *remoteWriteExporter) Temporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+func (e *remoteWriteExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return e.aggregation(kind)
+}
+
+func (e *remoteWriteExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	done := e.shutdown
+	e.mu.Unlock()
+	if done {
+		return fmt.Errorf("remote-write exporter is shut down")
+	}
+
+	body := s2.EncodeSnappy(nil, encodeWriteRequest(rm))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+	if e.config.Username != "" {
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote write failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("remote write failed: %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (e *remoteWriteExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *remoteWriteExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+// One sample of a Prometheus time series.
+type promSample struct {
+	labels    [][2]string
+	value     float64
+	timestamp int64
+}
+
+// Encodes the metrics as a remote-write WriteRequest protobuf message.
+func encodeWriteRequest(rm *metricdata.ResourceMetrics) []byte {
+	var base [][2]string
+	if v, ok := rm.Resource.Set().Value(semconv.ServiceNameKey); ok {
+		base = append(base, [2]string{"job", v.AsString()})
+	}
+	if v, ok := rm.Resource.Set().Value(semconv.ServiceInstanceIDKey); ok {
+		base = append(base, [2]string{"instance", v.AsString()})
+	}
+
+	var buf []byte
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, s := range promSamples(m, base) {
+				buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+				buf = protowire.AppendBytes(buf, encodeTimeSeries(s))
+			}
+		}
+	}
+	return buf
+}
+
+func encodeTimeSeries(s promSample) []byte {
+	// labels must be sorted by name
+	sort.Slice(s.labels, func(i, j int) bool { return s.labels[i][0] < s.labels[j][0] })
+	var ts []byte
+	for _, l := range s.labels {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, l[0])
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, l[1])
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+	ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+	return protowire.AppendBytes(ts, sample)
+}
+
+// Translates the data points of m into Prometheus samples, following the
+// Prometheus naming conventions: monotonic sums end in _total, histograms
+// become _bucket, _sum and _count series.
+func promSamples(m metricdata.Metrics, base [][2]string) []promSample {
+	name := promName(m.Name)
+	var samples []promSample
+	add := func(name string, attrs attribute.Set, extra [][2]string, value float64, ts int64) {
+		labels := append(append([][2]string{{"__name__", name}}, base...), extra...)
+		for _, kv := range attrs.ToSlice() {
+			labels = append(labels, [2]string{promName(string(kv.Key)), kv.Value.Emit()})
+		}
+		samples = append(samples, promSample{labels: labels, value: value, timestamp: ts})
+	}
+
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		name := sumName(name, data.IsMonotonic)
+		for _, dp := range data.DataPoints {
+			add(name, dp.Attributes, nil, float64(dp.Value), dp.Time.UnixMilli())
+		}
+	case metricdata.Sum[float64]:
+		name := sumName(name, data.IsMonotonic)
+		for _, dp := range data.DataPoints {
+			add(name, dp.Attributes, nil, dp.Value, dp.Time.UnixMilli())
+		}
+	case metricdata.Gauge[int64]:
+		for _, dp := range data.DataPoints {
+			add(name, dp.Attributes, nil, float64(dp.Value), dp.Time.UnixMilli())
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range data.DataPoints {
+			add(name, dp.Attributes, nil, dp.Value, dp.Time.UnixMilli())
+		}
+	case metricdata.Histogram[int64]:
+		for _, dp := range data.DataPoints {
+			addHistogram(add, name, dp.Attributes, dp.Bounds, dp.BucketCounts, float64(dp.Sum), dp.Count, dp.Time.UnixMilli())
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range data.DataPoints {
+			addHistogram(add, name, dp.Attributes, dp.Bounds, dp.BucketCounts, dp.Sum, dp.Count, dp.Time.UnixMilli())
+		}
+	}
+	return samples
+}
+
+func addHistogram(add func(string, attribute.Set, [][2]string, float64, int64), name string, attrs attribute.Set, bounds []float64, counts []uint64, sum float64, count uint64, ts int64) {
+	// Prometheus buckets are cumulative
+	var cumulative uint64
+	for i, c := range counts {
+		cumulative += c
+		le := "+Inf"
+		if i < len(bounds) {
+			le = strconv.FormatFloat(bounds[i], 'g', -1, 64)
+		}
+		add(name+"_bucket", attrs, [][2]string{{"le", le}}, float64(cumulative), ts)
+	}
+	add(name+"_sum", attrs, nil, sum, ts)
+	add(name+"_count", attrs, nil, float64(count), ts)
+}
+
+func sumName(name string, monotonic bool) string {
+	if monotonic && !strings.HasSuffix(name, "_total") {
+		return name + "_total"
+	}
+	return name
+}
+
+// Replaces the characters Prometheus does not allow in names, e.g.
+// packages.lookup becomes packages_lookup.
+func promName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
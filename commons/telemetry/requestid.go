@@ -0,0 +1,86 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// header carrying the request ID in requests and responses
+	RequestIDHeader = "X-Request-ID"
+	// span attribute and baggage member holding the request ID
+	RequestIDKey = attribute.Key("request.id")
+)
+
+// Request IDs accepted from clients and upstream services, others being
+// replaced as they end up in spans, logs and downstream baggage.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// Returns a copy of ctx carrying the given request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// Returns the request ID stored in ctx, falling back to the one propagated
+// through baggage by an upstream service.
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return baggage.FromContext(ctx).Member(string(RequestIDKey)).Value()
+}
+
+// Sets the request ID header of an outgoing request from its context.
+func SetRequestIDHeader(req *http.Request) {
+	if id := RequestIDFromContext(req.Context()); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+}
+
+// Middleware reusing the inbound X-Request-ID when made of at most 128
+// letters, digits, dots, underscores and dashes, or generating one from the
+// trace ID, then storing it in the context, on the span, in the baggage and
+// in the response headers. It must be installed after the tracing middleware.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = RequestIDFromContext(ctx)
+		}
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID(ctx)
+		}
+
+		ctx = ContextWithRequestID(ctx, id)
+		if m, err := baggage.NewMember(string(RequestIDKey), id); err == nil {
+			if bag, err := baggage.FromContext(ctx).SetMember(m); err == nil {
+				ctx = baggage.ContextWithBaggage(ctx, bag)
+			}
+		}
+		trace.SpanFromContext(ctx).SetAttributes(RequestIDKey.String(id))
+		w.Header().Set(RequestIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Uses the trace ID so request and trace share the same key, or random bytes
+// when there is no active trace.
+func newRequestID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
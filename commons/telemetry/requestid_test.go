@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddlewareRejectsUnsafeIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		inbound string
+		keep    bool
+	}{
+		{"uuid", "3f2c9a1e-7b4d-4c2a-9e1f-0a6b5c4d3e2f", true},
+		{"dotted", "edge.req_42", true},
+		{"max length", strings.Repeat("a", 128), true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", 129), false},
+		{"newline", "abc\ninjected", false},
+		{"baggage delimiters", "abc,evil=1;p", false},
+		{"spaces", "abc def", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tt.inbound)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := seen == tt.inbound; got != tt.keep {
+				t.Errorf("request ID %q kept = %v, want %v", tt.inbound, got, tt.keep)
+			}
+			if !requestIDPattern.MatchString(seen) {
+				t.Errorf("request ID %q does not match %s", seen, requestIDPattern)
+			}
+			if got := rec.Header().Get(RequestIDHeader); got != seen {
+				t.Errorf("response header %q, want %q", got, seen)
+			}
+		})
+	}
+}
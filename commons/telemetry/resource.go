@@ -0,0 +1,219 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Returns a new OpenTelemetry resource describing this application.
+func newResource(ctx context.Context, cfg Config) *resource.Resource {
+	if cfg.CloudDetection {
+		// metadata endpoints must not hold the startup back
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.CloudDetectionTimeout)
+		defer cancel()
+	}
+
+	opts := []resource.Option{
+		resource.WithFromEnv(),
+		resource.WithProcess(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	}
+	opts = append(opts, detectorOptions(cfg)...)
+	attrs := []attribute.KeyValue{
+		// the service name used to display traces in backends
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.DeploymentEnvironment(os.Getenv("GO_ENV")),
+		attribute.String("environment", os.Getenv("GO_ENV")),
+	}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	attrs = append(attrs, cfg.ResourceAttributes...)
+	opts = append(opts, resource.WithAttributes(attrs...))
+
+	res, err := resource.New(ctx, opts...)
+	if err != nil {
+		if res == nil {
+			log.Fatalf("%s: %v", "Failed to create resource", err)
+		}
+		// partial detection failures still yield a usable resource
+		otel.Handle(err)
+	}
+	return res
+}
+
+// Returns the resource detection options enabled by cfg.
+func detectorOptions(cfg Config) []resource.Option {
+	if !cfg.CloudDetection {
+		return nil
+	}
+	return []resource.Option{
+		resource.WithContainer(),
+		resource.WithDetectors(
+			k8sDownwardAPIDetector{},
+			ec2Detector{},
+			gcpDetector{},
+			azureDetector{},
+		),
+	}
+}
+
+// Detects Kubernetes attributes exposed to the container as environment
+// variables through the downward API, e.g.
+//
+//	env:
+//	  - name: K8S_POD_NAME
+//	    valueFrom:
+//	      fieldRef:
+//	        fieldPath: metadata.name
+type k8sDownwardAPIDetector struct{}
+
+func (k8sDownwardAPIDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for env, attr := range map[string]func(string) attribute.KeyValue{
+		"K8S_POD_NAME":       semconv.K8SPodName,
+		"K8S_POD_UID":        semconv.K8SPodUID,
+		"K8S_NAMESPACE_NAME": semconv.K8SNamespaceName,
+		"K8S_NODE_NAME":      semconv.K8SNodeName,
+		"K8S_CONTAINER_NAME": semconv.K8SContainerName,
+	} {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, attr(v))
+		}
+	}
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// Detects AWS EC2 attributes from the instance metadata service (IMDSv2).
+type ec2Detector struct{}
+
+func (ec2Detector) Detect(ctx context.Context) (*resource.Resource, error) {
+	token, err := metadataRequest(ctx, http.MethodPut, "http://169.254.169.254/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return nil, nil
+	}
+	body, err := metadataRequest(ctx, http.MethodGet, "http://169.254.169.254/latest/dynamic/instance-identity/document",
+		map[string]string{"X-aws-ec2-metadata-token": string(token)})
+	if err != nil {
+		return nil, nil
+	}
+	var doc struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		AccountID        string `json:"accountId"`
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		ImageID          string `json:"imageId"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.CloudProviderAWS,
+		semconv.CloudPlatformAWSEC2,
+		semconv.CloudRegion(doc.Region),
+		semconv.CloudAvailabilityZone(doc.AvailabilityZone),
+		semconv.CloudAccountID(doc.AccountID),
+		semconv.HostID(doc.InstanceID),
+		semconv.HostType(doc.InstanceType),
+		semconv.HostImageID(doc.ImageID),
+	), nil
+}
+
+// Detects Google Compute Engine attributes from the metadata server.
+type gcpDetector struct{}
+
+func (gcpDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	get := func(path string) (string, error) {
+		b, err := metadataRequest(ctx, http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/"+path,
+			map[string]string{"Metadata-Flavor": "Google"})
+		return string(b), err
+	}
+	project, err := get("project/project-id")
+	if err != nil {
+		return nil, nil
+	}
+	// zone comes as projects/<number>/zones/<zone>
+	zone, _ := get("instance/zone")
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	id, _ := get("instance/id")
+	return resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.CloudProviderGCP,
+		semconv.CloudPlatformGCPComputeEngine,
+		semconv.CloudAccountID(project),
+		semconv.CloudAvailabilityZone(zone),
+		semconv.CloudRegion(region),
+		semconv.HostID(id),
+	), nil
+}
+
+// Detects Azure VM attributes from the instance metadata service.
+type azureDetector struct{}
+
+func (azureDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	body, err := metadataRequest(ctx, http.MethodGet, "http://169.254.169.254/metadata/instance/compute?api-version=2021-12-13&format=json",
+		map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, nil
+	}
+	var compute struct {
+		Location       string `json:"location"`
+		VMID           string `json:"vmId"`
+		Name           string `json:"name"`
+		VMSize         string `json:"vmSize"`
+		SubscriptionID string `json:"subscriptionId"`
+	}
+	if err := json.Unmarshal(body, &compute); err != nil {
+		return nil, err
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.CloudProviderAzure,
+		semconv.CloudPlatformAzureVM,
+		semconv.CloudRegion(compute.Location),
+		semconv.CloudAccountID(compute.SubscriptionID),
+		semconv.HostID(compute.VMID),
+		semconv.HostName(compute.Name),
+		semconv.HostType(compute.VMSize),
+	), nil
+}
+
+// Queries a link-local metadata endpoint, failing on any non-200 answer.
+func metadataRequest(ctx context.Context, method, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata request to %s failed: %s", url, res.Status)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 1<<16))
+}
@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// app1 and app2 both initialize through InitProvider, so their resources
+// may only differ by service name.
+func TestResourcesConsistentAcrossServices(t *testing.T) {
+	t.Setenv("GO_ENV", "development")
+	ctx := context.Background()
+	server := newResource(ctx, NewConfig("otel-example-server"))
+	client := newResource(ctx, NewConfig("otel-example-client"))
+
+	for _, res := range []struct {
+		name string
+		set  *attribute.Set
+		url  string
+	}{
+		{"otel-example-server", server.Set(), server.SchemaURL()},
+		{"otel-example-client", client.Set(), client.SchemaURL()},
+	} {
+		if res.url != semconv.SchemaURL {
+			t.Errorf("%s: schema URL %q, want %q", res.name, res.url, semconv.SchemaURL)
+		}
+		if v, _ := res.set.Value(semconv.ServiceNameKey); v.AsString() != res.name {
+			t.Errorf("service.name = %q, want %q", v.AsString(), res.name)
+		}
+	}
+
+	withoutName := func(kv attribute.KeyValue) bool { return kv.Key != semconv.ServiceNameKey }
+	serverAttrs, _ := server.Set().Filter(withoutName)
+	clientAttrs, _ := client.Set().Filter(withoutName)
+	if !serverAttrs.Equals(&clientAttrs) {
+		t.Errorf("resources differ beyond service.name:\n server %s\n client %s",
+			serverAttrs.Encoded(attribute.DefaultEncoder()), clientAttrs.Encoded(attribute.DefaultEncoder()))
+	}
+	for _, key := range []attribute.Key{semconv.DeploymentEnvironmentKey, semconv.TelemetrySDKVersionKey, semconv.HostNameKey} {
+		if !serverAttrs.HasValue(key) {
+			t.Errorf("resources lack %s", key)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Longest wait between two attempts, Retry-After included.
+const maxRetryBackoff = 30 * time.Second
+
+// Retries failed idempotent requests up to maxAttempts times in total,
+// waiting an exponentially growing, jittered delay starting at backoff
+// between attempts, or the delay the server asks for with Retry-After.
+// Network errors and 429, 502, 503 and 504 responses are retried. Every
+// attempt gets its own client span, resends carrying
+// http.request.resend_count, and retries are counted in the
+// http.client.retries counter.
+func WithRetry(maxAttempts int, backoff time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.retryAttempts = maxAttempts
+		c.retryBackoff = backoff
+	}
+}
+
+type resendCountKey struct{}
+
+// Round tripper retrying the requests of next, which starts the client spans.
+type retryTransport struct {
+	next        http.RoundTripper
+	maxAttempts int
+	backoff     time.Duration
+	clock       Clock
+	retries     metric.Int64Counter
+}
+
+func newRetryTransport(next http.RoundTripper, maxAttempts int, backoff time.Duration, clock Clock) *retryTransport {
+	return &retryTransport{
+		next:        next,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		clock:       clock,
+		retries: NewCounter(instrumentationName, Descriptor{
+			Name:        "http.client.retries",
+			Unit:        "{retry}",
+			Description: "HTTP client requests sent again, by method and reason.",
+			Attributes:  []string{string(semconv.HTTPRequestMethodKey), "reason"},
+		}),
+	}
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !retryable(r) {
+		return t.next.RoundTrip(r)
+	}
+	ctx := r.Context()
+	for attempt := 0; ; attempt++ {
+		req := r
+		if attempt > 0 {
+			req = r.Clone(context.WithValue(ctx, resendCountKey{}, attempt))
+			if r.GetBody != nil {
+				body, err := r.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+		resp, err := t.next.RoundTrip(req)
+		reason, retry := retryReason(ctx, resp, err)
+		if !retry || attempt+1 >= t.maxAttempts {
+			return resp, err
+		}
+
+		delay := t.delay(attempt, resp)
+		if resp != nil {
+			// frees the connection for the next attempt
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		t.retries.Add(ctx, 1, metric.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			attribute.String("reason", reason),
+		))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.clock.After(delay):
+		}
+	}
+}
+
+// Returns the wait before the attempt following attempt, honoring the
+// Retry-After header of resp.
+func (t *retryTransport) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if after := resp.Header.Get("Retry-After"); after != "" {
+			if seconds, err := strconv.Atoi(after); err == nil {
+				// capped before converting, as large values overflow
+				return time.Duration(min(max(seconds, 0), int(maxRetryBackoff/time.Second))) * time.Second
+			}
+			if at, err := http.ParseTime(after); err == nil {
+				return min(max(at.Sub(t.clock.Now()), 0), maxRetryBackoff)
+			}
+		}
+	}
+	// compared before shifting, as the shift overflows after a few dozen
+	// attempts
+	ceiling := maxRetryBackoff
+	if t.backoff <= maxRetryBackoff>>attempt {
+		ceiling = max(t.backoff<<attempt, 0)
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Reports whether r can be sent again: idempotent methods, or requests
+// carrying an Idempotency-Key, whose body can be rewound.
+func retryable(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Header.Get("Idempotency-Key") != ""
+}
+
+// Returns why the outcome of an attempt calls for another one, if it does.
+func retryReason(ctx context.Context, resp *http.Response, err error) (string, bool) {
+	if err != nil {
+		return "error", ctx.Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return strconv.Itoa(resp.StatusCode), true
+	}
+	return "", false
+}
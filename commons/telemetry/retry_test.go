@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryDelayStaysWithinCap(t *testing.T) {
+	rt := newRetryTransport(http.DefaultTransport, 3, 100*time.Millisecond, SystemClock)
+	for _, tt := range []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{9, maxRetryBackoff},
+		{40, maxRetryBackoff},
+		{63, maxRetryBackoff},
+		{64, maxRetryBackoff},
+		{1000, maxRetryBackoff},
+	} {
+		for range 100 {
+			if d := rt.delay(tt.attempt, nil); d < 0 || d > tt.ceiling {
+				t.Fatalf("delay(%d) = %s, want within [0, %s]", tt.attempt, d, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryDelayHonorsRetryAfter(t *testing.T) {
+	rt := newRetryTransport(http.DefaultTransport, 3, 100*time.Millisecond, SystemClock)
+	for _, tt := range []struct {
+		header string
+		want   time.Duration
+	}{
+		{"2", 2 * time.Second},
+		{"-5", 0},
+		{"600", maxRetryBackoff},
+		{"99999999999999999", maxRetryBackoff},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), maxRetryBackoff},
+	} {
+		resp := &http.Response{Header: http.Header{"Retry-After": {tt.header}}}
+		if got := rt.delay(0, resp); got != tt.want {
+			t.Errorf("delay with Retry-After %q = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
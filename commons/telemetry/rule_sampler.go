@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Sampling ratio applied to the routes matching a glob pattern, such as
+// {Route: "/healthz", Ratio: 0} or {Route: "/packages/*", Ratio: 0.5}. As
+// with path.Match, * stays within one path segment, while a trailing /**
+// matches the routes below a prefix at any depth, e.g. "/debug/**" matches
+// /debug/pprof/profile.
+type SamplingRule struct {
+	Route string
+	Ratio float64
+}
+
+type ruleSampler struct {
+	rules    []SamplingRule
+	samplers []sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+// Creates a sampler choosing the sampling ratio of entry spans from the first
+// rule whose pattern matches the http.route attribute or the span name.
+// Spans matching no rule use fallback, and local child spans follow their
+// parent decision.
+func NewRuleSampler(fallback sdktrace.Sampler, rules ...SamplingRule) sdktrace.Sampler {
+	s := &ruleSampler{rules: rules, fallback: fallback}
+	for _, r := range rules {
+		s.samplers = append(s.samplers, ratioSampler(r.Ratio))
+	}
+	return s
+}
+
+// Returns a copy of s using fallback for the spans matching no rule.
+func (s *ruleSampler) withFallback(fallback sdktrace.Sampler) *ruleSampler {
+	return &ruleSampler{rules: s.rules, samplers: s.samplers, fallback: fallback}
+}
+
+func ratioSampler(ratio float64) sdktrace.Sampler {
+	switch {
+	case ratio <= 0:
+		return sdktrace.NeverSample()
+	case ratio >= 1:
+		return sdktrace.AlwaysSample()
+	default:
+		return sdktrace.TraceIDRatioBased(ratio)
+	}
+}
+
+func (s *ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+	if psc.IsValid() && !psc.IsRemote() {
+		decision := sdktrace.Drop
+		if psc.IsSampled() {
+			decision = sdktrace.RecordAndSample
+		}
+		return sdktrace.SamplingResult{Decision: decision, Tracestate: psc.TraceState()}
+	}
+
+	candidates := routeCandidates(p)
+	for i, r := range s.rules {
+		for _, c := range candidates {
+			if matchRoute(r.Route, c) {
+				return s.samplers[i].ShouldSample(p)
+			}
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *ruleSampler) Description() string {
+	rules := make([]string, 0, len(s.rules))
+	for _, r := range s.rules {
+		rules = append(rules, fmt.Sprintf("%s=%g", r.Route, r.Ratio))
+	}
+	return fmt.Sprintf("RuleSampler{rules:[%s],fallback:%s}", strings.Join(rules, ","), s.fallback.Description())
+}
+
+// Reports whether route matches pattern, a path.Match pattern optionally
+// ending in /** to match the prefix itself and every route below it.
+func matchRoute(pattern, route string) bool {
+	prefix, ok := strings.CutSuffix(pattern, "/**")
+	if !ok {
+		matched, _ := path.Match(pattern, route)
+		return matched
+	}
+	// the prefix is matched against as many leading segments of route
+	segments := strings.Count(prefix, "/")
+	head := route
+	if parts := strings.SplitAfterN(route, "/", segments+2); len(parts) > segments+1 {
+		head = strings.TrimSuffix(strings.Join(parts[:segments+1], ""), "/")
+	}
+	matched, _ := path.Match(prefix, head)
+	return matched
+}
+
+// Returns the values rules are matched against: the http.route attribute,
+// the span name, and the span name stripped of a leading HTTP method.
+func routeCandidates(p sdktrace.SamplingParameters) []string {
+	var candidates []string
+	for _, attr := range p.Attributes {
+		if attr.Key == semconv.HTTPRouteKey {
+			candidates = append(candidates, attr.Value.AsString())
+		}
+	}
+	candidates = append(candidates, p.Name)
+	if _, route, ok := strings.Cut(p.Name, " "); ok {
+		candidates = append(candidates, route)
+	}
+	return candidates
+}
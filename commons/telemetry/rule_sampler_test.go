@@ -0,0 +1,28 @@
+package telemetry
+
+import "testing"
+
+func TestMatchRoute(t *testing.T) {
+	tests := []struct {
+		pattern, route string
+		want           bool
+	}{
+		{"/healthz", "/healthz", true},
+		{"/packages/*", "/packages/1", true},
+		{"/debug/*", "/debug/pprof/profile", false},
+		{"/debug/**", "/debug", true},
+		{"/debug/**", "/debug/tracez", true},
+		{"/debug/**", "/debug/pprof/profile", true},
+		{"/debug/**", "/debug/telemetry/sampler", true},
+		{"/debug/**", "/debugger", false},
+		{"/debug/**", "/packages/debug/x", false},
+		{"/admin/otel/**", "/admin/otel/manifest", true},
+		{"/admin/otel/**", "/admin/sweep", false},
+		{"/v*/packages/**", "/v1/packages/1/documents", true},
+	}
+	for _, tt := range tests {
+		if got := matchRoute(tt.pattern, tt.route); got != tt.want {
+			t.Errorf("matchRoute(%q, %q) = %v, want %v", tt.pattern, tt.route, got, tt.want)
+		}
+	}
+}
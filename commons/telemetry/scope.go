@@ -0,0 +1,129 @@
+package telemetry
+
+import (
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option of the instrumentation scope of the tracers and meters returned by
+// Tracer and Meter.
+type ScopeOption func(*scopeConfig)
+
+type scopeConfig struct {
+	schemaURL string
+}
+
+// Sets the schema URL of the scope, the semantic conventions one used by the
+// telemetry package by default.
+func WithScopeSchemaURL(url string) ScopeOption {
+	return func(c *scopeConfig) {
+		c.schemaURL = url
+	}
+}
+
+type scopeKey struct {
+	provider  any
+	name      string
+	version   string
+	schemaURL string
+}
+
+var (
+	tracers sync.Map // scopeKey -> trace.Tracer
+	meters  sync.Map // scopeKey -> metric.Meter
+)
+
+// Returns the tracer of the instrumentation scope name at the given version,
+// from the global provider. Tracers are cached per scope and provider, so
+// hot paths can call it instead of keeping their own tracer, and every span
+// carries the scope name, version and schema URL. An empty version defaults
+// to the one of the module named by the scope in the build info, or to the
+// version of the binary.
+func Tracer(name, version string, opts ...ScopeOption) trace.Tracer {
+	return cachedTracer(otel.GetTracerProvider(), name, version, opts...)
+}
+
+// Returns the meter of the instrumentation scope name at the given version,
+// from the global provider, cached and versioned as Tracer does.
+func Meter(name, version string, opts ...ScopeOption) metric.Meter {
+	provider := otel.GetMeterProvider()
+	key := newScopeKey(provider, name, version, opts)
+	if m, ok := meters.Load(key); ok {
+		return m.(metric.Meter)
+	}
+	m, _ := meters.LoadOrStore(key, provider.Meter(name,
+		metric.WithInstrumentationVersion(key.version),
+		metric.WithSchemaURL(key.schemaURL),
+	))
+	return m.(metric.Meter)
+}
+
+func cachedTracer(provider trace.TracerProvider, name, version string, opts ...ScopeOption) trace.Tracer {
+	key := newScopeKey(provider, name, version, opts)
+	if t, ok := tracers.Load(key); ok {
+		return t.(trace.Tracer)
+	}
+	t, _ := tracers.LoadOrStore(key, provider.Tracer(name,
+		trace.WithInstrumentationVersion(key.version),
+		trace.WithSchemaURL(key.schemaURL),
+	))
+	return t.(trace.Tracer)
+}
+
+func newScopeKey(provider any, name, version string, opts []ScopeOption) scopeKey {
+	cfg := scopeConfig{schemaURL: semconv.SchemaURL}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if version == "" {
+		version = scopeVersion(name)
+	}
+	return scopeKey{provider: provider, name: name, version: version, schemaURL: cfg.schemaURL}
+}
+
+// Modules of the running binary by path, with their version.
+var buildModules = sync.OnceValue(func() map[string]string {
+	modules := map[string]string{}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return modules
+	}
+	for _, m := range append([]*debug.Module{&info.Main}, info.Deps...) {
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		if m.Version != "" && m.Version != "(devel)" {
+			modules[m.Path] = m.Version
+		}
+	}
+	return modules
+})
+
+// Versions of the scopes by name, resolved once as the build info is parsed
+// on every read.
+var scopeVersions sync.Map // string -> string
+
+// Returns the version of the module whose path is the longest prefix of the
+// scope name, the version of the binary when there is none.
+func scopeVersion(name string) string {
+	if v, ok := scopeVersions.Load(name); ok {
+		return v.(string)
+	}
+	var path, version string
+	for p, v := range buildModules() {
+		if (name == p || strings.HasPrefix(name, p+"/")) && len(p) > len(path) {
+			path, version = p, v
+		}
+	}
+	if version == "" {
+		version = BuildVersion()
+	}
+	scopeVersions.Store(name, version)
+	return version
+}
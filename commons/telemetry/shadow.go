@@ -0,0 +1,158 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute of the shadow latency telling the primary request from its
+// mirror.
+const ShadowRoleKey = attribute.Key("http.shadow.role")
+
+// How long a mirrored request may take.
+const shadowTimeout = 10 * time.Second
+
+// Mirrors the given fraction of the requests, in the [0, 1] range, to the
+// shadow base URL, e.g. a new version of the service under test. Mirrors are
+// sent in the background, their responses discarded, so the primary response
+// is never delayed nor changed. Each mirror is traced in its own trace whose
+// root span links to the span sending the primary request, and the latency
+// of both is recorded in the http.client.shadow.duration histogram. Requests
+// with a body which cannot be replayed are not mirrored.
+func WithShadow(target string, ratio float64) ClientOption {
+	return func(c *clientConfig) {
+		u, err := url.Parse(target)
+		if err == nil && u.Host == "" {
+			err = fmt.Errorf("shadow URL %q has no host", target)
+		}
+		if err != nil {
+			otel.Handle(err)
+			return
+		}
+		c.shadowURL, c.shadowRatio = u, ratio
+	}
+}
+
+// Round tripper mirroring requests of next to the shadow transport.
+type shadowTransport struct {
+	next       http.RoundTripper
+	shadow     http.RoundTripper
+	target     *url.URL
+	ratio      float64
+	duration   metric.Float64Histogram
+	mismatches metric.Int64Counter
+}
+
+func newShadowTransport(next, shadow http.RoundTripper, target *url.URL, ratio float64) *shadowTransport {
+	return &shadowTransport{
+		next:   next,
+		shadow: shadow,
+		target: target,
+		ratio:  ratio,
+		duration: NewHistogram(instrumentationName, Descriptor{
+			Name:        "http.client.shadow.duration",
+			Unit:        "s",
+			Description: "Time to the response headers of mirrored requests and of their primary request.",
+			Attributes:  []string{string(ShadowRoleKey), string(semconv.HTTPRequestMethodKey)},
+		}, latencyBoundaries...),
+		mismatches: NewCounter(instrumentationName, Descriptor{
+			Name:        "http.client.shadow.mismatches",
+			Unit:        "{request}",
+			Description: "Mirrored requests whose status code differs from the primary one.",
+			Attributes:  []string{string(semconv.HTTPRequestMethodKey)},
+		}),
+	}
+}
+
+func (t *shadowTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.ratio <= 0 || rand.Float64() >= t.ratio || (r.Body != nil && r.Body != http.NoBody && r.GetBody == nil) {
+		return t.next.RoundTrip(r)
+	}
+	mirror, err := t.mirror(r)
+	if err != nil {
+		otel.Handle(err)
+		return t.next.RoundTrip(r)
+	}
+
+	primary := make(chan int, 1)
+	go t.send(mirror, trace.SpanContextFromContext(r.Context()), primary)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r)
+	t.record(r.Context(), "primary", r.Method, time.Since(start))
+	if err != nil {
+		primary <- 0
+	} else {
+		primary <- resp.StatusCode
+	}
+	return resp, err
+}
+
+// Returns the copy of r sent to the shadow URL, with a context of its own
+// carrying the baggage of r.
+func (t *shadowTransport) mirror(r *http.Request) (*http.Request, error) {
+	ctx := context.WithoutCancel(r.Context())
+	mirror := r.Clone(ctx)
+	mirror.URL.Scheme, mirror.URL.Host, mirror.Host = t.target.Scheme, t.target.Host, ""
+	mirror.URL.Path, mirror.URL.RawPath = strings.TrimSuffix(t.target.Path, "/")+r.URL.Path, ""
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		mirror.Body = body
+	}
+	return mirror, nil
+}
+
+// Sends the mirror under a new root span linked to the span of the primary
+// request, comparing its status with the primary one once known.
+func (t *shadowTransport) send(r *http.Request, primarySpan trace.SpanContext, primary <-chan int) {
+	ctx, cancel := context.WithTimeout(r.Context(), shadowTimeout)
+	defer cancel()
+	ctx, span := Tracer(instrumentationName, "").Start(ctx, r.Method+" shadow",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{SpanContext: primarySpan}),
+		trace.WithAttributes(semconv.URLFull(r.URL.String())),
+	)
+	defer span.End()
+
+	start := time.Now()
+	resp, err := t.shadow.RoundTrip(r.WithContext(ctx))
+	t.record(ctx, "shadow", r.Method, time.Since(start))
+	status := 0
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		status = resp.StatusCode
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	if primaryStatus := <-primary; primaryStatus != status {
+		span.SetAttributes(attribute.Int("http.shadow.primary_status_code", primaryStatus))
+		t.mismatches.Add(ctx, 1, metric.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)))
+	}
+}
+
+func (t *shadowTransport) record(ctx context.Context, role, method string, d time.Duration) {
+	t.duration.Record(ctx, d.Seconds(), metric.WithAttributes(
+		ShadowRoleKey.String(role),
+		semconv.HTTPRequestMethodKey.String(method),
+	))
+}
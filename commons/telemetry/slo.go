@@ -0,0 +1,138 @@
+package telemetry
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// attribute of slo.spans and slo.objective naming the objective
+	SLONameKey = attribute.Key("slo.name")
+	// attribute of slo.spans, SLOResultGood or SLOResultBad
+	SLOResultKey = attribute.Key("slo.result")
+	// attribute of the bad slo.spans telling which threshold they crossed,
+	// SLOViolationError or SLOViolationLatency
+	SLOViolationKey = attribute.Key("slo.violation")
+
+	SLOResultGood       = "good"
+	SLOResultBad        = "bad"
+	SLOViolationError   = "error"
+	SLOViolationLatency = "latency"
+)
+
+// Service level objective judged on the finished server spans: spans with an
+// error status are bad, and so are those lasting longer than Latency when
+// set, the others are good.
+type SLO struct {
+	// reported as slo.name, e.g. "package-lookup"
+	Name string
+	// path.Match patterns of the span names covered, every server span when
+	// empty
+	Spans []string
+	// spans lasting longer are bad, latency is not judged when zero
+	Latency time.Duration
+	// fraction of good spans aimed at, e.g. 0.999, reported as slo.objective
+	Objective float64
+}
+
+// Reports whether the objective covers the span named name.
+func (o SLO) covers(name string) bool {
+	if len(o.Spans) == 0 {
+		return true
+	}
+	for _, pattern := range o.Spans {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts the server spans covered by each objective in slo.spans, by
+// slo.name and slo.result, and reports the objectives in slo.objective, so
+// the burn rate of an objective over a window is
+//
+//	rate(slo.spans{slo.result="bad"}) / rate(slo.spans) / (1 - slo.objective)
+//
+// Only sampled spans are counted: ratios stay representative under head
+// sampling, the counts do not.
+func WithSLOs(slos ...SLO) Option {
+	return func(c *Config) {
+		c.SLOs = append(c.SLOs, slos...)
+	}
+}
+
+// Span processor classifying finished server spans against the objectives.
+type sloProcessor struct {
+	slos    []SLO
+	counter metric.Int64Counter
+}
+
+func newSLOProcessor(slos []SLO) sdktrace.SpanProcessor {
+	meter := Meter(instrumentationName, "")
+	counter, err := meter.Int64Counter(
+		"slo.spans",
+		metric.WithDescription("Server spans judged against the service level objectives, by result."),
+	)
+	HandleErr(err, "Failed to create the SLO counter")
+	_, err = meter.Float64ObservableGauge(
+		"slo.objective",
+		metric.WithDescription("Fraction of good spans aimed at by the service level objectives."),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			for _, slo := range slos {
+				o.Observe(slo.Objective, metric.WithAttributes(SLONameKey.String(slo.Name)))
+			}
+			return nil
+		}),
+	)
+	HandleErr(err, "Failed to create the SLO objective gauge")
+	RegisterMetrics(
+		Descriptor{
+			Name:        "slo.spans",
+			Kind:        "counter",
+			Description: "Server spans judged against the service level objectives, by result.",
+			Attributes:  []string{string(SLONameKey), string(SLOResultKey), string(SLOViolationKey)},
+		},
+		Descriptor{
+			Name:        "slo.objective",
+			Kind:        "gauge",
+			Description: "Fraction of good spans aimed at by the service level objectives.",
+			Attributes:  []string{string(SLONameKey)},
+		},
+	)
+	return &sloProcessor{slos: slos, counter: counter}
+}
+
+func (p *sloProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *sloProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanKind() != trace.SpanKindServer {
+		return
+	}
+	for _, slo := range p.slos {
+		if !slo.covers(s.Name()) {
+			continue
+		}
+		attrs := []attribute.KeyValue{SLONameKey.String(slo.Name), SLOResultKey.String(SLOResultGood)}
+		switch {
+		case s.Status().Code == codes.Error:
+			attrs[1] = SLOResultKey.String(SLOResultBad)
+			attrs = append(attrs, SLOViolationKey.String(SLOViolationError))
+		case slo.Latency > 0 && s.EndTime().Sub(s.StartTime()) > slo.Latency:
+			attrs[1] = SLOResultKey.String(SLOResultBad)
+			attrs = append(attrs, SLOViolationKey.String(SLOViolationLatency))
+		}
+		p.counter.Add(context.Background(), 1, metric.WithAttributes(attrs...))
+	}
+}
+
+func (p *sloProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *sloProcessor) ForceFlush(context.Context) error { return nil }
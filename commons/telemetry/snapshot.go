@@ -0,0 +1,79 @@
+package telemetry
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Finished span built from its parts, e.g. read back from the write-ahead
+// log or rewritten by a processor before export.
+type spanSnapshot struct {
+	// only provides the unexported method of the interface, never called
+	sdktrace.ReadOnlySpan
+
+	name              string
+	spanContext       trace.SpanContext
+	parent            trace.SpanContext
+	kind              trace.SpanKind
+	start             time.Time
+	end               time.Time
+	attributes        []attribute.KeyValue
+	events            []sdktrace.Event
+	links             []sdktrace.Link
+	status            sdktrace.Status
+	droppedAttributes int
+	droppedEvents     int
+	droppedLinks      int
+	childSpanCount    int
+	resource          *resource.Resource
+	scope             instrumentation.Scope
+}
+
+// Returns a copy of s whose parts can be changed.
+func snapshotOf(s sdktrace.ReadOnlySpan) *spanSnapshot {
+	return &spanSnapshot{
+		name:              s.Name(),
+		spanContext:       s.SpanContext(),
+		parent:            s.Parent(),
+		kind:              s.SpanKind(),
+		start:             s.StartTime(),
+		end:               s.EndTime(),
+		attributes:        s.Attributes(),
+		events:            s.Events(),
+		links:             s.Links(),
+		status:            s.Status(),
+		droppedAttributes: s.DroppedAttributes(),
+		droppedEvents:     s.DroppedEvents(),
+		droppedLinks:      s.DroppedLinks(),
+		childSpanCount:    s.ChildSpanCount(),
+		resource:          s.Resource(),
+		scope:             s.InstrumentationScope(),
+	}
+}
+
+func (s *spanSnapshot) Name() string                                { return s.name }
+func (s *spanSnapshot) SpanContext() trace.SpanContext              { return s.spanContext }
+func (s *spanSnapshot) Parent() trace.SpanContext                   { return s.parent }
+func (s *spanSnapshot) SpanKind() trace.SpanKind                    { return s.kind }
+func (s *spanSnapshot) StartTime() time.Time                        { return s.start }
+func (s *spanSnapshot) EndTime() time.Time                          { return s.end }
+func (s *spanSnapshot) Attributes() []attribute.KeyValue            { return s.attributes }
+func (s *spanSnapshot) Events() []sdktrace.Event                    { return s.events }
+func (s *spanSnapshot) Links() []sdktrace.Link                      { return s.links }
+func (s *spanSnapshot) Status() sdktrace.Status                     { return s.status }
+func (s *spanSnapshot) DroppedAttributes() int                      { return s.droppedAttributes }
+func (s *spanSnapshot) DroppedEvents() int                          { return s.droppedEvents }
+func (s *spanSnapshot) DroppedLinks() int                           { return s.droppedLinks }
+func (s *spanSnapshot) ChildSpanCount() int                         { return s.childSpanCount }
+func (s *spanSnapshot) Resource() *resource.Resource                { return s.resource }
+func (s *spanSnapshot) InstrumentationScope() instrumentation.Scope { return s.scope }
+
+//nolint:staticcheck // part of the interface
+func (s *spanSnapshot) InstrumentationLibrary() instrumentation.Library {
+	return instrumentation.Library(s.scope)
+}
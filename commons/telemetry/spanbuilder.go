@@ -0,0 +1,116 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fluent builder of spans, e.g.
+//
+//	ctx, span, end := telemetry.Span(ctx, "GET warehouse").Attr("peer.service", "warehouse").Client().Start()
+//	defer func() { end(err) }()
+type SpanBuilder struct {
+	ctx   context.Context
+	name  string
+	scope string
+	kind  trace.SpanKind
+	attrs []attribute.KeyValue
+	links []trace.Link
+}
+
+// Starts building a span named name, child of the span in ctx.
+func Span(ctx context.Context, name string) *SpanBuilder {
+	return &SpanBuilder{ctx: ctx, name: name, scope: instrumentationName}
+}
+
+// Names the instrumentation scope of the tracer starting the span, the
+// telemetry package by default.
+func (b *SpanBuilder) Scope(name string) *SpanBuilder {
+	b.scope = name
+	return b
+}
+
+// Adds an attribute, converting value to the attribute type matching its Go
+// type. Values of other types are recorded as strings.
+func (b *SpanBuilder) Attr(key string, value any) *SpanBuilder {
+	b.attrs = append(b.attrs, attribute.KeyValue{Key: attribute.Key(key), Value: attributeValue(value)})
+	return b
+}
+
+// Adds already built attributes.
+func (b *SpanBuilder) Attrs(attrs ...attribute.KeyValue) *SpanBuilder {
+	b.attrs = append(b.attrs, attrs...)
+	return b
+}
+
+// Links the span to the span of the given context.
+func (b *SpanBuilder) Link(sc trace.SpanContext, attrs ...attribute.KeyValue) *SpanBuilder {
+	b.links = append(b.links, trace.Link{SpanContext: sc, Attributes: attrs})
+	return b
+}
+
+// Sets the span kind, internal by default.
+func (b *SpanBuilder) Kind(kind trace.SpanKind) *SpanBuilder {
+	b.kind = kind
+	return b
+}
+
+func (b *SpanBuilder) Client() *SpanBuilder { return b.Kind(trace.SpanKindClient) }
+
+func (b *SpanBuilder) Server() *SpanBuilder { return b.Kind(trace.SpanKindServer) }
+
+func (b *SpanBuilder) Producer() *SpanBuilder { return b.Kind(trace.SpanKindProducer) }
+
+func (b *SpanBuilder) Consumer() *SpanBuilder { return b.Kind(trace.SpanKindConsumer) }
+
+// Starts the span with the provider of the span in the context, the global
+// one when there is none. The returned end function records a non nil error
+// on the span, setting its status, and ends it.
+func (b *SpanBuilder) Start() (context.Context, trace.Span, func(error)) {
+	provider := otel.GetTracerProvider()
+	if parent := trace.SpanFromContext(b.ctx); parent.SpanContext().IsValid() {
+		provider = parent.TracerProvider()
+	}
+	opts := []trace.SpanStartOption{trace.WithAttributes(b.attrs...), trace.WithLinks(b.links...)}
+	if b.kind != trace.SpanKindUnspecified {
+		opts = append(opts, trace.WithSpanKind(b.kind))
+	}
+	ctx, span := cachedTracer(provider, b.scope, "").Start(b.ctx, b.name, opts...)
+	return ctx, span, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func attributeValue(v any) attribute.Value {
+	switch v := v.(type) {
+	case string:
+		return attribute.StringValue(v)
+	case bool:
+		return attribute.BoolValue(v)
+	case int:
+		return attribute.IntValue(v)
+	case int64:
+		return attribute.Int64Value(v)
+	case float64:
+		return attribute.Float64Value(v)
+	case []string:
+		return attribute.StringSliceValue(v)
+	case []int:
+		return attribute.IntSliceValue(v)
+	case []bool:
+		return attribute.BoolSliceValue(v)
+	case fmt.Stringer:
+		return attribute.StringValue(v.String())
+	default:
+		return attribute.StringValue(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/gorilla/mux"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Builds span names following the naming conventions: a span name identifies
+// a class of operations, never a single one, so IDs and other
+// high-cardinality values belong in attributes.
+type SpanNamer struct{}
+
+// Names server spans after the method and the route template, e.g.
+// "GET /packages/{id}", or the method alone when the route is unknown.
+func (SpanNamer) HTTPServer(method, route string) string {
+	if route == "" {
+		return method
+	}
+	return method + " " + route
+}
+
+// Names a server span from the gorilla/mux route matched by r.
+func (n SpanNamer) HTTPServerRequest(r *http.Request) string {
+	var route string
+	if cr := mux.CurrentRoute(r); cr != nil {
+		route, _ = cr.GetPathTemplate()
+	}
+	return n.HTTPServer(r.Method, route)
+}
+
+// Names client spans after the method only, the URL being unknown to be
+// low-cardinality.
+func (SpanNamer) HTTPClient(method string) string {
+	return method
+}
+
+// Names database spans after the operation and the table or collection, e.g.
+// "SELECT packages".
+func (SpanNamer) DB(operation, collection string) string {
+	if collection == "" {
+		return operation
+	}
+	return operation + " " + collection
+}
+
+// Names messaging spans after the destination and the operation, e.g.
+// "package.shipped publish".
+func (SpanNamer) Messaging(destination, operation string) string {
+	return destination + " " + operation
+}
+
+var idLikeTokens = regexp.MustCompile(
+	// UUIDs, long hex strings (hashes, trace IDs) and runs of 3+ digits
+	`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|\b[0-9a-fA-F]{16,}\b|[0-9]{3,}`)
+
+// Returns the first token of a span name looking like an ID.
+func IDLikeToken(name string) (string, bool) {
+	token := idLikeTokens.FindString(name)
+	return token, token != ""
+}
+
+// Number of distinct span names remembered by the check.
+const maxCheckedSpanNames = 1024
+
+// Span processor reporting span names containing ID-like tokens, once per
+// name. Such names explode the cardinality of span-name based views.
+type spanNameCheckProcessor struct {
+	report  func(name, token string)
+	mu      sync.Mutex
+	checked map[string]struct{}
+}
+
+// Creates a processor calling report for every distinct span name holding an
+// ID-like token, logging a warning when report is nil.
+func NewSpanNameCheckProcessor(report func(name, token string)) sdktrace.SpanProcessor {
+	if report == nil {
+		report = func(name, token string) {
+			log.Printf("telemetry: span name %q contains ID-like token %q, move it into an attribute", name, token)
+		}
+	}
+	return &spanNameCheckProcessor{report: report, checked: map[string]struct{}{}}
+}
+
+func (p *spanNameCheckProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *spanNameCheckProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	name := s.Name()
+	p.mu.Lock()
+	_, seen := p.checked[name]
+	full := len(p.checked) >= maxCheckedSpanNames
+	if !seen && !full {
+		p.checked[name] = struct{}{}
+	}
+	p.mu.Unlock()
+	if seen || full {
+		return
+	}
+	if token, ok := IDLikeToken(name); ok {
+		p.report(name, token)
+	}
+}
+
+func (p *spanNameCheckProcessor) Shutdown(context.Context) error { return nil }
+
+func (p *spanNameCheckProcessor) ForceFlush(context.Context) error { return nil }
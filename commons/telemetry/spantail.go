@@ -0,0 +1,198 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Keeps the last finished spans in a ring buffer and streams them, along
+// with the spans finishing afterwards, as NDJSON, e.g.
+//
+//	curl -N 'localhost:8080/debug/spans/stream?name=GET*&min_duration=50ms&errors=true'
+type SpanTail struct {
+	mu          sync.Mutex
+	ring        []tailedSpan
+	next        int
+	full        bool
+	subscribers map[chan tailedSpan]struct{}
+}
+
+// Finished span as streamed by SpanTail.
+type tailedSpan struct {
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"`
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Start        time.Time         `json:"start"`
+	Duration     time.Duration     `json:"duration_ns"`
+	Status       string            `json:"status"`
+	StatusMsg    string            `json:"status_message,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+// Creates a tail keeping the last size spans.
+func NewSpanTail(size int) *SpanTail {
+	if size <= 0 {
+		size = 1
+	}
+	return &SpanTail{ring: make([]tailedSpan, size), subscribers: map[chan tailedSpan]struct{}{}}
+}
+
+// Feeds the finished spans into t, nil disabling the tail.
+func WithSpanTail(t *SpanTail) Option {
+	return func(c *Config) {
+		c.SpanTail = t
+	}
+}
+
+func (t *SpanTail) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (t *SpanTail) OnEnd(s sdktrace.ReadOnlySpan) {
+	span := tailedSpan{
+		Name:      s.Name(),
+		Kind:      s.SpanKind().String(),
+		TraceID:   s.SpanContext().TraceID().String(),
+		SpanID:    s.SpanContext().SpanID().String(),
+		Start:     s.StartTime(),
+		Duration:  s.EndTime().Sub(s.StartTime()),
+		Status:    s.Status().Code.String(),
+		StatusMsg: s.Status().Description,
+	}
+	if s.Parent().IsValid() {
+		span.ParentSpanID = s.Parent().SpanID().String()
+	}
+	if attrs := s.Attributes(); len(attrs) > 0 {
+		span.Attributes = make(map[string]string, len(attrs))
+		for _, kv := range attrs {
+			span.Attributes[string(kv.Key)] = kv.Value.Emit()
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ring[t.next] = span
+	t.next = (t.next + 1) % len(t.ring)
+	t.full = t.full || t.next == 0
+	for ch := range t.subscribers {
+		// slow readers miss spans rather than hold the application back
+		select {
+		case ch <- span:
+		default:
+		}
+	}
+}
+
+func (t *SpanTail) Shutdown(context.Context) error { return nil }
+
+func (t *SpanTail) ForceFlush(context.Context) error { return nil }
+
+// Returns the buffered spans, oldest first, and subscribes to the next ones.
+func (t *SpanTail) subscribe() ([]tailedSpan, chan tailedSpan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var spans []tailedSpan
+	if t.full {
+		spans = append(spans, t.ring[t.next:]...)
+	}
+	spans = append(spans, t.ring[:t.next]...)
+	ch := make(chan tailedSpan, 256)
+	t.subscribers[ch] = struct{}{}
+	return spans, ch
+}
+
+func (t *SpanTail) unsubscribe(ch chan tailedSpan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.subscribers, ch)
+}
+
+// Filter of the streamed spans, from the query parameters.
+type tailFilter struct {
+	// span name, with * and ? wildcards
+	name string
+	// shortest duration streamed
+	minDuration time.Duration
+	// only stream spans with an error status
+	errors bool
+}
+
+func (f tailFilter) match(s tailedSpan) bool {
+	if f.name != "" {
+		if ok, _ := path.Match(f.name, s.Name); !ok {
+			return false
+		}
+	}
+	if f.errors && s.Status != codes.Error.String() {
+		return false
+	}
+	return s.Duration >= f.minDuration
+}
+
+// Streams the buffered and newly finished spans as NDJSON until the client
+// disconnects. The name, min_duration and errors query parameters filter
+// them.
+func (t *SpanTail) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := tailFilter{name: q.Get("name")}
+		if v := q.Get("min_duration"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				http.Error(w, "invalid min_duration: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.minDuration = d
+		}
+		if v := q.Get("errors"); v != "" {
+			errorsOnly, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "invalid errors: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.errors = errorsOnly
+		}
+
+		rc := http.NewResponseController(w)
+		// the server write timeout must not cut the stream
+		_ = rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		buffered, ch := t.subscribe()
+		defer t.unsubscribe(ch)
+		enc := json.NewEncoder(w)
+		write := func(s tailedSpan) error {
+			if !f.match(s) {
+				return nil
+			}
+			if err := enc.Encode(s); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		for _, s := range buffered {
+			if err := write(s); err != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case s := <-ch:
+				if err := write(s); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,252 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Settings of the StatsD bridge.
+type StatsDConfig struct {
+	// sink address, udp://host:port or unix:///path/to/socket
+	Addr string
+	// prepended to every metric name, e.g. "shop."
+	Prefix string
+	// names of the mirrored instruments, with * and ? wildcards; all when
+	// empty
+	Instruments []string
+	// tag names of attributes, attributes mapped to "" being dropped
+	Tags map[attribute.Key]string
+	// send tags the DogStatsD way, plain StatsD having none
+	DogStatsD bool
+}
+
+type StatsDOption func(*StatsDConfig)
+
+// Prepends prefix to the name of every mirrored metric.
+func WithStatsDPrefix(prefix string) StatsDOption {
+	return func(c *StatsDConfig) {
+		c.Prefix = prefix
+	}
+}
+
+// Mirrors only the instruments matching the given names, which may contain *
+// and ? wildcards.
+func WithStatsDInstruments(names ...string) StatsDOption {
+	return func(c *StatsDConfig) {
+		c.Instruments = append(c.Instruments, names...)
+	}
+}
+
+// Sends the attribute key as the tag named tag, or drops it when tag is
+// empty, so the mirrored metrics match what the legacy dashboards query.
+func WithStatsDTag(key attribute.Key, tag string) StatsDOption {
+	return func(c *StatsDConfig) {
+		if c.Tags == nil {
+			c.Tags = map[attribute.Key]string{}
+		}
+		c.Tags[key] = tag
+	}
+}
+
+// Speaks plain StatsD, without tags, instead of DogStatsD.
+func WithPlainStatsD() StatsDOption {
+	return func(c *StatsDConfig) {
+		c.DogStatsD = false
+	}
+}
+
+// Mirrors the metrics to a StatsD or DogStatsD sink at addr, on top of the
+// regular export, for teams feeding legacy dashboards while they migrate.
+// Counters are sent as deltas, UpDownCounters and gauges as gauges, and
+// histograms as their count and sum, StatsD having no notion of buckets.
+func WithStatsDBridge(addr string, opts ...StatsDOption) Option {
+	return func(c *Config) {
+		sd := &StatsDConfig{Addr: addr, DogStatsD: true}
+		for _, opt := range opts {
+			opt(sd)
+		}
+		c.StatsD = sd
+	}
+}
+
+// Largest payload written at once: a safe UDP datagram size, unix sockets
+// accepting more.
+const (
+	statsdUDPPayload  = 1432
+	statsdUnixPayload = 8192
+)
+
+// Metric exporter writing the metrics it is given to a StatsD sink.
+type statsdExporter struct {
+	config     StatsDConfig
+	conn       net.Conn
+	maxPayload int
+}
+
+func newStatsDExporter(config StatsDConfig) (*statsdExporter, error) {
+	network, addr, ok := strings.Cut(config.Addr, "://")
+	if !ok {
+		network, addr = "udp", config.Addr
+	}
+	maxPayload := statsdUDPPayload
+	switch network {
+	case "udp":
+	case "unix":
+		network, maxPayload = "unixgram", statsdUnixPayload
+	default:
+		return nil, fmt.Errorf("unsupported StatsD address %q, expected udp:// or unix://", config.Addr)
+	}
+	// datagrams need no listener, the sink may come up later
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdExporter{config: config, conn: conn, maxPayload: maxPayload}, nil
+}
+
+func (e *statsdExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return DeltaTemporality(kind)
+}
+
+func (e *statsdExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return defaultAggregation(kind)
+}
+
+func (e *statsdExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	var lines []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if e.selected(m.Name) {
+				lines = append(lines, e.lines(m)...)
+			}
+		}
+	}
+
+	var errs []error
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > e.maxPayload {
+			_, err := e.conn.Write(packet)
+			errs = append(errs, err)
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		_, err := e.conn.Write(packet)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (e *statsdExporter) selected(name string) bool {
+	if len(e.config.Instruments) == 0 {
+		return true
+	}
+	for _, pattern := range e.config.Instruments {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Formats the data points of m as StatsD lines.
+func (e *statsdExporter) lines(m metricdata.Metrics) []string {
+	name := e.config.Prefix + m.Name
+	var lines []string
+	add := func(name string, value float64, kind string, attrs attribute.Set) {
+		lines = append(lines, name+":"+strconv.FormatFloat(value, 'f', -1, 64)+"|"+kind+e.tags(attrs))
+	}
+
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		kind := statsdSumKind(data)
+		for _, dp := range data.DataPoints {
+			add(name, float64(dp.Value), kind, dp.Attributes)
+		}
+	case metricdata.Sum[float64]:
+		kind := statsdSumKind(data)
+		for _, dp := range data.DataPoints {
+			add(name, dp.Value, kind, dp.Attributes)
+		}
+	case metricdata.Gauge[int64]:
+		for _, dp := range data.DataPoints {
+			add(name, float64(dp.Value), "g", dp.Attributes)
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range data.DataPoints {
+			add(name, dp.Value, "g", dp.Attributes)
+		}
+	case metricdata.Histogram[int64]:
+		for _, dp := range data.DataPoints {
+			add(name+".count", float64(dp.Count), "c", dp.Attributes)
+			add(name+".sum", float64(dp.Sum), "c", dp.Attributes)
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range data.DataPoints {
+			add(name+".count", float64(dp.Count), "c", dp.Attributes)
+			add(name+".sum", dp.Sum, "c", dp.Attributes)
+		}
+	}
+	return lines
+}
+
+// Monotonic delta sums are counters, anything else a gauge.
+func statsdSumKind[N int64 | float64](s metricdata.Sum[N]) string {
+	if s.IsMonotonic && s.Temporality == metricdata.DeltaTemporality {
+		return "c"
+	}
+	return "g"
+}
+
+// Formats attrs as DogStatsD tags, following the tag mapping rules.
+func (e *statsdExporter) tags(attrs attribute.Set) string {
+	if !e.config.DogStatsD || attrs.Len() == 0 {
+		return ""
+	}
+	var tags []string
+	for _, kv := range attrs.ToSlice() {
+		tag := string(kv.Key)
+		if mapped, ok := e.config.Tags[kv.Key]; ok {
+			if mapped == "" {
+				continue
+			}
+			tag = mapped
+		}
+		tags = append(tags, statsdSafe(tag)+":"+statsdSafe(kv.Value.Emit()))
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return "|#" + strings.Join(tags, ",")
+}
+
+// Replaces the characters delimiting StatsD fields.
+func statsdSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', ',', '#', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func (e *statsdExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *statsdExporter) Shutdown(context.Context) error {
+	return e.conn.Close()
+}
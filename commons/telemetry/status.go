@@ -0,0 +1,127 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Number of pipeline errors kept by the status.
+const recentErrorsSize = 10
+
+// Snapshot of the telemetry pipeline, e.g. for a status page.
+type Status struct {
+	Service   string
+	Collector string
+	// whether the collector connection is ready
+	Connected bool
+	// exporter selected by the controller, otlp without one
+	Exporter string
+	Sampler  string
+	// errors reported to the global error handler since the start, and the
+	// last ones
+	PipelineErrors int64
+	RecentErrors   []PipelineError
+	// spans which ended with an error status since the start
+	ErrorSpans int64
+}
+
+// Error reported by the telemetry pipeline, e.g. a failed export.
+type PipelineError struct {
+	Time    time.Time
+	Message string
+}
+
+// State of the providers set by the last InitProvider.
+type statusTracker struct {
+	mu         sync.Mutex
+	service    string
+	collector  string
+	watcher    *connectionWatcher
+	sampler    sdktrace.Sampler
+	controller *Controller
+	recent     []PipelineError
+
+	pipelineErrors atomic.Int64
+	errorSpans     atomic.Int64
+}
+
+var status = &statusTracker{}
+
+// Returns the current state of the telemetry pipeline.
+func CurrentStatus() Status {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+	s := Status{
+		Service:        status.service,
+		Collector:      status.collector,
+		Exporter:       "otlp",
+		PipelineErrors: status.pipelineErrors.Load(),
+		RecentErrors:   append([]PipelineError(nil), status.recent...),
+		ErrorSpans:     status.errorSpans.Load(),
+	}
+	if status.watcher != nil {
+		s.Connected = status.watcher.connected.Load()
+	}
+	if status.sampler != nil {
+		s.Sampler = status.sampler.Description()
+	}
+	if status.controller != nil {
+		s.Exporter = status.controller.Exporter()
+	}
+	return s
+}
+
+func (t *statusTracker) set(cfg Config, collector string, watcher *connectionWatcher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.service, t.collector, t.watcher, t.controller = cfg.ServiceName, collector, watcher, cfg.Controller
+}
+
+func (t *statusTracker) setCollector(collector string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.collector = collector
+}
+
+func (t *statusTracker) setSampler(sampler sdktrace.Sampler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sampler = sampler
+}
+
+// Error handler keeping the recent errors, logging them as the default one
+// does.
+func (t *statusTracker) Handle(err error) {
+	log.Print(err)
+	t.pipelineErrors.Add(1)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recent = append(t.recent, PipelineError{Time: time.Now(), Message: err.Error()})
+	if len(t.recent) > recentErrorsSize {
+		t.recent = t.recent[len(t.recent)-recentErrorsSize:]
+	}
+}
+
+var _ otel.ErrorHandler = (*statusTracker)(nil)
+
+// Span processor counting the spans which ended with an error status.
+type statusSpanProcessor struct{}
+
+func (statusSpanProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (statusSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.Status().Code == codes.Error {
+		status.errorSpans.Add(1)
+	}
+}
+
+func (statusSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (statusSpanProcessor) ForceFlush(context.Context) error { return nil }
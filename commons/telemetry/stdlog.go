@@ -0,0 +1,163 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// How long an error record may hold the logging goroutine back while it is
+// exported, so the line logged before log.Fatal exits is not lost.
+const stdLogErrorFlushTimeout = time.Second
+
+// Set while an error record is flushed, so the export errors the flush logs
+// through the error handler do not flush again.
+var stdLogFlushing atomic.Bool
+
+// Routes the output of the global log package through the OTel log
+// pipeline, each line becoming a record of the severity its text starts with
+// (DEBUG, INFO, WARN, ERROR or FATAL, optionally bracketed), or of error
+// severity when it reports a failure. Lines are still written to the
+// previous output. The log package passes no context, so these records
+// carry no trace; handlers log through ContextLogger to correlate their
+// lines with the request span. Returns a function restoring the previous
+// output.
+func RedirectStdLog() (restore func()) {
+	std := log.Default()
+	previous := std.Writer()
+	std.SetOutput(newStdLogWriter(context.Background(), std, previous))
+	return func() { std.SetOutput(previous) }
+}
+
+// Returns a logger with the flags and prefix of the global one whose lines
+// are emitted as records correlated with the span of ctx, and written to the
+// output of the global logger.
+func ContextLogger(ctx context.Context) *log.Logger {
+	std := log.Default()
+	l := log.New(io.Discard, std.Prefix(), std.Flags())
+	out := std.Writer()
+	if w, ok := out.(*stdLogWriter); ok {
+		out = w.out
+	}
+	l.SetOutput(newStdLogWriter(ctx, l, out))
+	return l
+}
+
+// Writer turning the lines of a log.Logger into log records.
+type stdLogWriter struct {
+	ctx    context.Context
+	source *log.Logger
+	out    io.Writer
+	logger otellog.Logger
+	mu     sync.Mutex
+}
+
+func newStdLogWriter(ctx context.Context, source *log.Logger, out io.Writer) *stdLogWriter {
+	return &stdLogWriter{
+		ctx:    ctx,
+		source: source,
+		out:    out,
+		logger: global.Logger(instrumentationName),
+	}
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	n, err := w.out.Write(p)
+	w.mu.Unlock()
+
+	now := time.Now()
+	msg, file, line := stripLogHeader(strings.TrimSuffix(string(p), "\n"), w.source.Prefix(), w.source.Flags())
+	severity, text, msg := parseSeverity(msg)
+
+	var rec otellog.Record
+	rec.SetTimestamp(now)
+	rec.SetObservedTimestamp(now)
+	rec.SetSeverity(severity)
+	rec.SetSeverityText(text)
+	rec.SetBody(otellog.StringValue(msg))
+	if file != "" {
+		rec.AddAttributes(
+			otellog.String(string(semconv.CodeFilepathKey), file),
+			otellog.Int(string(semconv.CodeLineNumberKey), line),
+		)
+	}
+	w.logger.Emit(w.ctx, rec)
+
+	if f, ok := global.GetLoggerProvider().(flusher); ok && severity >= otellog.SeverityError && stdLogFlushing.CompareAndSwap(false, true) {
+		ctx, cancel := context.WithTimeout(context.Background(), stdLogErrorFlushTimeout)
+		_ = f.ForceFlush(ctx)
+		cancel()
+		stdLogFlushing.Store(false)
+	}
+	return n, err
+}
+
+// Removes the prefix, date, time and file the logger writes before the
+// message, returning the file and line when written.
+func stripLogHeader(s, prefix string, flags int) (msg, file string, line int) {
+	if flags&log.Lmsgprefix == 0 {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	if flags&log.Ldate != 0 && len(s) >= len("2006/01/02 ") {
+		s = s[len("2006/01/02 "):]
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n := len("15:04:05 ")
+		if flags&log.Lmicroseconds != 0 {
+			n = len("15:04:05.000000 ")
+		}
+		if len(s) >= n {
+			s = s[n:]
+		}
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if loc, rest, ok := strings.Cut(s, ": "); ok {
+			if i := strings.LastIndexByte(loc, ':'); i > 0 {
+				file = loc[:i]
+				line, _ = strconv.Atoi(loc[i+1:])
+			}
+			s = rest
+		}
+	}
+	if flags&log.Lmsgprefix != 0 {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	return s, file, line
+}
+
+// Severity of the level names lines may start with.
+var logLevels = map[string]otellog.Severity{
+	"DEBUG":   otellog.SeverityDebug,
+	"INFO":    otellog.SeverityInfo,
+	"WARN":    otellog.SeverityWarn,
+	"WARNING": otellog.SeverityWarn,
+	"ERROR":   otellog.SeverityError,
+	"FATAL":   otellog.SeverityFatal,
+	"PANIC":   otellog.SeverityFatal,
+}
+
+// Returns the severity of msg, its name, and msg without the level it
+// starts with. Lines without a level are errors when they report a failure,
+// as in "Failed to start server: ...", and informational otherwise.
+func parseSeverity(msg string) (otellog.Severity, string, string) {
+	word, rest, _ := strings.Cut(msg, " ")
+	level := strings.ToUpper(strings.Trim(word, "[]:"))
+	if severity, ok := logLevels[level]; ok {
+		return severity, level, strings.TrimSpace(rest)
+	}
+	lower := strings.ToLower(msg)
+	if strings.HasPrefix(lower, "failed") || strings.Contains(lower, "error:") {
+		return otellog.SeverityError, "ERROR", msg
+	}
+	return otellog.SeverityInfo, "INFO", msg
+}
@@ -0,0 +1,35 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exports every span synchronously, the instant it ends, instead of batching
+// them, and logs one line per span. Meant for development only: exporting
+// blocks the code ending spans and costs a round trip per span.
+func WithSyncExportDevOnly(enabled bool) Option {
+	return func(c *Config) {
+		c.SyncExport = enabled
+	}
+}
+
+// Span exporter writing one log line per span.
+type logExporter struct{}
+
+func (logExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	for _, s := range spans {
+		parent := "-"
+		if s.Parent().IsValid() {
+			parent = s.Parent().SpanID().String()
+		}
+		log.Printf("span %q trace=%s span=%s parent=%s duration=%s status=%s",
+			s.Name(), s.SpanContext().TraceID(), s.SpanContext().SpanID(), parent,
+			s.EndTime().Sub(s.StartTime()), s.Status().Code)
+	}
+	return nil
+}
+
+func (logExporter) Shutdown(context.Context) error { return nil }
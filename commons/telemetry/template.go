@@ -0,0 +1,24 @@
+package telemetry
+
+import (
+	"context"
+	"html/template"
+	"io"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Renders the template name of t into w within a "render <name>" span, so
+// slow views show in the traces next to the work feeding them.
+func ExecuteTemplate(ctx context.Context, w io.Writer, t *template.Template, name string, data any) error {
+	_, span := Tracer(instrumentationName, "").Start(ctx, "render "+name)
+	defer span.End()
+	span.SetAttributes(attribute.String("template.name", name))
+	if err := t.ExecuteTemplate(w, name, data); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
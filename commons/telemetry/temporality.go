@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Selects the temporality of the exported metrics. Without this option the
+// OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE environment variable
+// decides, cumulative by default.
+func WithTemporality(selector sdkmetric.TemporalitySelector) Option {
+	return func(c *Config) {
+		c.Temporality = selector
+	}
+}
+
+// Selects how instruments are aggregated by default, views taking
+// precedence.
+func WithAggregation(selector sdkmetric.AggregationSelector) Option {
+	return func(c *Config) {
+		c.Aggregation = selector
+	}
+}
+
+// Exports counters and histograms as deltas, as Datadog and StatsD-like
+// backends expect. UpDownCounters stay cumulative, their deltas being
+// meaningless on their own.
+func DeltaTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindCounter,
+		sdkmetric.InstrumentKindObservableCounter,
+		sdkmetric.InstrumentKindHistogram:
+		return metricdata.DeltaTemporality
+	}
+	return metricdata.CumulativeTemporality
+}
+
+// Exports every instrument cumulatively, as Prometheus expects.
+func CumulativeTemporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+// Aggregates histograms into base-2 exponential histograms, which adapt
+// their buckets to the recorded values, as supported by Prometheus native
+// histograms and most vendors, other instruments as by default.
+func ExponentialHistograms(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	if kind == sdkmetric.InstrumentKindHistogram {
+		return sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}
+	}
+	return defaultAggregation(kind)
+}
@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// header carrying the tenant of a request
+	TenantHeader = "X-Tenant-ID"
+	// span and metric attribute, and baggage member, holding the tenant
+	TenantKey = attribute.Key("tenant.id")
+)
+
+type tenantKey struct{}
+
+// Returns a copy of ctx carrying the given tenant, also added to the baggage
+// so downstream services receive it.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	ctx = context.WithValue(ctx, tenantKey{}, tenant)
+	if m, err := baggage.NewMember(string(TenantKey), tenant); err == nil {
+		if bag, err := baggage.FromContext(ctx).SetMember(m); err == nil {
+			ctx = baggage.ContextWithBaggage(ctx, bag)
+		}
+	}
+	return ctx
+}
+
+// Returns the tenant stored in ctx, falling back to the one propagated
+// through baggage by an upstream service.
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant
+	}
+	return baggage.FromContext(ctx).Member(string(TenantKey)).Value()
+}
+
+// Middleware taking the tenant from the X-Tenant-ID header, or from the
+// inbound baggage, and storing it in the request context. It must be
+// installed before the tracing middleware so samplers see the tenant and
+// request spans get the tenant.id attribute, and before LatencyMiddleware so
+// measurements carry it.
+func TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(TenantHeader)
+		if tenant == "" {
+			inbound := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			tenant = TenantFromContext(inbound)
+		}
+		if tenant == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithTenant(r.Context(), tenant)))
+	})
+}
+
+// Span processor stamping the tenant of the parent context on every span.
+type tenantSpanProcessor struct{}
+
+func (tenantSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if tenant := TenantFromContext(parent); tenant != "" {
+		s.SetAttributes(TenantKey.String(tenant))
+	}
+}
+
+func (tenantSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (tenantSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (tenantSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+type tenantSampler struct {
+	ratios   map[string]float64
+	samplers map[string]sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+// Creates a sampler applying a per-tenant sampling ratio to new traces, so
+// the telemetry cost of each customer can be controlled. Traces of other
+// tenants, and spans with a parent, are left to fallback.
+func NewTenantSampler(fallback sdktrace.Sampler, ratios map[string]float64) sdktrace.Sampler {
+	s := &tenantSampler{ratios: ratios, samplers: map[string]sdktrace.Sampler{}, fallback: fallback}
+	for tenant, ratio := range ratios {
+		s.samplers[tenant] = ratioSampler(ratio)
+	}
+	return s
+}
+
+func (s *tenantSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if !trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		if sampler, ok := s.samplers[TenantFromContext(p.ParentContext)]; ok {
+			return sampler.ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *tenantSampler) Description() string {
+	tenants := make([]string, 0, len(s.ratios))
+	for tenant, ratio := range s.ratios {
+		tenants = append(tenants, fmt.Sprintf("%s=%g", tenant, ratio))
+	}
+	sort.Strings(tenants)
+	return fmt.Sprintf("TenantSampler{tenants:[%s],fallback:%s}", strings.Join(tenants, ","), s.fallback.Description())
+}
+
+// Parses per-tenant sampling ratios written as "acme=0.1,globex=1".
+func ParseTenantRatios(s string) (map[string]float64, error) {
+	ratios := map[string]float64{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		tenant, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tenant ratio %q, expected tenant=ratio", pair)
+		}
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid ratio %q for tenant %q, expected a number within [0, 1]", value, tenant)
+		}
+		ratios[strings.TrimSpace(tenant)] = ratio
+	}
+	return ratios, nil
+}
@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// How often the throttling processor reports the spans it dropped.
+const throttleReportInterval = 10 * time.Second
+
+const (
+	// attribute of telemetry.spans.dropped telling why spans were dropped
+	DropReasonKey = attribute.Key("reason")
+	// reason recorded for spans dropped by the throttling processor
+	DropReasonRateLimited = "rate_limited"
+)
+
+// Span processor capping the number of spans handed to the next processor
+// with a token bucket, protecting the collector during traffic spikes.
+type throttlingSpanProcessor struct {
+	next  sdktrace.SpanProcessor
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	dropped map[string]int64
+
+	counter  metric.Int64Counter
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+// Creates a processor forwarding at most perSecond spans per second to next,
+// with bursts of up to burst spans. Dropped spans are counted per reason in
+// the telemetry.spans.dropped counter and logged every 10 seconds.
+func NewThrottlingSpanProcessor(next sdktrace.SpanProcessor, perSecond float64, burst int) sdktrace.SpanProcessor {
+	if burst < 1 {
+		burst = 1
+	}
+	counter, err := Meter(instrumentationName, "").Int64Counter(
+		"telemetry.spans.dropped",
+		metric.WithDescription("Spans dropped before export, by reason."),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	p := &throttlingSpanProcessor{
+		next:    next,
+		rate:    perSecond,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+		dropped: map[string]int64{},
+		counter: counter,
+		stop:    make(chan struct{}),
+	}
+	p.done.Add(1)
+	go p.report()
+	return p
+}
+
+func (p *throttlingSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *throttlingSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.take() {
+		p.next.OnEnd(s)
+		return
+	}
+	if p.counter != nil {
+		p.counter.Add(context.Background(), 1, metric.WithAttributes(DropReasonKey.String(DropReasonRateLimited)))
+	}
+	p.mu.Lock()
+	p.dropped[DropReasonRateLimited]++
+	p.mu.Unlock()
+}
+
+// Takes a token from the bucket, refilled according to the elapsed time.
+func (p *throttlingSpanProcessor) take() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.tokens = min(p.burst, p.tokens+now.Sub(p.last).Seconds()*p.rate)
+	p.last = now
+	if p.tokens < 1 {
+		return false
+	}
+	p.tokens--
+	return true
+}
+
+func (p *throttlingSpanProcessor) report() {
+	defer p.done.Done()
+	ticker := time.NewTicker(throttleReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			p.flushReport()
+			return
+		case <-ticker.C:
+			p.flushReport()
+		}
+	}
+}
+
+// Logs and resets the drop counts of the elapsed interval.
+func (p *throttlingSpanProcessor) flushReport() {
+	p.mu.Lock()
+	dropped := p.dropped
+	p.dropped = map[string]int64{}
+	p.mu.Unlock()
+	for reason, n := range dropped {
+		log.Printf("telemetry: dropped %d spans (%s)", n, reason)
+	}
+}
+
+func (p *throttlingSpanProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	p.done.Wait()
+	return p.next.Shutdown(ctx)
+}
+
+func (p *throttlingSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
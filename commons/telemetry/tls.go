@@ -0,0 +1,164 @@
+package telemetry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Secures the collector connection with the given TLS configuration instead
+// of plaintext. WithCACert and WithClientCert refine it.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Config) {
+		c.TLSConfig = config
+	}
+}
+
+// Verifies the collector certificate against the CA certificates of the PEM
+// file at path instead of the system pool. The file is reloaded when it
+// changes, on the next handshake.
+func WithCACert(path string) Option {
+	return func(c *Config) {
+		c.CACertFile = path
+	}
+}
+
+// Authenticates to the collector with the certificate and key of the PEM
+// files at certPath and keyPath (mTLS). Both are reloaded when they change,
+// on the next handshake, so rotated certificates are picked up without a
+// restart.
+func WithClientCert(certPath, keyPath string) Option {
+	return func(c *Config) {
+		c.ClientCertFile = certPath
+		c.ClientKeyFile = keyPath
+	}
+}
+
+// Returns the transport credentials of the collector connection, plaintext
+// unless TLS is configured.
+func collectorCredentials(cfg Config) (credentials.TransportCredentials, error) {
+	if cfg.TLSConfig == nil && cfg.CACertFile == "" && cfg.ClientCertFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSConfig != nil {
+		config = cfg.TLSConfig.Clone()
+	}
+
+	if cfg.CACertFile != "" {
+		ca := &fileReloader[*x509.CertPool]{paths: []string{cfg.CACertFile}, load: loadCertPool}
+		if _, err := ca.get(); err != nil {
+			return nil, err
+		}
+		// the standard verification uses a fixed pool, verify against the
+		// current one instead
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			pool, err := ca.get()
+			if err != nil {
+				return err
+			}
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("collector presented no certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range cs.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err = cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       cs.ServerName,
+				Roots:         pool,
+				Intermediates: intermediates,
+			})
+			return err
+		}
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert := &fileReloader[*tls.Certificate]{
+			paths: []string{cfg.ClientCertFile, cfg.ClientKeyFile},
+			load: func(paths []string) (*tls.Certificate, error) {
+				c, err := tls.LoadX509KeyPair(paths[0], paths[1])
+				return &c, err
+			},
+		}
+		if _, err := cert.get(); err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+func loadCertPool(paths []string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(paths[0])
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", paths[0])
+	}
+	return pool, nil
+}
+
+// Value loaded from files and loaded again whenever one of them is modified.
+// A failed reload keeps serving the previous value.
+type fileReloader[T any] struct {
+	paths []string
+	load  func(paths []string) (T, error)
+
+	mu       sync.Mutex
+	value    T
+	modTimes []time.Time
+	loaded   bool
+}
+
+func (r *fileReloader[T]) get() (T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes := make([]time.Time, len(r.paths))
+	for i, p := range r.paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			if r.loaded {
+				return r.value, nil
+			}
+			return r.value, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.loaded && equalTimes(modTimes, r.modTimes) {
+		return r.value, nil
+	}
+
+	value, err := r.load(r.paths)
+	if err != nil {
+		if r.loaded {
+			return r.value, nil
+		}
+		return value, err
+	}
+	r.value, r.modTimes, r.loaded = value, modTimes, true
+	return value, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
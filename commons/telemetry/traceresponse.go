@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header carrying the trace context of the response, as drafted by the W3C
+// Trace Context Level 2 specification.
+const DefaultTraceResponseHeader = "traceresponse"
+
+type traceResponseConfig struct {
+	header       string
+	serverTiming bool
+}
+
+// Configures the TraceResponseMiddleware.
+type TraceResponseOption func(*traceResponseConfig)
+
+// Changes the name of the header carrying the trace context.
+func WithTraceResponseHeader(name string) TraceResponseOption {
+	return func(c *traceResponseConfig) {
+		c.header = name
+	}
+}
+
+// Enables or disables the Server-Timing entry, enabled by default so browser
+// devtools display the trace ID next to the request timings.
+func WithServerTiming(enabled bool) TraceResponseOption {
+	return func(c *traceResponseConfig) {
+		c.serverTiming = enabled
+	}
+}
+
+// Middleware writing the current trace context into the response headers so
+// browser and CLI clients can correlate responses with backend traces. It
+// must be installed after the tracing middleware.
+func TraceResponseMiddleware(opts ...TraceResponseOption) func(http.Handler) http.Handler {
+	cfg := traceResponseConfig{header: DefaultTraceResponseHeader, serverTiming: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sc := trace.SpanContextFromContext(r.Context())
+			if sc.IsValid() {
+				value := fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+				h := w.Header()
+				h.Set(cfg.header, value)
+				h.Add("Access-Control-Expose-Headers", cfg.header)
+				if cfg.serverTiming {
+					h.Add("Server-Timing", fmt.Sprintf("traceparent;desc=%q", value))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
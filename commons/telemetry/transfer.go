@@ -0,0 +1,98 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the span events recorded during long transfers.
+const TransferProgressEvent = "transfer.progress"
+
+// Progress of a long transfer, reported on the span in its context: every
+// interval a transfer.progress event carries the bytes transferred so far and
+// the throughput since the previous event, and Finish sums the transfer up in
+// span attributes.
+type Transfer struct {
+	span     trace.Span
+	interval time.Duration
+
+	mu        sync.Mutex
+	start     time.Time
+	last      time.Time
+	bytes     int64
+	lastBytes int64
+}
+
+func newTransfer(ctx context.Context, interval time.Duration) *Transfer {
+	now := time.Now()
+	return &Transfer{span: trace.SpanFromContext(ctx), interval: interval, start: now, last: now}
+}
+
+func (t *Transfer) add(n int) {
+	if n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bytes += int64(n)
+	now := time.Now()
+	if elapsed := now.Sub(t.last); elapsed >= t.interval {
+		t.span.AddEvent(TransferProgressEvent, trace.WithAttributes(
+			attribute.Int64("transfer.bytes", t.bytes),
+			attribute.Float64("transfer.throughput", float64(t.bytes-t.lastBytes)/elapsed.Seconds()),
+		))
+		t.last, t.lastBytes = now, t.bytes
+	}
+}
+
+// Sets the total bytes, duration and average throughput (in bytes per
+// second) of the transfer on the span.
+func (t *Transfer) Finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elapsed := time.Since(t.start)
+	t.span.SetAttributes(
+		attribute.Int64("transfer.bytes_total", t.bytes),
+		attribute.Float64("transfer.duration", elapsed.Seconds()),
+		attribute.Float64("transfer.throughput_avg", float64(t.bytes)/elapsed.Seconds()),
+	)
+}
+
+// Reader reporting the progress of the reads of a long transfer.
+type TransferReader struct {
+	*Transfer
+	r io.Reader
+}
+
+// Wraps r, reporting progress on the span in ctx every interval.
+func NewTransferReader(ctx context.Context, r io.Reader, interval time.Duration) *TransferReader {
+	return &TransferReader{Transfer: newTransfer(ctx, interval), r: r}
+}
+
+func (t *TransferReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.add(n)
+	return n, err
+}
+
+// Writer reporting the progress of the writes of a long transfer.
+type TransferWriter struct {
+	*Transfer
+	w io.Writer
+}
+
+// Wraps w, reporting progress on the span in ctx every interval.
+func NewTransferWriter(ctx context.Context, w io.Writer, interval time.Duration) *TransferWriter {
+	return &TransferWriter{Transfer: newTransfer(ctx, interval), w: w}
+}
+
+func (t *TransferWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	t.add(n)
+	return n, err
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Keeps up to n idle connections per host, instead of the 2 of the standard
+// library, so bursts towards a single peer reuse connections.
+func WithMaxIdleConnsPerHost(n int) ClientOption {
+	return func(c *clientConfig) {
+		c.maxIdleConnsPerHost = n
+	}
+}
+
+// Closes the connections idle for longer than d.
+func WithIdleConnTimeout(d time.Duration) ClientOption {
+	return func(c *clientConfig) {
+		c.idleConnTimeout = d
+	}
+}
+
+// Negotiates HTTP/2 with TLS peers when enabled, or sticks to HTTP/1.1.
+func WithHTTP2(enabled bool) ClientOption {
+	return func(c *clientConfig) {
+		c.http2 = &enabled
+	}
+}
+
+// Returns a copy of the default transport tuned by the connection options,
+// for clients needing the transport itself, e.g. to instrument its pool.
+func NewTransport(opts ...ClientOption) *http.Transport {
+	var cfg clientConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg.tune(http.DefaultTransport.(*http.Transport))
+}
+
+func (c clientConfig) tuned() bool {
+	return c.maxIdleConnsPerHost > 0 || c.idleConnTimeout > 0 || c.http2 != nil
+}
+
+// Returns a copy of t with the connection options applied.
+func (c clientConfig) tune(t *http.Transport) *http.Transport {
+	t = t.Clone()
+	if c.maxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = c.maxIdleConnsPerHost
+		t.MaxIdleConns = max(t.MaxIdleConns, c.maxIdleConnsPerHost)
+	}
+	if c.idleConnTimeout > 0 {
+		t.IdleConnTimeout = c.idleConnTimeout
+	}
+	if c.http2 != nil {
+		t.ForceAttemptHTTP2 = *c.http2
+		if !*c.http2 {
+			// a non-nil empty map disables the HTTP/2 upgrade
+			t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+	}
+	return t
+}
+
+// Applies the connection options to base, which must be an *http.Transport
+// for them to take effect.
+func (c clientConfig) tuneBase(base http.RoundTripper) (http.RoundTripper, error) {
+	if !c.tuned() {
+		return base, nil
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return base, errors.New("ignoring the connection options of an HTTP client not based on *http.Transport")
+	}
+	return c.tune(t), nil
+}
@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Prefix of the collector endpoints naming a Unix domain socket, e.g.
+// unix:///var/run/otel/collector.sock for a sidecar collector. gRPC
+// exporters dial them natively, HTTP ones through unixHTTPClient.
+const unixEndpointPrefix = "unix://"
+
+// Upper bound of the connectivity self-check of a collector socket.
+const unixSocketCheckTimeout = 500 * time.Millisecond
+
+// Returns the socket path of a unix:// endpoint.
+func unixSocketPath(endpoint string) (string, bool) {
+	path, ok := strings.CutPrefix(endpoint, unixEndpointPrefix)
+	return path, ok && path != ""
+}
+
+// Dials the socket of a unix:// endpoint once, logging why it cannot be
+// reached, as a missing or stale socket otherwise only shows as failed
+// exports. Exports are still retried, the sidecar may start later.
+func checkUnixEndpoint(endpoint string) {
+	path, ok := unixSocketPath(endpoint)
+	if !ok {
+		return
+	}
+	conn, err := net.DialTimeout("unix", path, unixSocketCheckTimeout)
+	if err != nil {
+		log.Printf("telemetry: collector socket %s unreachable, exporting once it is up: %v", path, err)
+		return
+	}
+	_ = conn.Close()
+}
+
+// OTLP/HTTP trace client posting protobuf payloads to a collector listening
+// on a Unix domain socket, which the OTLP/HTTP exporter cannot dial.
+type unixHTTPClient struct {
+	client  *http.Client
+	headers map[string]string
+}
+
+func newUnixHTTPClient(path string, headers map[string]string, dialTimeout time.Duration) *unixHTTPClient {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	return &unixHTTPClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", path)
+				},
+				MaxIdleConns:    10,
+				IdleConnTimeout: 90 * time.Second,
+			},
+			Timeout: 10 * time.Second,
+		},
+		headers: headers,
+	}
+}
+
+func (c *unixHTTPClient) Start(context.Context) error { return nil }
+
+func (c *unixHTTPClient) Stop(context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *unixHTTPClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	body, err := proto.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if err != nil {
+		return err
+	}
+	// the host is ignored by the dialer
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector socket answered %s", resp.Status)
+	}
+	return nil
+}
+
+var _ otlptrace.Client = (*unixHTTPClient)(nil)
@@ -0,0 +1,327 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Misconfigured setting reported by ValidateConfig.
+type FieldError struct {
+	// Config field or environment variable at fault
+	Field string
+	// value provided
+	Value string
+	// values the setting accepts
+	Allowed string
+	// why the value was rejected, when Allowed alone does not tell
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	msg := fmt.Sprintf("%s=%q", e.Field, e.Value)
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+		if e.Allowed != "" {
+			msg += ", expected " + e.Allowed
+		}
+		return msg
+	}
+	return msg + ": expected " + e.Allowed
+}
+
+// Report of every misconfigured setting of a Config.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid telemetry config, %d issue(s):", len(e.Fields))
+	for _, f := range e.Fields {
+		b.WriteString("\n  ")
+		b.WriteString(f.Error())
+	}
+	return b.String()
+}
+
+// Checks cfg, built by NewConfig, and the environment InitProvider reads:
+// collector endpoint, sampler and its arguments, batch settings, sampling
+// ratios, span pipeline, service level objectives, and the aggregations and
+// views applied to the instruments registered in the manifest so far.
+// Returns a *ValidationError listing every misconfigured setting, nil when
+// there is none. InitProvider fails on the same checks instead of falling
+// back to defaults.
+func ValidateConfig(cfg Config) error {
+	var issues []FieldError
+	if cfg.ServiceName == "" {
+		issues = append(issues, FieldError{Field: "ServiceName", Allowed: "a non-empty service name"})
+	}
+	if endpoint, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT"); ok {
+		issues = append(issues, endpointIssues("OTEL_EXPORTER_OTLP_ENDPOINT", endpoint)...)
+	}
+	issues = append(issues, samplerIssues()...)
+	issues = append(issues, cfg.Batch.issues()...)
+	issues = append(issues, ratioIssues(cfg)...)
+	issues = append(issues, pipelineIssues(cfg.SpanPipeline)...)
+	issues = append(issues, sloIssues(cfg.SLOs)...)
+	issues = append(issues, metricIssues(cfg)...)
+	if len(issues) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: issues}
+}
+
+// Checks a collector endpoint as dialed by the gRPC exporters.
+func endpointIssues(field, endpoint string) []FieldError {
+	allowed := "host:port, dns:///host:port or unix:///path/to/socket"
+	if strings.HasPrefix(endpoint, unixEndpointPrefix) {
+		if _, ok := unixSocketPath(endpoint); !ok {
+			return []FieldError{{Field: field, Value: endpoint, Allowed: allowed, Reason: "missing socket path"}}
+		}
+		return nil
+	}
+	hostPort := strings.TrimPrefix(endpoint, "dns:///")
+	if strings.Contains(hostPort, "://") {
+		return []FieldError{{Field: field, Value: endpoint, Allowed: allowed, Reason: "gRPC endpoints take no URL scheme"}}
+	}
+	_, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return []FieldError{{Field: field, Value: endpoint, Allowed: allowed, Reason: "missing port"}}
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return []FieldError{{Field: field, Value: endpoint, Allowed: allowed, Reason: "invalid port"}}
+	}
+	return nil
+}
+
+// Checks the environment GetSampler and JaegerRemoteFromEnv read, which
+// services also call to build their own samplers.
+func samplerIssues() []FieldError {
+	var issues []FieldError
+	switch env := os.Getenv("GO_ENV"); env {
+	case "", "development", "production":
+	default:
+		issues = append(issues, FieldError{Field: "GO_ENV", Value: env, Allowed: "development or production"})
+	}
+	switch sampler := os.Getenv("OTEL_TRACES_SAMPLER"); sampler {
+	case "":
+	case "jaeger_remote":
+		c, argIssues := parseJaegerRemoteArgs(os.Getenv("OTEL_TRACES_SAMPLER_ARG"))
+		issues = append(issues, argIssues...)
+		if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			issues = append(issues, FieldError{
+				Field:   "OTEL_TRACES_SAMPLER_ARG",
+				Value:   "endpoint=" + c.Endpoint,
+				Allowed: "an http:// or https:// sampling endpoint URL",
+			})
+		}
+	default:
+		issues = append(issues, FieldError{
+			Field:   "OTEL_TRACES_SAMPLER",
+			Value:   sampler,
+			Allowed: "jaeger_remote, or unset to sample according to GO_ENV",
+		})
+	}
+	return issues
+}
+
+// Checks the sampling ratios of cfg lie within [0, 1].
+func ratioIssues(cfg Config) []FieldError {
+	var issues []FieldError
+	check := func(field string, ratio float64) {
+		if math.IsNaN(ratio) || ratio < 0 || ratio > 1 {
+			issues = append(issues, FieldError{Field: field, Value: strconv.FormatFloat(ratio, 'g', -1, 64), Allowed: "a ratio within [0, 1]"})
+		}
+	}
+	if cfg.DegradedPeriod > 0 {
+		check("DegradedSamplingRatio", cfg.DegradedSamplingRatio)
+	}
+	for _, tenant := range sortedKeys(cfg.TenantSampling) {
+		check("TenantSampling["+tenant+"]", cfg.TenantSampling[tenant])
+	}
+	if cfg.LogSampling != nil {
+		for _, severity := range sortedKeys(cfg.LogSampling.Ratios) {
+			check("LogSampling.Ratios["+severity+"]", cfg.LogSampling.Ratios[severity])
+		}
+	}
+	if cfg.SpanRateLimit < 0 {
+		issues = append(issues, FieldError{Field: "SpanRateLimit", Value: strconv.FormatFloat(cfg.SpanRateLimit, 'g', -1, 64), Allowed: "a positive rate, or 0 for no limit"})
+	}
+	return issues
+}
+
+// Checks the objectives have distinct names, an objective within (0, 1) and
+// valid span patterns.
+func sloIssues(slos []SLO) []FieldError {
+	var issues []FieldError
+	seen := map[string]bool{}
+	for i, slo := range slos {
+		field := fmt.Sprintf("SLOs[%d]", i)
+		if slo.Name == "" || seen[slo.Name] {
+			issues = append(issues, FieldError{Field: field + ".Name", Value: slo.Name, Allowed: "a unique non-empty name"})
+		}
+		seen[slo.Name] = true
+		if math.IsNaN(slo.Objective) || slo.Objective <= 0 || slo.Objective >= 1 {
+			issues = append(issues, FieldError{Field: field + ".Objective", Value: strconv.FormatFloat(slo.Objective, 'g', -1, 64), Allowed: "a ratio within (0, 1), e.g. 0.999"})
+		}
+		if slo.Latency < 0 {
+			issues = append(issues, FieldError{Field: field + ".Latency", Value: slo.Latency.String(), Allowed: "a positive duration, or 0 not to judge latency"})
+		}
+		for _, pattern := range slo.Spans {
+			if _, err := path.Match(pattern, ""); err != nil {
+				issues = append(issues, FieldError{Field: field + ".Spans", Value: pattern, Allowed: "a path.Match pattern", Reason: err.Error()})
+			}
+		}
+	}
+	return issues
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Builds each processor of the pipeline on its own, reporting unknown ones
+// and invalid parameters.
+func pipelineIssues(specs []ProcessorSpec) []FieldError {
+	var issues []FieldError
+	for i, spec := range specs {
+		field := fmt.Sprintf("SpanPipeline[%d]", i)
+		factory, ok := spanProcessorFactory(spec.Name)
+		if !ok {
+			issues = append(issues, FieldError{Field: field, Value: spec.Name, Allowed: strings.Join(SpanProcessorNames(), ", ")})
+			continue
+		}
+		p, err := factory(noopSpanProcessor{}, spec.Params)
+		if err != nil {
+			issues = append(issues, FieldError{Field: field, Value: formatParams(spec), Reason: err.Error()})
+			continue
+		}
+		_ = p.Shutdown(context.Background())
+	}
+	return issues
+}
+
+// Formats spec the way ParseSpanPipeline reads it.
+func formatParams(spec ProcessorSpec) string {
+	params := make([]string, 0, len(spec.Params))
+	for _, key := range sortedKeys(spec.Params) {
+		params = append(params, key+"="+spec.Params[key])
+	}
+	if len(params) == 0 {
+		return spec.Name
+	}
+	return spec.Name + ":" + strings.Join(params, ",")
+}
+
+// Span processor discarding every span, ending the pipelines built for
+// validation.
+type noopSpanProcessor struct{}
+
+func (noopSpanProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (noopSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (noopSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (noopSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// Instrument kinds of the manifest descriptors.
+var manifestKinds = map[string]sdkmetric.InstrumentKind{
+	"counter":       sdkmetric.InstrumentKindCounter,
+	"updowncounter": sdkmetric.InstrumentKindUpDownCounter,
+	"histogram":     sdkmetric.InstrumentKindHistogram,
+	"gauge":         sdkmetric.InstrumentKindGauge,
+}
+
+// Checks the default aggregations, and applies the views to the instruments
+// registered in the manifest, reporting the invalid aggregations the SDK
+// would silently replace and the streams renamed onto one another.
+func metricIssues(cfg Config) []FieldError {
+	var issues []FieldError
+	if cfg.Aggregation != nil {
+		for _, kind := range manifestKinds {
+			if reason := aggregationIssue(cfg.Aggregation(kind)); reason != "" {
+				issues = append(issues, FieldError{Field: "Aggregation", Value: kind.String(), Reason: reason})
+			}
+		}
+		slices.SortFunc(issues, func(a, b FieldError) int { return strings.Compare(a.Value, b.Value) })
+	}
+
+	registry.mu.RLock()
+	instruments := make([]sdkmetric.Instrument, 0, len(registry.metrics))
+	for _, d := range registry.metrics {
+		instruments = append(instruments, sdkmetric.Instrument{Name: d.Name, Description: d.Description, Unit: d.Unit, Kind: manifestKinds[d.Kind]})
+	}
+	registry.mu.RUnlock()
+	slices.SortFunc(instruments, func(a, b sdkmetric.Instrument) int { return strings.Compare(a.Name, b.Name) })
+
+	streams := map[string]string{}
+	for _, inst := range instruments {
+		streams[inst.Name] = inst.Name
+	}
+	for i, view := range cfg.MetricViews {
+		field := fmt.Sprintf("MetricViews[%d]", i)
+		for _, inst := range instruments {
+			s, ok := view(inst)
+			if !ok {
+				continue
+			}
+			if reason := aggregationIssue(s.Aggregation); reason != "" {
+				issues = append(issues, FieldError{Field: field, Value: inst.Name, Reason: reason})
+			}
+			if s.Name == "" || s.Name == inst.Name {
+				continue
+			}
+			if other, taken := streams[s.Name]; taken && other != inst.Name {
+				issues = append(issues, FieldError{
+					Field:   field,
+					Value:   inst.Name + " renamed " + s.Name,
+					Allowed: "a name no other instrument exports",
+					Reason:  other + " already exports a stream named " + s.Name,
+				})
+				continue
+			}
+			streams[s.Name] = inst.Name
+		}
+	}
+	return issues
+}
+
+// Returns why the SDK would reject aggregation, "" when it is valid.
+func aggregationIssue(aggregation sdkmetric.Aggregation) string {
+	switch a := aggregation.(type) {
+	case sdkmetric.AggregationExplicitBucketHistogram:
+		for i, b := range a.Boundaries {
+			if math.IsNaN(b) || math.IsInf(b, 0) {
+				return fmt.Sprintf("histogram boundaries %v are not finite", a.Boundaries)
+			}
+			if i > 0 && b <= a.Boundaries[i-1] {
+				return fmt.Sprintf("histogram boundaries %v are not strictly increasing", a.Boundaries)
+			}
+		}
+	case sdkmetric.AggregationBase2ExponentialHistogram:
+		if a.MaxScale > 20 || a.MaxScale < -10 {
+			return fmt.Sprintf("exponential histogram max scale %d is outside [-10, 20]", a.MaxScale)
+		}
+		if a.MaxSize <= 0 {
+			return fmt.Sprintf("exponential histogram max size %d is not positive", a.MaxSize)
+		}
+	}
+	return ""
+}
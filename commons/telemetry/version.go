@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"runtime/debug"
+)
+
+// Service version stamped at build time, e.g.
+//
+//	go build -ldflags "-X github.com/sosalejandro/otel-example/commons/telemetry.Version=v1.2.3"
+var Version string
+
+// Returns the version of the running binary: the stamped Version when set,
+// otherwise the main module version or the VCS revision recorded by the Go
+// toolchain.
+func BuildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,81 @@
+package telemetry
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Attributes dropped from every metric unless a view keeps them, their
+// cardinality being unbounded.
+var highCardinalityKeys = []attribute.Key{semconv.URLFullKey}
+
+// Customizes metric streams: renaming instruments, changing histogram
+// boundaries or dropping attributes. For every instrument, the first
+// matching view wins; url.full is dropped from the streams of views setting
+// no attribute filter, and from those of instruments no view matches.
+func WithMetricViews(views ...sdkmetric.View) Option {
+	return func(c *Config) {
+		c.MetricViews = append(c.MetricViews, views...)
+	}
+}
+
+// Renames the instrument called from to to.
+func RenameInstrument(from, to string) sdkmetric.View {
+	return sdkmetric.NewView(sdkmetric.Instrument{Name: from}, sdkmetric.Stream{Name: to})
+}
+
+// Uses the given bucket boundaries for the histograms matching name, which
+// may contain * and ? wildcards.
+func HistogramBoundaries(name string, boundaries ...float64) sdkmetric.View {
+	match := sdkmetric.NewView(sdkmetric.Instrument{Name: name, Kind: sdkmetric.InstrumentKindHistogram}, sdkmetric.Stream{})
+	aggregation := sdkmetric.AggregationExplicitBucketHistogram{Boundaries: boundaries}
+	// set past NewView, which silently drops invalid boundaries, so that
+	// ValidateConfig reports them
+	return func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		s, ok := match(i)
+		if ok {
+			s.Aggregation = aggregation
+		}
+		return s, ok
+	}
+}
+
+// Drops the given attributes from the metrics matching name, which may
+// contain * and ? wildcards.
+func DropAttributes(name string, keys ...attribute.Key) sdkmetric.View {
+	keys = append(keys[:len(keys):len(keys)], highCardinalityKeys...)
+	return sdkmetric.NewView(sdkmetric.Instrument{Name: name}, sdkmetric.Stream{AttributeFilter: attribute.NewDenyKeysFilter(keys...)})
+}
+
+// Merges views into the single view given to the meter provider, the SDK
+// otherwise exporting one stream per matching view. The attributes listed in
+// drops, when set, are dropped too.
+func mergeViews(views []sdkmetric.View, drops *attributeDrops) sdkmetric.View {
+	return func(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		s := sdkmetric.Stream{Name: i.Name, Description: i.Description, Unit: i.Unit}
+		for _, view := range views {
+			if vs, ok := view(i); ok {
+				s = vs
+				break
+			}
+		}
+		if s.AttributeFilter == nil {
+			s.AttributeFilter = attribute.NewDenyKeysFilter(highCardinalityKeys...)
+		}
+		if drops != nil {
+			s.AttributeFilter = drops.filter(i.Name, s.AttributeFilter)
+		}
+		return s, true
+	}
+}
+
+// Aggregates histograms without boundaries of their own into buckets suited
+// to sub-second HTTP services, instead of the SDK buckets meant for
+// milliseconds.
+func defaultAggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	if kind == sdkmetric.InstrumentKindHistogram {
+		return sdkmetric.AggregationExplicitBucketHistogram{Boundaries: latencyBoundaries}
+	}
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
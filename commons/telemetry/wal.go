@@ -0,0 +1,377 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Writes the spans the collector did not acknowledge to a write-ahead log
+// under dir, so they survive collector restarts and process crashes. They
+// are replayed, oldest first, before the next batches are exported. The log
+// is capped to maxBytes, later batches being dropped while it is full.
+func WithPersistentQueue(dir string, maxBytes int64) Option {
+	return func(c *Config) {
+		c.QueueDir = dir
+		c.QueueMaxBytes = maxBytes
+	}
+}
+
+// Name of the write-ahead log within the queue directory.
+const walFileName = "spans.wal"
+
+// Span exporter persisting the batches next failed to export until it
+// acknowledges them.
+type persistentExporter struct {
+	next     sdktrace.SpanExporter
+	path     string
+	maxBytes int64
+
+	mu sync.Mutex
+	// whether the log holds batches, left by a previous run or failed
+	// exports
+	pending bool
+}
+
+func newPersistentExporter(dir string, maxBytes int64, next sdktrace.SpanExporter) (*persistentExporter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	e := &persistentExporter{next: next, path: filepath.Join(dir, walFileName), maxBytes: maxBytes}
+	if info, err := os.Stat(e.path); err == nil {
+		e.pending = info.Size() > 0
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Exports the batch, first replaying the logged batches, and logs it when
+// the export fails. Failures are still returned, so they are reported and
+// counted; the spans they carry are only lost when the log is full.
+func (e *persistentExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var err error
+	if e.pending {
+		err = e.replay(ctx)
+	}
+	if err == nil {
+		if err = e.next.ExportSpans(ctx, spans); err == nil {
+			return nil
+		}
+	}
+	if logErr := e.append(spans); logErr != nil {
+		return errors.Join(err, logErr)
+	}
+	e.pending = true
+	return fmt.Errorf("spans kept in %s for a later export: %w", e.path, err)
+}
+
+func (e *persistentExporter) append(spans []sdktrace.ReadOnlySpan) error {
+	line, err := json.Marshal(newWALBatch(spans))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(e.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if e.maxBytes > 0 && info.Size()+int64(len(line)) > e.maxBytes {
+		return fmt.Errorf("persistent span queue %s is full, dropping %d spans", e.path, len(spans))
+	}
+	if _, err := f.Write(line); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Exports the logged batches in order, stopping at the first failure. The
+// batches exported are removed from the log, the others kept.
+func (e *persistentExporter) replay(ctx context.Context) error {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	consumed := false
+	for {
+		line, readErr := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var batch walBatch
+			if err := json.Unmarshal(line, &batch); err != nil {
+				// a crash may have cut the last write
+				otel.Handle(fmt.Errorf("dropping corrupted span batch from %s: %w", e.path, err))
+			} else if err := e.next.ExportSpans(ctx, batch.spans()); err != nil {
+				if !consumed {
+					return err
+				}
+				return e.rewrite(io.MultiReader(bytes.NewReader(line), r), err)
+			}
+			consumed = true
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	e.pending = false
+	return os.Truncate(e.path, 0)
+}
+
+// Replaces the log with the batches read from rest.
+func (e *persistentExporter) rewrite(rest io.Reader, cause error) error {
+	tmp := e.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+	_, err = io.Copy(f, rest)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+	return errors.Join(cause, os.Rename(tmp, e.path))
+}
+
+func (e *persistentExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.next.Shutdown(ctx)
+}
+
+// Batch of spans as written to the log. Spans of a batch share their
+// resource.
+type walBatch struct {
+	Resource  []walAttr `json:"resource"`
+	SchemaURL string    `json:"schema_url,omitempty"`
+	Spans     []walSpan `json:"spans"`
+}
+
+type walSpan struct {
+	Name       string                `json:"name"`
+	Context    walSpanContext        `json:"context"`
+	Parent     walSpanContext        `json:"parent"`
+	Kind       trace.SpanKind        `json:"kind"`
+	Start      time.Time             `json:"start"`
+	End        time.Time             `json:"end"`
+	Attributes []walAttr             `json:"attributes,omitempty"`
+	Events     []walEvent            `json:"events,omitempty"`
+	Links      []walLink             `json:"links,omitempty"`
+	StatusCode codes.Code            `json:"status_code"`
+	StatusDesc string                `json:"status_description,omitempty"`
+	Dropped    [3]int                `json:"dropped"`
+	ChildSpans int                   `json:"child_spans"`
+	Scope      instrumentation.Scope `json:"scope"`
+}
+
+type walSpanContext struct {
+	TraceID    string `json:"trace_id,omitempty"`
+	SpanID     string `json:"span_id,omitempty"`
+	Flags      byte   `json:"flags"`
+	TraceState string `json:"trace_state,omitempty"`
+	Remote     bool   `json:"remote,omitempty"`
+}
+
+type walEvent struct {
+	Name       string    `json:"name"`
+	Time       time.Time `json:"time"`
+	Attributes []walAttr `json:"attributes,omitempty"`
+}
+
+type walLink struct {
+	Context    walSpanContext `json:"context"`
+	Attributes []walAttr      `json:"attributes,omitempty"`
+}
+
+type walAttr struct {
+	Key   string          `json:"k"`
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+func newWALBatch(spans []sdktrace.ReadOnlySpan) walBatch {
+	var b walBatch
+	if len(spans) > 0 && spans[0].Resource() != nil {
+		b.Resource = newWALAttrs(spans[0].Resource().Attributes())
+		b.SchemaURL = spans[0].Resource().SchemaURL()
+	}
+	for _, s := range spans {
+		ws := walSpan{
+			Name:       s.Name(),
+			Context:    newWALSpanContext(s.SpanContext()),
+			Parent:     newWALSpanContext(s.Parent()),
+			Kind:       s.SpanKind(),
+			Start:      s.StartTime(),
+			End:        s.EndTime(),
+			Attributes: newWALAttrs(s.Attributes()),
+			StatusCode: s.Status().Code,
+			StatusDesc: s.Status().Description,
+			Dropped:    [3]int{s.DroppedAttributes(), s.DroppedEvents(), s.DroppedLinks()},
+			ChildSpans: s.ChildSpanCount(),
+			Scope:      s.InstrumentationScope(),
+		}
+		for _, ev := range s.Events() {
+			ws.Events = append(ws.Events, walEvent{Name: ev.Name, Time: ev.Time, Attributes: newWALAttrs(ev.Attributes)})
+		}
+		for _, l := range s.Links() {
+			ws.Links = append(ws.Links, walLink{Context: newWALSpanContext(l.SpanContext), Attributes: newWALAttrs(l.Attributes)})
+		}
+		b.Spans = append(b.Spans, ws)
+	}
+	return b
+}
+
+// Rebuilds the spans of the batch.
+func (b walBatch) spans() []sdktrace.ReadOnlySpan {
+	res := resource.NewWithAttributes(b.SchemaURL, walAttrs(b.Resource)...)
+	spans := make([]sdktrace.ReadOnlySpan, 0, len(b.Spans))
+	for _, ws := range b.Spans {
+		span := &spanSnapshot{
+			name:              ws.Name,
+			spanContext:       ws.Context.spanContext(),
+			parent:            ws.Parent.spanContext(),
+			kind:              ws.Kind,
+			start:             ws.Start,
+			end:               ws.End,
+			attributes:        walAttrs(ws.Attributes),
+			status:            sdktrace.Status{Code: ws.StatusCode, Description: ws.StatusDesc},
+			droppedAttributes: ws.Dropped[0],
+			droppedEvents:     ws.Dropped[1],
+			droppedLinks:      ws.Dropped[2],
+			childSpanCount:    ws.ChildSpans,
+			resource:          res,
+			scope:             ws.Scope,
+		}
+		for _, ev := range ws.Events {
+			span.events = append(span.events, sdktrace.Event{Name: ev.Name, Time: ev.Time, Attributes: walAttrs(ev.Attributes)})
+		}
+		for _, l := range ws.Links {
+			span.links = append(span.links, sdktrace.Link{SpanContext: l.Context.spanContext(), Attributes: walAttrs(l.Attributes)})
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func newWALSpanContext(sc trace.SpanContext) walSpanContext {
+	if !sc.IsValid() {
+		return walSpanContext{}
+	}
+	return walSpanContext{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		Flags:      byte(sc.TraceFlags()),
+		TraceState: sc.TraceState().String(),
+		Remote:     sc.IsRemote(),
+	}
+}
+
+func (c walSpanContext) spanContext() trace.SpanContext {
+	if c.TraceID == "" {
+		return trace.SpanContext{}
+	}
+	traceID, _ := trace.TraceIDFromHex(c.TraceID)
+	spanID, _ := trace.SpanIDFromHex(c.SpanID)
+	state, _ := trace.ParseTraceState(c.TraceState)
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.TraceFlags(c.Flags),
+		TraceState: state,
+		Remote:     c.Remote,
+	})
+}
+
+func newWALAttrs(kvs []attribute.KeyValue) []walAttr {
+	attrs := make([]walAttr, 0, len(kvs))
+	for _, kv := range kvs {
+		v, err := json.Marshal(kv.Value.AsInterface())
+		if err != nil {
+			continue
+		}
+		attrs = append(attrs, walAttr{Key: string(kv.Key), Type: kv.Value.Type().String(), Value: v})
+	}
+	return attrs
+}
+
+func walAttrs(attrs []walAttr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		if kv, ok := a.keyValue(); ok {
+			kvs = append(kvs, kv)
+		}
+	}
+	return kvs
+}
+
+func (a walAttr) keyValue() (attribute.KeyValue, bool) {
+	k := attribute.Key(a.Key)
+	var err error
+	switch a.Type {
+	case "BOOL":
+		var v bool
+		err = json.Unmarshal(a.Value, &v)
+		return k.Bool(v), err == nil
+	case "INT64":
+		var v int64
+		err = json.Unmarshal(a.Value, &v)
+		return k.Int64(v), err == nil
+	case "FLOAT64":
+		var v float64
+		err = json.Unmarshal(a.Value, &v)
+		return k.Float64(v), err == nil
+	case "STRING":
+		var v string
+		err = json.Unmarshal(a.Value, &v)
+		return k.String(v), err == nil
+	case "BOOLSLICE":
+		var v []bool
+		err = json.Unmarshal(a.Value, &v)
+		return k.BoolSlice(v), err == nil
+	case "INT64SLICE":
+		var v []int64
+		err = json.Unmarshal(a.Value, &v)
+		return k.Int64Slice(v), err == nil
+	case "FLOAT64SLICE":
+		var v []float64
+		err = json.Unmarshal(a.Value, &v)
+		return k.Float64Slice(v), err == nil
+	case "STRINGSLICE":
+		var v []string
+		err = json.Unmarshal(a.Value, &v)
+		return k.StringSlice(v), err == nil
+	}
+	return attribute.KeyValue{}, false
+}
@@ -0,0 +1,147 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Records the batches it exports, failing while down.
+type flakyExporter struct {
+	down bool
+	// exports succeeding before going down, when positive
+	upFor   int
+	calls   int
+	batches [][]string
+}
+
+func (e *flakyExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.calls++
+	if e.upFor > 0 {
+		e.upFor--
+		e.down = e.upFor == 0
+	} else if e.down {
+		return errors.New("collector unavailable")
+	}
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	e.batches = append(e.batches, names)
+	return nil
+}
+
+func (e *flakyExporter) Shutdown(context.Context) error { return nil }
+
+func TestPersistentExporterReplaysFailedBatches(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	next := &flakyExporter{}
+	exp, err := newPersistentExporter(dir, 0, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := func(name string) []sdktrace.ReadOnlySpan {
+		return tracetest.SpanStubs{{Name: name}}.Snapshots()
+	}
+
+	if err := exp.ExportSpans(ctx, batch("a")); err != nil {
+		t.Fatalf("export with the collector up: %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, walFileName)); info != nil && info.Size() > 0 {
+		t.Errorf("exported batch logged")
+	}
+
+	next.down = true
+	for _, name := range []string{"b", "c"} {
+		if err := exp.ExportSpans(ctx, batch(name)); err == nil {
+			t.Fatalf("export of %s with the collector down succeeded, want its failure", name)
+		}
+	}
+	// the logged batch is tried once, the new one is queued behind it
+	if next.calls != 3 {
+		t.Errorf("next called %d times, want 3", next.calls)
+	}
+
+	// the collector goes down again after replaying b, c is kept
+	next.down, next.upFor = false, 1
+	if err := exp.ExportSpans(ctx, batch("d")); err == nil {
+		t.Fatal("export with the collector down again succeeded, want its failure")
+	}
+
+	// a new exporter picks up the log left by the previous run
+	next.down = false
+	exp, err = newPersistentExporter(dir, 0, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(ctx, batch("e")); err != nil {
+		t.Fatalf("export once the collector is back: %v", err)
+	}
+	if err := exp.ExportSpans(ctx, batch("f")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "b", "c", "d", "e", "f"}
+	if len(next.batches) != len(want) {
+		t.Fatalf("exported batches %v, want %v, each once", next.batches, want)
+	}
+	for i, b := range next.batches {
+		if len(b) != 1 || b[0] != want[i] {
+			t.Fatalf("exported batches %v, want %v, each once", next.batches, want)
+		}
+	}
+}
+
+func TestPersistentExporterFullQueue(t *testing.T) {
+	next := &flakyExporter{down: true}
+	exp, err := newPersistentExporter(t.TempDir(), 1, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = exp.ExportSpans(context.Background(), tracetest.SpanStubs{{Name: "a"}}.Snapshots())
+	if err == nil || exp.pending {
+		t.Errorf("export to a full queue: error %v, pending %t, want an error and nothing logged", err, exp.pending)
+	}
+}
+
+func TestWALBatchRoundTrip(t *testing.T) {
+	traceID, spanID, parentID := trace.TraceID{1}, trace.SpanID{2}, trace.SpanID{3}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+	parent := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: parentID, Remote: true})
+	res := resource.NewSchemaless(attribute.String("service.name", "wal-test"))
+	stub := tracetest.SpanStub{
+		Name:        "GET /packages/{id}",
+		SpanContext: sc,
+		Parent:      parent,
+		SpanKind:    trace.SpanKindServer,
+		Attributes:  []attribute.KeyValue{attribute.Int64("http.status_code", 200), attribute.StringSlice("tags", []string{"a", "b"})},
+		Events:      []sdktrace.Event{{Name: "retry", Attributes: []attribute.KeyValue{attribute.Bool("final", true)}}},
+		Links:       []sdktrace.Link{{SpanContext: parent, Attributes: []attribute.KeyValue{attribute.Float64("weight", 0.5)}}},
+		Resource:    res,
+	}
+
+	got := newWALBatch(tracetest.SpanStubs{stub}.Snapshots()).spans()[0]
+	if got.Name() != stub.Name || !got.SpanContext().Equal(sc) || !got.Parent().Equal(parent) || got.SpanKind() != stub.SpanKind {
+		t.Errorf("span identity not kept: %s %s %s %s", got.Name(), got.SpanContext().SpanID(), got.Parent().SpanID(), got.SpanKind())
+	}
+	if a, b := attribute.NewSet(got.Attributes()...), attribute.NewSet(stub.Attributes...); !a.Equals(&b) {
+		t.Errorf("attributes = %v, want %v", got.Attributes(), stub.Attributes)
+	}
+	if ev := got.Events(); len(ev) != 1 || ev[0].Name != "retry" || len(ev[0].Attributes) != 1 {
+		t.Errorf("events = %v", ev)
+	}
+	if l := got.Links(); len(l) != 1 || !l[0].SpanContext.Equal(parent) || l[0].Attributes[0] != stub.Links[0].Attributes[0] {
+		t.Errorf("links = %v", l)
+	}
+	if !got.Resource().Equal(res) {
+		t.Errorf("resource = %v, want %v", got.Resource(), res)
+	}
+}
@@ -0,0 +1,96 @@
+// Package zapotel correlates zap logs with traces: entries logged through
+// Logger carry the trace_id and span_id of the span in the context, and
+// those of error level or above are mirrored as span events.
+package zapotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Keys of the correlation fields.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// Attributes of the log span event.
+const (
+	LogSeverityKey = attribute.Key("log.severity")
+	LogMessageKey  = attribute.Key("log.message")
+)
+
+// Returns l correlated with the span of ctx, or l itself when ctx carries
+// no valid span.
+//
+//	zapotel.Logger(r.Context(), logger).Info("stock checked", zap.String("id", id))
+func Logger(ctx context.Context, l *zap.Logger) *zap.Logger {
+	span := trace.SpanFromContext(ctx)
+	if !span.SpanContext().IsValid() {
+		return l
+	}
+	return l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &spanCore{Core: core, span: span}
+	})).With(Fields(ctx)...)
+}
+
+// Returns the trace_id and span_id fields of the span of ctx, none when it
+// carries no valid span.
+func Fields(ctx context.Context) []zap.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []zap.Field{
+		zap.String(TraceIDKey, sc.TraceID().String()),
+		zap.String(SpanIDKey, sc.SpanID().String()),
+	}
+}
+
+// Core adding the entries of error level or above to span as events.
+type spanCore struct {
+	zapcore.Core
+	span trace.Span
+	// fields added by With, whose error is reported with the event
+	fields []zapcore.Field
+}
+
+func (c *spanCore) With(fields []zapcore.Field) zapcore.Core {
+	return &spanCore{
+		Core:   c.Core.With(fields),
+		span:   c.span,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+	}
+}
+
+func (c *spanCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *spanCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	if e.Level >= zapcore.ErrorLevel && c.span.IsRecording() {
+		attrs := []attribute.KeyValue{
+			LogSeverityKey.String(e.Level.CapitalString()),
+			LogMessageKey.String(e.Message),
+		}
+		for _, f := range append(c.fields[:len(c.fields):len(c.fields)], fields...) {
+			if err, ok := f.Interface.(error); ok && f.Type == zapcore.ErrorType {
+				attrs = append(attrs, semconv.ExceptionMessage(err.Error()))
+			}
+		}
+		c.span.AddEvent("log", trace.WithAttributes(attrs...))
+		if e.Level >= zapcore.DPanicLevel {
+			c.span.SetStatus(codes.Error, e.Message)
+		}
+	}
+	return c.Core.Write(e, fields)
+}
@@ -0,0 +1,58 @@
+// Package zerootel correlates zerolog logs with traces: events of a logger
+// with Hook carry the trace_id and span_id of the span in their context, and
+// those of error level or above are mirrored as span events.
+package zerootel
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the correlation fields.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// Attributes of the log span event.
+const (
+	LogSeverityKey = attribute.Key("log.severity")
+	LogMessageKey  = attribute.Key("log.message")
+)
+
+// Hook correlating the events given a context, through Event.Ctx or a
+// logger returned by Logger, with its span.
+//
+//	logger := zerolog.New(os.Stderr).Hook(zerootel.Hook{})
+//	logger.Info().Ctx(ctx).Msg("received")
+type Hook struct{}
+
+func (Hook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	span := trace.SpanFromContext(e.GetCtx())
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	e.Str(TraceIDKey, sc.TraceID().String()).Str(SpanIDKey, sc.SpanID().String())
+	if level < zerolog.ErrorLevel || level == zerolog.NoLevel || !span.IsRecording() {
+		return
+	}
+	span.AddEvent("log", trace.WithAttributes(
+		LogSeverityKey.String(strings.ToUpper(level.String())),
+		LogMessageKey.String(msg),
+	))
+	if level >= zerolog.FatalLevel {
+		span.SetStatus(codes.Error, msg)
+	}
+}
+
+// Returns l, which must not have Hook already, with Hook and its events
+// correlated with the span of ctx.
+func Logger(ctx context.Context, l zerolog.Logger) zerolog.Logger {
+	return l.With().Ctx(ctx).Logger().Hook(Hook{})
+}
@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/zpages"
+)
+
+// In-process view of the recent, running and errored spans, letting
+// developers inspect traces without any backend.
+type ZPages struct {
+	processor *zpages.SpanProcessor
+}
+
+func NewZPages() *ZPages {
+	return &ZPages{processor: zpages.NewSpanProcessor()}
+}
+
+// Serves the tracez page, e.g. at /debug/tracez.
+func (z *ZPages) Handler() http.Handler {
+	return zpages.NewTracezHandler(z.processor)
+}
+
+// Records spans into z, nil disabling zpages.
+func WithZPages(z *ZPages) Option {
+	return func(c *Config) {
+		c.ZPages = z
+	}
+}
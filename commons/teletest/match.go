@@ -0,0 +1,177 @@
+package teletest
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Expectation on the recorded traces.
+type Matcher interface {
+	// returns an error describing the recorded traces when none matches
+	Match(spans tracetest.SpanStubs) error
+}
+
+// Expected trace, built from its root span down.
+type TraceMatcher struct {
+	root *SpanMatcher
+}
+
+// Starts the expectation of a trace.
+func ExpectTrace() *TraceMatcher {
+	return &TraceMatcher{}
+}
+
+// Expects the trace root span to be named name, which may be a path.Match
+// pattern such as "GET /packages/*".
+func (m *TraceMatcher) Root(name string) *SpanMatcher {
+	m.root = &SpanMatcher{trace: m, name: name}
+	return m.root
+}
+
+// Expected span of a trace.
+type SpanMatcher struct {
+	trace    *TraceMatcher
+	parent   *SpanMatcher
+	name     string
+	kind     trace.SpanKind
+	status   codes.Code
+	attrs    []attribute.KeyValue
+	children []*SpanMatcher
+}
+
+// Expects a child span named name, returning its matcher.
+func (m *SpanMatcher) Child(name string) *SpanMatcher {
+	child := &SpanMatcher{trace: m.trace, parent: m, name: name}
+	m.children = append(m.children, child)
+	return child
+}
+
+// Expects the span to carry the given attributes.
+func (m *SpanMatcher) WithAttr(attrs ...attribute.KeyValue) *SpanMatcher {
+	m.attrs = append(m.attrs, attrs...)
+	return m
+}
+
+// Expects the span to be of the given kind.
+func (m *SpanMatcher) WithKind(kind trace.SpanKind) *SpanMatcher {
+	m.kind = kind
+	return m
+}
+
+// Expects the span to end with the given status code. Unset matches any.
+func (m *SpanMatcher) WithStatus(code codes.Code) *SpanMatcher {
+	m.status = code
+	return m
+}
+
+// Returns the matcher of the parent span, to expect siblings, e.g.
+//
+//	ExpectTrace().Root("a").Child("b").Parent().Child("c")
+func (m *SpanMatcher) Parent() *SpanMatcher {
+	if m.parent == nil {
+		return m
+	}
+	return m.parent
+}
+
+// Matches the whole trace the span belongs to.
+func (m *SpanMatcher) Match(spans tracetest.SpanStubs) error {
+	return m.trace.Match(spans)
+}
+
+func (m *TraceMatcher) Match(spans tracetest.SpanStubs) error {
+	if m.root == nil {
+		return fmt.Errorf("trace expectation without root")
+	}
+	children := map[trace.SpanID][]tracetest.SpanStub{}
+	known := map[trace.SpanID]bool{}
+	for _, s := range spans {
+		known[s.SpanContext.SpanID()] = true
+	}
+	var roots []tracetest.SpanStub
+	for _, s := range spans {
+		if !s.Parent.IsValid() || !known[s.Parent.SpanID()] {
+			roots = append(roots, s)
+			continue
+		}
+		children[s.Parent.SpanID()] = append(children[s.Parent.SpanID()], s)
+	}
+	for _, root := range roots {
+		if m.root.matches(root, children) {
+			return nil
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "no trace matches %s\nrecorded traces:\n", m.root)
+	for _, root := range roots {
+		writeTree(&b, root, children, 1)
+	}
+	return fmt.Errorf("%s", b.String())
+}
+
+func (m *SpanMatcher) matches(s tracetest.SpanStub, children map[trace.SpanID][]tracetest.SpanStub) bool {
+	if ok, _ := path.Match(m.name, s.Name); !ok && m.name != s.Name {
+		return false
+	}
+	if m.kind != trace.SpanKindUnspecified && m.kind != s.SpanKind {
+		return false
+	}
+	if m.status != codes.Unset && m.status != s.Status.Code {
+		return false
+	}
+	for _, want := range m.attrs {
+		if !slices.Contains(s.Attributes, want) {
+			return false
+		}
+	}
+	for _, child := range m.children {
+		found := slices.ContainsFunc(children[s.SpanContext.SpanID()], func(c tracetest.SpanStub) bool {
+			return child.matches(c, children)
+		})
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Describes the expected tree, e.g. GET /packages/* > getPackage{package.id=123}.
+func (m *SpanMatcher) String() string {
+	s := m.name
+	if m.status != codes.Unset {
+		s += "[" + m.status.String() + "]"
+	}
+	if len(m.attrs) > 0 {
+		attrs := make([]string, len(m.attrs))
+		for i, kv := range m.attrs {
+			attrs[i] = string(kv.Key) + "=" + kv.Value.Emit()
+		}
+		s += "{" + strings.Join(attrs, ",") + "}"
+	}
+	switch len(m.children) {
+	case 0:
+		return s
+	case 1:
+		return s + " > " + m.children[0].String()
+	}
+	children := make([]string, len(m.children))
+	for i, c := range m.children {
+		children[i] = c.String()
+	}
+	return s + " > (" + strings.Join(children, ", ") + ")"
+}
+
+func writeTree(b *strings.Builder, s tracetest.SpanStub, children map[trace.SpanID][]tracetest.SpanStub, depth int) {
+	fmt.Fprintf(b, "%s%s [%s]\n", strings.Repeat("  ", depth), s.Name, s.SpanKind)
+	for _, c := range children[s.SpanContext.SpanID()] {
+		writeTree(b, c, children, depth+1)
+	}
+}
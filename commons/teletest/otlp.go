@@ -0,0 +1,105 @@
+package teletest
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Converts an OTLP span back into the stub the matchers check. Kinds share
+// their numbering with the proto, statuses do not.
+func spanStub(s *tracepb.Span) tracetest.SpanStub {
+	traceID := trace.TraceID(s.TraceId)
+	stub := tracetest.SpanStub{
+		Name: s.Name,
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  trace.SpanID(s.SpanId),
+		}),
+		SpanKind:   trace.SpanKind(s.Kind),
+		StartTime:  time.Unix(0, int64(s.StartTimeUnixNano)),
+		EndTime:    time.Unix(0, int64(s.EndTimeUnixNano)),
+		Attributes: attributes(s.Attributes),
+	}
+	if len(s.ParentSpanId) == 8 {
+		stub.Parent = trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  trace.SpanID(s.ParentSpanId),
+		})
+	}
+	if st := s.Status; st != nil {
+		switch st.Code {
+		case tracepb.Status_STATUS_CODE_ERROR:
+			stub.Status = sdktrace.Status{Code: codes.Error, Description: st.Message}
+		case tracepb.Status_STATUS_CODE_OK:
+			stub.Status = sdktrace.Status{Code: codes.Ok}
+		}
+	}
+	for _, e := range s.Events {
+		stub.Events = append(stub.Events, sdktrace.Event{
+			Name:       e.Name,
+			Time:       time.Unix(0, int64(e.TimeUnixNano)),
+			Attributes: attributes(e.Attributes),
+		})
+	}
+	return stub
+}
+
+func attributes(kvs []*commonpb.KeyValue) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		if v, ok := attributeValue(kv.Value); ok {
+			attrs = append(attrs, attribute.KeyValue{Key: attribute.Key(kv.Key), Value: v})
+		}
+	}
+	return attrs
+}
+
+// Returns the attribute value of v, false for the maps and bytes attributes
+// cannot hold.
+func attributeValue(v *commonpb.AnyValue) (attribute.Value, bool) {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return attribute.StringValue(v.StringValue), true
+	case *commonpb.AnyValue_BoolValue:
+		return attribute.BoolValue(v.BoolValue), true
+	case *commonpb.AnyValue_IntValue:
+		return attribute.Int64Value(v.IntValue), true
+	case *commonpb.AnyValue_DoubleValue:
+		return attribute.Float64Value(v.DoubleValue), true
+	case *commonpb.AnyValue_ArrayValue:
+		var strs []string
+		var bools []bool
+		var ints []int64
+		var floats []float64
+		for _, e := range v.ArrayValue.Values {
+			switch e := e.GetValue().(type) {
+			case *commonpb.AnyValue_StringValue:
+				strs = append(strs, e.StringValue)
+			case *commonpb.AnyValue_BoolValue:
+				bools = append(bools, e.BoolValue)
+			case *commonpb.AnyValue_IntValue:
+				ints = append(ints, e.IntValue)
+			case *commonpb.AnyValue_DoubleValue:
+				floats = append(floats, e.DoubleValue)
+			}
+		}
+		switch {
+		case strs != nil:
+			return attribute.StringSliceValue(strs), true
+		case bools != nil:
+			return attribute.BoolSliceValue(bools), true
+		case ints != nil:
+			return attribute.Int64SliceValue(ints), true
+		case floats != nil:
+			return attribute.Float64SliceValue(floats), true
+		}
+	}
+	return attribute.Value{}, false
+}
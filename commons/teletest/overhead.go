@@ -0,0 +1,239 @@
+package teletest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Tracing modes compared by MeasureHandlerOverhead and
+// BenchmarkHandlerOverhead, named after their sub-benchmarks.
+const (
+	// instrumented, with a no-op provider
+	ModeOff = "off"
+	// instrumented, every trace dropped by the sampler
+	ModeSampledOut = "sampled-out"
+	// instrumented, every trace sampled and batched to a discarding exporter
+	ModeSampledIn = "sampled-in"
+)
+
+// Tracer provider of each tracing mode, along with its shutdown.
+var tracingModes = []struct {
+	mode     string
+	provider func() (trace.TracerProvider, func())
+}{
+	{ModeOff, func() (trace.TracerProvider, func()) { return noop.NewTracerProvider(), func() {} }},
+	{ModeSampledOut, func() (trace.TracerProvider, func()) {
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+		return tp, func() { _ = tp.Shutdown(context.Background()) }
+	}},
+	{ModeSampledIn, func() (trace.TracerProvider, func()) {
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithSampler(sdktrace.AlwaysSample()),
+			sdktrace.WithBatcher(discardExporter{}),
+		)
+		return tp, func() { _ = tp.Shutdown(context.Background()) }
+	}},
+}
+
+// Installs tp as the global tracer provider and returns the handler built by
+// newHandler behind the otelhttp middleware. Instrumentation binds the
+// provider it finds when created, hence a new handler per mode.
+func tracedHandler(tp trace.TracerProvider, newHandler func() http.Handler) http.Handler {
+	otel.SetTracerProvider(tp)
+	return otelhttp.NewHandler(newHandler(), "bench", otelhttp.WithTracerProvider(tp))
+}
+
+// Cost of serving one request in a tracing mode.
+type Overhead struct {
+	Mode        string
+	NsPerOp     int64
+	AllocsPerOp int64
+	BytesPerOp  int64
+}
+
+// Measurements of each mode, the fastest being kept, and their length.
+const (
+	overheadRounds   = 3
+	overheadDuration = 300 * time.Millisecond
+)
+
+// Measures the handlers built by newHandler in every tracing mode, serving
+// requests for path. The global tracer provider is the measured one, so the
+// spans of the handler count too. Modes are measured in turns, keeping the
+// fastest of overheadRounds runs, so a noisy neighbour does not count against
+// a single mode.
+func MeasureHandlerOverhead(newHandler func() http.Handler, path string) []Overhead {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+	results := make([]Overhead, len(tracingModes))
+	for round := 0; round < overheadRounds; round++ {
+		for i, m := range tracingModes {
+			tp, shutdown := m.provider()
+			o := measureRequests(tracedHandler(tp, newHandler), path, overheadDuration)
+			shutdown()
+			o.Mode = m.mode
+			if round == 0 || o.NsPerOp < results[i].NsPerOp {
+				results[i] = o
+			}
+		}
+	}
+	return results
+}
+
+// Runs a sub-benchmark per tracing mode serving requests for path through
+// the handler built by newHandler, e.g. from BenchmarkXxx in a service:
+//
+//	go test -bench . -benchmem
+func BenchmarkHandlerOverhead(b *testing.B, newHandler func() http.Handler, path string) {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+	for _, m := range tracingModes {
+		b.Run(m.mode, func(b *testing.B) {
+			tp, shutdown := m.provider()
+			defer shutdown()
+			h := tracedHandler(tp, newHandler)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+		})
+	}
+}
+
+// Serves requests for path for about d, returning their average cost.
+func measureRequests(h http.Handler, path string, d time.Duration) Overhead {
+	serve := func(n int) {
+		for i := 0; i < n; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+	}
+	// warms the caches and pools up
+	serve(100)
+	runtime.GC()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	n := 0
+	for batch := 100; time.Since(start) < d; n += batch {
+		serve(batch)
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return Overhead{
+		NsPerOp:     elapsed.Nanoseconds() / int64(n),
+		AllocsPerOp: int64(after.Mallocs-before.Mallocs) / int64(n),
+		BytesPerOp:  int64(after.TotalAlloc-before.TotalAlloc) / int64(n),
+	}
+}
+
+// Overhead tracing may add to a request compared to ModeOff: allocations,
+// and time as a fraction of the request time, so it holds across machines.
+// Time is only checked when its budget is positive.
+type OverheadBudget struct {
+	SampledOut       float64
+	SampledIn        float64
+	SampledOutAllocs int64
+	SampledInAllocs  int64
+}
+
+// Budget of the services of this repository, checked by their tests. Only
+// allocations are enforced by default, timings varying too much on shared
+// machines.
+var DefaultOverheadBudget = OverheadBudget{
+	SampledOutAllocs: 40,
+	SampledInAllocs:  80,
+}
+
+// Returns DefaultOverheadBudget, with the time budget enforced too when
+// TELETEST_TIMING_BUDGET=true, e.g. on a dedicated benchmark machine.
+func OverheadBudgetFromEnv() OverheadBudget {
+	b := DefaultOverheadBudget
+	if os.Getenv("TELETEST_TIMING_BUDGET") == "true" {
+		b.SampledOut, b.SampledIn = 0.5, 1
+	}
+	return b
+}
+
+// Returns an error listing the modes exceeding the budget.
+func (b OverheadBudget) Check(results []Overhead) error {
+	byMode := map[string]Overhead{}
+	for _, r := range results {
+		byMode[r.Mode] = r
+	}
+	base, ok := byMode[ModeOff]
+	if !ok || base.NsPerOp <= 0 {
+		return fmt.Errorf("no %s measurement to compare to", ModeOff)
+	}
+	var exceeded []string
+	for _, m := range []struct {
+		mode   string
+		time   float64
+		allocs int64
+	}{
+		{ModeSampledOut, b.SampledOut, b.SampledOutAllocs},
+		{ModeSampledIn, b.SampledIn, b.SampledInAllocs},
+	} {
+		r, ok := byMode[m.mode]
+		if !ok {
+			exceeded = append(exceeded, fmt.Sprintf("%s not measured", m.mode))
+			continue
+		}
+		if extra := float64(r.NsPerOp-base.NsPerOp) / float64(base.NsPerOp); m.time > 0 && extra > m.time {
+			exceeded = append(exceeded, fmt.Sprintf("%s adds %.0f%%, over %.0f%%", m.mode, extra*100, m.time*100))
+		}
+		if extra := r.AllocsPerOp - base.AllocsPerOp; extra > m.allocs {
+			exceeded = append(exceeded, fmt.Sprintf("%s adds %d allocations, over %d", m.mode, extra, m.allocs))
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("tracing overhead budget exceeded: %s", strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+// Benchmarks exporting batches of batchSize spans through exp, reporting
+// the exported spans per second.
+func BenchmarkExporter(b *testing.B, exp sdktrace.SpanExporter, batchSize int) {
+	stubs := make(tracetest.SpanStubs, batchSize)
+	now := time.Now()
+	for i := range stubs {
+		stubs[i] = tracetest.SpanStub{
+			Name:       fmt.Sprintf("span %d", i),
+			StartTime:  now,
+			EndTime:    now.Add(time.Millisecond),
+			Attributes: []attribute.KeyValue{attribute.Int("bench.index", i)},
+		}
+	}
+	spans := stubs.Snapshots()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := exp.ExportSpans(context.Background(), spans); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "spans/s")
+}
+
+type discardExporter struct{}
+
+func (discardExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error { return nil }
+
+func (discardExporter) Shutdown(context.Context) error { return nil }
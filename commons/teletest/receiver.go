@@ -0,0 +1,318 @@
+package teletest
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // accept gzip compressed exports
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Export received by a Receiver.
+type ReceivedExport struct {
+	// "grpc" or "http"
+	Protocol string
+	// request headers, or gRPC metadata, with lowercase keys
+	Headers map[string]string
+	// compression of the payload, empty when uncompressed
+	Compression   string
+	ResourceSpans []*tracepb.ResourceSpans
+}
+
+// Option of a Receiver.
+type ReceiverOption func(*receiverConfig)
+
+type receiverConfig struct {
+	tls     *tls.Config
+	discard bool
+}
+
+// Serves both protocols over TLS with the given configuration.
+func WithReceiverTLS(config *tls.Config) ReceiverOption {
+	return func(c *receiverConfig) {
+		c.tls = config
+	}
+}
+
+// Accepts the exports without keeping them, e.g. to benchmark exporters
+// without the receiver growing.
+func WithReceiverDiscard() ReceiverOption {
+	return func(c *receiverConfig) {
+		c.discard = true
+	}
+}
+
+// In-process OTLP trace receiver, serving the gRPC trace service and the
+// HTTP /v1/traces endpoint, so exporter settings such as headers, TLS,
+// compression and retries can be verified without a collector.
+type Receiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	// address of the gRPC endpoint, e.g. 127.0.0.1:4317
+	GRPCAddr string
+	// base URL of the HTTP endpoint, e.g. http://127.0.0.1:4318
+	HTTPURL string
+
+	discard  bool
+	mu       sync.Mutex
+	exports  []ReceivedExport
+	failures int
+	received chan struct{}
+	stop     func()
+}
+
+// Starts a receiver stopped when the test ends.
+func NewReceiver(tb testing.TB, opts ...ReceiverOption) *Receiver {
+	tb.Helper()
+	r, err := StartReceiver("127.0.0.1:0", opts...)
+	if err != nil {
+		tb.Fatalf("failed to listen for OTLP/gRPC: %v", err)
+	}
+	tb.Cleanup(r.Close)
+	return r
+}
+
+// Starts a receiver serving OTLP/gRPC on addr, e.g. for a collector to
+// forward to, outside of tests. It runs until Close.
+func StartReceiver(addr string, opts ...ReceiverOption) (*Receiver, error) {
+	var cfg receiverConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	r := &Receiver{received: make(chan struct{}, 1), discard: cfg.discard}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var serverOpts []grpc.ServerOption
+	if cfg.tls != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(cfg.tls)))
+	}
+	serverOpts = append(serverOpts, grpc.StatsHandler(compressionRecorder{}))
+	srv := grpc.NewServer(serverOpts...)
+	coltracepb.RegisterTraceServiceServer(srv, r)
+	colmetricspb.RegisterMetricsServiceServer(srv, discardMetrics{})
+	go func() { _ = srv.Serve(lis) }()
+	r.GRPCAddr = lis.Addr().String()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/traces", r.serveHTTP)
+	httpSrv := httptest.NewUnstartedServer(mux)
+	if cfg.tls != nil {
+		httpSrv.TLS = cfg.tls
+		httpSrv.StartTLS()
+	} else {
+		httpSrv.Start()
+	}
+	r.HTTPURL = httpSrv.URL
+
+	r.stop = func() {
+		srv.Stop()
+		httpSrv.Close()
+	}
+	return r, nil
+}
+
+// Stops serving.
+func (r *Receiver) Close() {
+	r.stop()
+}
+
+// Rejects the next n exports as unavailable, with gRPC status UNAVAILABLE or
+// HTTP 503, to exercise the exporter retries.
+func (r *Receiver) FailNext(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = n
+}
+
+// Returns the exports received so far, rejected ones excluded.
+func (r *Receiver) Exports() []ReceivedExport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ReceivedExport(nil), r.exports...)
+}
+
+// Returns the spans received so far, in order.
+func (r *Receiver) Spans() []*tracepb.Span {
+	var spans []*tracepb.Span
+	for _, e := range r.Exports() {
+		for _, rs := range e.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+// Returns the spans received so far as span stubs, to be checked with a
+// Matcher.
+func (r *Receiver) SpanStubs() tracetest.SpanStubs {
+	var stubs tracetest.SpanStubs
+	for _, e := range r.Exports() {
+		for _, rs := range e.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					stubs = append(stubs, spanStub(s))
+				}
+			}
+		}
+	}
+	return stubs
+}
+
+// Waits until at least n spans were received, or the timeout expires.
+func (r *Receiver) WaitForSpans(n int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for len(r.Spans()) < n {
+		select {
+		case <-r.received:
+		case <-deadline:
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Receiver) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := map[string]string{}
+	for k, v := range md {
+		headers[k] = strings.Join(v, ",")
+	}
+	if !r.record(ReceivedExport{
+		Protocol:      "grpc",
+		Headers:       headers,
+		Compression:   compressionFromContext(ctx),
+		ResourceSpans: req.ResourceSpans,
+	}) {
+		return nil, status.Error(codes.Unavailable, "receiver asked to fail")
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func (r *Receiver) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	payload, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var msg coltracepb.ExportTraceServiceRequest
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		err = protojson.Unmarshal(payload, &msg)
+	} else {
+		err = proto.Unmarshal(payload, &msg)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headers := map[string]string{}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	if !r.record(ReceivedExport{
+		Protocol:      "http",
+		Headers:       headers,
+		Compression:   req.Header.Get("Content-Encoding"),
+		ResourceSpans: msg.ResourceSpans,
+	}) {
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "receiver asked to fail", http.StatusServiceUnavailable)
+		return
+	}
+	resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+// Metrics service accepting and dropping the exports, so services pointed at
+// a receiver instead of a collector do not report failed metric exports.
+type discardMetrics struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+}
+
+func (discardMetrics) Export(context.Context, *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+// Keeps the export unless it must be rejected, reporting whether it was kept.
+func (r *Receiver) record(e ReceivedExport) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return false
+	}
+	if !r.discard {
+		r.exports = append(r.exports, e)
+	}
+	select {
+	case r.received <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+type compressionKey struct{}
+
+// Stats handler recording the compression of incoming gRPC calls, which the
+// metadata does not carry.
+type compressionRecorder struct{}
+
+func (compressionRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, compressionKey{}, new(string))
+}
+
+func (compressionRecorder) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok {
+		if c, ok := ctx.Value(compressionKey{}).(*string); ok {
+			*c = h.Compression
+		}
+	}
+}
+
+func (compressionRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (compressionRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func compressionFromContext(ctx context.Context) string {
+	if c, ok := ctx.Value(compressionKey{}).(*string); ok {
+		return *c
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+// Package teletest runs HTTP handlers against an in-memory trace exporter and
+// asserts on the shape of the resulting traces, e.g.
+//
+//	h := teletest.New(t, router)
+//	res, _ := h.Get(ctx, "/packages/123")
+//	res.Body.Close()
+//	h.AssertTrace(t, teletest.ExpectTrace().
+//		Root("GET /packages/*").
+//		Child("getPackage").WithAttr(attribute.String("package.id", "123")))
+package teletest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Handler served in-process with every span recorded in memory.
+type Harness struct {
+	Exporter *tracetest.InMemoryExporter
+	Provider *sdktrace.TracerProvider
+	Server   *httptest.Server
+	// traced client propagating the trace context to the server
+	Client *http.Client
+}
+
+// Serves handler and installs an always sampling tracer provider exporting
+// synchronously to memory, along with the W3C propagators. Everything is
+// restored when the test ends.
+func New(tb testing.TB, handler http.Handler) *Harness {
+	tb.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSyncer(exp),
+	)
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	h := &Harness{
+		Exporter: exp,
+		Provider: tp,
+		Server:   httptest.NewServer(handler),
+		Client:   &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+	tb.Cleanup(func() {
+		h.Server.Close()
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return h
+}
+
+// Sends a GET request for path to the served handler.
+func (h *Harness) Get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.Server.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return h.Client.Do(req)
+}
+
+// Returns the spans ended so far.
+func (h *Harness) Spans() tracetest.SpanStubs {
+	return h.Exporter.GetSpans()
+}
+
+// Drops the recorded spans.
+func (h *Harness) Reset() {
+	h.Exporter.Reset()
+}
+
+// Fails the test unless one of the recorded traces matches m.
+func (h *Harness) AssertTrace(tb testing.TB, m Matcher) {
+	tb.Helper()
+	if err := m.Match(h.Spans()); err != nil {
+		tb.Error(err)
+	}
+}